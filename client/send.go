@@ -3,65 +3,111 @@ package client
 import (
 	"bufio"
 	"fmt"
-	"io"
 	"log"
 	"net"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/TFMV/pulse/iso"
 	"github.com/moov-io/iso8583"
-	"github.com/moov-io/iso8583/encoding"
-	"github.com/moov-io/iso8583/field"
-	"github.com/moov-io/iso8583/prefix"
 )
 
-// Client represents an ISO8583 client
+// Default client settings
+const (
+	defaultTerminalID      = "PULSE001"
+	defaultResponseTimeout = 30 * time.Second
+)
+
+// Config holds the configuration of an ISO8583 client
+type Config struct {
+	// ServerAddr is the address of the ISO8583 server
+	ServerAddr string
+	// Spec is the message spec used to pack requests and unpack responses
+	Spec *iso8583.MessageSpec
+	// Framer frames messages on the wire (default: 2-byte binary length)
+	Framer iso.Framer
+	// TerminalID is sent in field 41 (default: PULSE001)
+	TerminalID string
+	// ResponseTimeout bounds how long a request waits for its response (default: 30s)
+	ResponseTimeout time.Duration
+}
+
+// Client represents an ISO8583 client. Requests may be sent concurrently on
+// the same connection; responses are matched to requests by STAN and terminal ID.
 type Client struct {
-	serverAddr  string
-	conn        net.Conn
-	messageSpec *iso8583.MessageSpec
+	config  Config
+	conn    net.Conn
+	writeMu sync.Mutex
+
+	// stan is the last System Trace Audit Number used
+	stan atomic.Uint32
+
+	// pending maps a STAN and terminal ID to the request waiting for its response
+	pending      map[string]chan *iso8583.Message
+	pendingMutex sync.Mutex
+
+	// done is closed when the read loop stops, readErr holds the reason
+	done    chan struct{}
+	readErr error
 }
 
 // NewClient creates a new ISO8583 client
-func NewClient(serverAddr string) *Client {
-	return &Client{
-		serverAddr: serverAddr,
+func NewClient(config Config) *Client {
+	if config.Framer == nil {
+		config.Framer = &iso.BinaryLengthFramer{}
+	}
+	if config.TerminalID == "" {
+		config.TerminalID = defaultTerminalID
 	}
+	if config.ResponseTimeout <= 0 {
+		config.ResponseTimeout = defaultResponseTimeout
+	}
+
+	c := &Client{
+		config:  config,
+		pending: make(map[string]chan *iso8583.Message),
+		done:    make(chan struct{}),
+	}
+	c.stan.Store(uint32(time.Now().Unix() % 1000000))
+
+	return c
 }
 
 // Connect establishes a connection to the server
 func (c *Client) Connect() error {
 	var err error
-	c.conn, err = net.Dial("tcp", c.serverAddr)
+	c.conn, err = net.Dial("tcp", c.config.ServerAddr)
 	if err != nil {
-		return fmt.Errorf("failed to connect to %s: %w", c.serverAddr, err)
+		return fmt.Errorf("failed to connect to %s: %w", c.config.ServerAddr, err)
 	}
 
-	// Initialize ISO8583 message spec
-	c.messageSpec = &iso8583.MessageSpec{
-		Name: "ISO 8583 v1987",
-		Fields: map[int]field.Field{
-			0:  field.NewString(field.NewSpec(4, "Message Type Indicator", encoding.ASCII, prefix.None.Fixed)),
-			2:  field.NewString(field.NewSpec(19, "Primary Account Number", encoding.ASCII, prefix.None.Fixed)),
-			4:  field.NewString(field.NewSpec(12, "Amount, Transaction", encoding.ASCII, prefix.None.Fixed)),
-			7:  field.NewString(field.NewSpec(10, "Transmission Date and Time", encoding.ASCII, prefix.None.Fixed)),
-			11: field.NewString(field.NewSpec(6, "System Trace Audit Number", encoding.ASCII, prefix.None.Fixed)),
-			39: field.NewString(field.NewSpec(2, "Response Code", encoding.ASCII, prefix.None.Fixed)),
-		},
-	}
+	go c.readLoop()
 
 	return nil
 }
 
-// SendAuthRequest sends an authorization request and returns the response
+// SendAuthRequest sends an authorization request (0100) and returns the response
 func (c *Client) SendAuthRequest(pan, amount string) (*iso8583.Message, error) {
+	return c.sendTransaction("0100", pan, amount)
+}
+
+// SendFinancialRequest sends a financial request (0200), which captures the
+// funds when approved, and returns the response
+func (c *Client) SendFinancialRequest(pan, amount string) (*iso8583.Message, error) {
+	return c.sendTransaction("0200", pan, amount)
+}
+
+// sendTransaction sends a transaction request with the given MTI
+func (c *Client) sendTransaction(mti, pan, amount string) (*iso8583.Message, error) {
 	// Create the ISO8583 message
-	message := iso8583.NewMessage(c.messageSpec)
+	message := iso8583.NewMessage(c.config.Spec)
 
 	// Set MTI (Field 0)
-	if err := message.Field(0, "0100"); err != nil {
+	if err := message.Field(0, mti); err != nil {
 		return nil, fmt.Errorf("failed to set MTI: %w", err)
 	}
 
@@ -76,16 +122,67 @@ func (c *Client) SendAuthRequest(pan, amount string) (*iso8583.Message, error) {
 	}
 
 	// Set Transaction Time (Field 7)
-	now := time.Now()
-	transmissionTime := now.Format("0102150405") // MMDDhhmmss
+	transmissionTime := time.Now().Format("0102150405") // MMDDhhmmss
+	if err := message.Field(7, transmissionTime); err != nil {
+		return nil, fmt.Errorf("failed to set transmission time: %w", err)
+	}
+
+	return c.Send(message)
+}
+
+// SignOn sends a network management sign-on (0800, field 70 = 001)
+func (c *Client) SignOn() (*iso8583.Message, error) {
+	return c.sendNetworkMessage(iso.NetworkSignOn)
+}
+
+// SignOff sends a network management sign-off (0800, field 70 = 002)
+func (c *Client) SignOff() (*iso8583.Message, error) {
+	return c.sendNetworkMessage(iso.NetworkSignOff)
+}
+
+// Echo sends a network management echo test (0800, field 70 = 301)
+func (c *Client) Echo() (*iso8583.Message, error) {
+	return c.sendNetworkMessage(iso.NetworkEcho)
+}
+
+// sendNetworkMessage sends a network management request with the given code
+func (c *Client) sendNetworkMessage(code string) (*iso8583.Message, error) {
+	message := iso8583.NewMessage(c.config.Spec)
+
+	if err := message.Field(0, "0800"); err != nil {
+		return nil, fmt.Errorf("failed to set MTI: %w", err)
+	}
+
+	transmissionTime := time.Now().Format("0102150405") // MMDDhhmmss
 	if err := message.Field(7, transmissionTime); err != nil {
 		return nil, fmt.Errorf("failed to set transmission time: %w", err)
 	}
 
-	// Set STAN (Field 11)
-	stan := fmt.Sprintf("%06d", now.Unix()%1000000)
-	if err := message.Field(11, stan); err != nil {
-		return nil, fmt.Errorf("failed to set STAN: %w", err)
+	if err := message.Field(70, code); err != nil {
+		return nil, fmt.Errorf("failed to set network management code: %w", err)
+	}
+
+	return c.Send(message)
+}
+
+// Send sends a request and waits for the response with the same STAN and
+// terminal ID. A STAN (field 11) and terminal ID (field 41) are assigned if
+// the request does not carry them.
+func (c *Client) Send(message *iso8583.Message) (*iso8583.Message, error) {
+	stan, err := message.GetString(11)
+	if err != nil || stan == "" {
+		stan = c.NextSTAN()
+		if err := message.Field(11, stan); err != nil {
+			return nil, fmt.Errorf("failed to set STAN: %w", err)
+		}
+	}
+
+	terminalID, err := message.GetString(41)
+	if err != nil || terminalID == "" {
+		terminalID = c.config.TerminalID
+		if err := message.Field(41, terminalID); err != nil {
+			return nil, fmt.Errorf("failed to set terminal ID: %w", err)
+		}
 	}
 
 	// Pack the message
@@ -94,56 +191,94 @@ func (c *Client) SendAuthRequest(pan, amount string) (*iso8583.Message, error) {
 		return nil, fmt.Errorf("failed to pack message: %w", err)
 	}
 
-	// Add length prefix (2 bytes)
-	length := len(packed)
-	lengthBytes := []byte{byte(length / 256), byte(length % 256)}
-	fullMessage := append(lengthBytes, packed...)
+	// Register for the response before sending so a fast reply is not missed
+	key := matchKey(stan, terminalID)
+	responseChan := make(chan *iso8583.Message, 1)
+	c.pendingMutex.Lock()
+	if _, exists := c.pending[key]; exists {
+		c.pendingMutex.Unlock()
+		return nil, fmt.Errorf("request with STAN %s and terminal %s already in flight", stan, terminalID)
+	}
+	c.pending[key] = responseChan
+	c.pendingMutex.Unlock()
 
-	// Send the message
+	defer func() {
+		c.pendingMutex.Lock()
+		delete(c.pending, key)
+		c.pendingMutex.Unlock()
+	}()
+
+	// Send the framed message
 	start := time.Now()
-	_, err = c.conn.Write(fullMessage)
+	c.writeMu.Lock()
+	err = c.config.Framer.WriteFrame(c.conn, packed, nil)
+	c.writeMu.Unlock()
 	if err != nil {
 		return nil, fmt.Errorf("failed to send message: %w", err)
 	}
 
-	// Read the response
-	response, err := c.ReadResponse()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	// Wait for the matching response
+	timer := time.NewTimer(c.config.ResponseTimeout)
+	defer timer.Stop()
+
+	select {
+	case response := <-responseChan:
+		log.Printf("Transaction %s completed in %s", stan, time.Since(start))
+		return response, nil
+	case <-c.done:
+		return nil, fmt.Errorf("failed to read response: %w", c.readErr)
+	case <-timer.C:
+		return nil, fmt.Errorf("no response for STAN %s after %s", stan, c.config.ResponseTimeout)
 	}
+}
 
-	elapsed := time.Since(start)
-	log.Printf("Transaction completed in %s", elapsed)
-
-	return response, nil
+// NextSTAN returns the next System Trace Audit Number for this client
+func (c *Client) NextSTAN() string {
+	return fmt.Sprintf("%06d", c.stan.Add(1)%1000000)
 }
 
-// ReadResponse reads an ISO8583 response
-func (c *Client) ReadResponse() (*iso8583.Message, error) {
+// readLoop reads responses and hands each one to the request waiting for it
+func (c *Client) readLoop() {
 	reader := bufio.NewReader(c.conn)
+	for {
+		// Read the framed message
+		frame, err := c.config.Framer.ReadFrame(reader)
+		if err != nil {
+			c.readErr = err
+			close(c.done)
+			return
+		}
 
-	// Read length bytes (2 bytes)
-	lengthBytes := make([]byte, 2)
-	if _, err := io.ReadFull(reader, lengthBytes); err != nil {
-		return nil, fmt.Errorf("failed to read length bytes: %w", err)
-	}
+		// Unpack the message
+		message := iso8583.NewMessage(c.config.Spec)
+		if err := message.Unpack(frame.Message); err != nil {
+			log.Printf("Failed to unpack response: %v", err)
+			continue
+		}
 
-	// Convert to int
-	length := int(lengthBytes[0])*256 + int(lengthBytes[1])
+		stan, _ := message.GetString(11)
+		terminalID, _ := message.GetString(41)
 
-	// Read the message
-	messageBytes := make([]byte, length)
-	if _, err := io.ReadFull(reader, messageBytes); err != nil {
-		return nil, fmt.Errorf("failed to read message: %w", err)
-	}
+		c.pendingMutex.Lock()
+		responseChan, ok := c.pending[matchKey(stan, terminalID)]
+		c.pendingMutex.Unlock()
 
-	// Unpack the message
-	message := iso8583.NewMessage(c.messageSpec)
-	if err := message.Unpack(messageBytes); err != nil {
-		return nil, fmt.Errorf("failed to unpack message: %w", err)
+		if !ok {
+			log.Printf("Discarding unmatched response for STAN %s, terminal %s", stan, terminalID)
+			continue
+		}
+
+		select {
+		case responseChan <- message:
+		default:
+			log.Printf("Discarding duplicate response for STAN %s, terminal %s", stan, terminalID)
+		}
 	}
+}
 
-	return message, nil
+// matchKey builds the key used to match a response to its request
+func matchKey(stan, terminalID string) string {
+	return stan + "/" + terminalID
 }
 
 // Close closes the connection
@@ -214,10 +349,15 @@ func getResponseCodeDescription(code string) string {
 		"13": "Invalid amount",
 		"14": "Invalid card number",
 		"15": "No such issuer",
+		"30": "Format error",
+		"41": "Lost card, pick up",
+		"43": "Stolen card, pick up",
 		"51": "Insufficient funds",
 		"54": "Expired card",
 		"55": "Invalid PIN",
 		"59": "Suspected fraud",
+		"62": "Restricted card",
+		"82": "Card verification value mismatch",
 		"91": "Issuer or switch inoperative",
 		"96": "System malfunction",
 	}
@@ -229,14 +369,22 @@ func getResponseCodeDescription(code string) string {
 }
 
 // RunInteractiveClient runs an interactive client session
-func RunInteractiveClient(serverAddr string) error {
-	client := NewClient(serverAddr)
+func RunInteractiveClient(config Config) error {
+	client := NewClient(config)
 	if err := client.Connect(); err != nil {
 		return err
 	}
 	defer client.Close()
 
-	fmt.Println("Connected to Pulse server at", serverAddr)
+	// Sign on so listeners that require it accept our authorizations
+	if response, err := client.SignOn(); err != nil {
+		log.Printf("Sign-on failed: %v", err)
+	} else if code, _ := response.GetString(39); code != "00" {
+		log.Printf("Sign-on declined with response code %s", code)
+	}
+	defer client.SignOff()
+
+	fmt.Println("Connected to Pulse server at", config.ServerAddr)
 	fmt.Println("Enter transactions (PAN,Amount) or 'quit' to exit.")
 	fmt.Println("Examples:")
 	fmt.Println("  4111111111111111,50.00  - US transaction, should be approved")
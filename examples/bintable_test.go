@@ -0,0 +1,61 @@
+package examples
+
+import (
+	"testing"
+
+	"github.com/TFMV/pulse/router"
+)
+
+func TestBinTableLongestMatch(t *testing.T) {
+	table, err := router.NewBinTable(map[string]string{
+		"4":             "us-east",
+		"400000-499999": "us-east", // Same BINs and region as "4"
+		"4571":          "eu-west",
+		"45717360":      "ap-south",
+		"222100-272099": "eu-west",
+		"51":            "eu-west",
+	})
+	if err != nil {
+		t.Fatalf("Failed to build BIN table: %v", err)
+	}
+
+	tests := []struct {
+		pan    string
+		region string
+		found  bool
+	}{
+		{"4111111111111111", "us-east", true},
+		{"4571111111111111", "eu-west", true},
+		{"4571736011111111", "ap-south", true},
+		{"4571736111111111", "eu-west", true},
+		{"2221000000000009", "eu-west", true},
+		{"2720999999999999", "eu-west", true},
+		{"2721000000000000", "", false},
+		{"5111111111111111", "eu-west", true},
+		{"6011111111111111", "", false},
+		{"4111", "", false},
+		{"4111ABCD11111111", "", false},
+	}
+	for _, test := range tests {
+		region, found := table.Lookup(test.pan)
+		if region != test.region || found != test.found {
+			t.Errorf("PAN %s: expected (%q, %v) but got (%q, %v)", test.pan, test.region, test.found, region, found)
+		}
+	}
+}
+
+func TestBinTableRejectsAmbiguousRoutes(t *testing.T) {
+	tests := map[string]map[string]string{
+		"partial overlap":   {"4000-4999": "us-east", "4500-5499": "eu-west"},
+		"same BINs":         {"4": "us-east", "40-49": "eu-west"},
+		"mismatched bounds": {"4000-49999": "us-east"},
+		"too long":          {"411111111": "us-east"},
+		"not numeric":       {"4A": "us-east"},
+		"reversed range":    {"4999-4000": "us-east"},
+	}
+	for name, routes := range tests {
+		if _, err := router.NewBinTable(routes); err == nil {
+			t.Errorf("%s: expected routes %v to be rejected", name, routes)
+		}
+	}
+}
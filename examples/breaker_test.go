@@ -0,0 +1,117 @@
+package examples
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/router"
+)
+
+func TestBreakerErrorRate(t *testing.T) {
+	health := router.NewRegionHealth(router.BreakerConfig{
+		FailureThreshold:   100,
+		ErrorRateThreshold: 0.5,
+		MinimumRequests:    10,
+		Window:             time.Minute,
+	})
+
+	// Half the calls fail, but not enough calls have been made yet
+	for i := 0; i < 4; i++ {
+		health.RecordSuccess(time.Millisecond)
+		health.RecordFailure()
+	}
+	if health.GetState() != router.CircuitClosed {
+		t.Fatal("Expected the circuit to stay closed below the minimum volume")
+	}
+
+	health.RecordSuccess(time.Millisecond)
+	health.RecordFailure()
+	if health.GetState() != router.CircuitOpen {
+		t.Fatal("Expected a 50% error rate over 10 calls to open the circuit")
+	}
+}
+
+func TestBreakerSlowCallRate(t *testing.T) {
+	health := router.NewRegionHealth(router.BreakerConfig{
+		SlowCallRateThreshold: 0.3,
+		SlowCallDuration:      100 * time.Millisecond,
+		MinimumRequests:       10,
+	})
+
+	for i := 0; i < 7; i++ {
+		health.RecordSuccess(10 * time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		health.RecordSuccess(time.Second)
+	}
+	if health.GetState() != router.CircuitOpen {
+		t.Fatal("Expected a 30% slow call rate to open the circuit")
+	}
+}
+
+func TestBreakerHalfOpenProbes(t *testing.T) {
+	health := router.NewRegionHealth(router.BreakerConfig{
+		FailureThreshold:  2,
+		OpenDuration:      20 * time.Millisecond,
+		HalfOpenMaxProbes: 2,
+	})
+
+	health.RecordFailure()
+	health.RecordFailure()
+	if health.Acquire() {
+		t.Fatal("Expected an open circuit to refuse requests")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	// Only two probes may be in flight while half-open
+	if !health.Acquire() || !health.Acquire() {
+		t.Fatal("Expected the half-open circuit to allow two probes")
+	}
+	if health.GetState() != router.CircuitHalfOpen {
+		t.Fatalf("Expected a half-open circuit but got state %d", health.GetState())
+	}
+	if health.Acquire() || health.IsHealthy() {
+		t.Fatal("Expected a third probe to be refused")
+	}
+
+	// A failed probe opens the circuit again
+	health.RecordFailure()
+	if health.GetState() != router.CircuitOpen {
+		t.Fatal("Expected a failed probe to open the circuit")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !health.Acquire() {
+		t.Fatal("Expected the circuit to allow a probe after the open duration")
+	}
+	health.RecordSuccess(time.Millisecond)
+	if health.GetState() != router.CircuitClosed {
+		t.Fatal("Expected a successful probe to close the circuit")
+	}
+}
+
+func TestBreakerIgnoresServingHealthChecks(t *testing.T) {
+	health := router.NewRegionHealth(router.BreakerConfig{
+		FailureThreshold: 2,
+		OpenDuration:     20 * time.Millisecond,
+	})
+
+	// A serving health check between failed requests does not reset them
+	health.RecordFailure()
+	health.MarkServing()
+	health.RecordFailure()
+	if health.GetState() != router.CircuitOpen {
+		t.Fatal("Expected two failed requests to open the circuit despite a serving health check")
+	}
+
+	// Nor does it end the half-open probe or close the circuit
+	time.Sleep(30 * time.Millisecond)
+	if !health.Acquire() {
+		t.Fatal("Expected the circuit to allow a probe after the open duration")
+	}
+	health.MarkServing()
+	if snapshot := health.Snapshot(); snapshot.State != router.CircuitHalfOpen || snapshot.HalfOpenProbes != 1 {
+		t.Fatalf("Expected the probe to stay in flight but got %+v", snapshot)
+	}
+}
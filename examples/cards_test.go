@@ -0,0 +1,152 @@
+package examples
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/pulse/iso"
+	"github.com/TFMV/pulse/issuer"
+	"github.com/TFMV/pulse/router"
+	"github.com/moov-io/iso8583"
+)
+
+func TestCardChecks(t *testing.T) {
+	seedFile := filepath.Join(t.TempDir(), "cards.yaml")
+	if err := os.WriteFile(seedFile, []byte(`
+cards:
+  - {pan: "4111111111111111", expiry: "4912", service_code: "201", pin: "1234", cvv: "123"}
+  - {pan: "5555555555554444", expiry: "4912", service_code: "220", pin: "4321", cvv: "737"}
+  - {pan: "4000000000000002", status: "blocked", expiry: "4912", service_code: "201"}
+  - {pan: "4000000000000028", status: "lost", expiry: "4912", service_code: "201"}
+  - {pan: "4000000000000036", status: "stolen", expiry: "4912", service_code: "201"}
+  - {pan: "4000000000000044", expiry: "2001", service_code: "201"}
+`), 0o644); err != nil {
+		t.Fatalf("Failed to write seed file: %v", err)
+	}
+	cards, err := issuer.NewCardRegistry(issuer.CardRegistryConfig{Enabled: true, SeedFile: seedFile})
+	if err != nil {
+		t.Fatalf("Failed to create card registry: %v", err)
+	}
+	usEast, err := issuer.NewRuleIssuer(builtinConfig(t, issuer.USEastConfig), cards, nil)
+	if err != nil {
+		t.Fatalf("Failed to create issuer: %v", err)
+	}
+
+	rt := router.NewRouter(router.Config{
+		DefaultRegion: "us-east",
+		Regions:       map[string]router.RegionConfig{"us-east": startIssuer(t, usEast)},
+	}, nil, nil, nil)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	pinBlock := func(pin, pan string) string {
+		block, err := issuer.EncodePINBlock(pin, pan)
+		if err != nil {
+			t.Fatalf("Failed to encode PIN block: %v", err)
+		}
+		return block
+	}
+
+	stan := 0
+	request := func(pan string, fields map[int]string) *iso8583.Message {
+		stan++
+		message := newMessage(t, map[int]string{
+			0:  "0100",
+			2:  pan,
+			4:  "50",
+			7:  "0102150405",
+			11: fmt.Sprintf("%06d", 700+stan),
+		})
+		for id, value := range fields {
+			if id == 52 {
+				block, _ := hex.DecodeString(value)
+				if err := message.BinaryField(id, block); err != nil {
+					t.Fatalf("Failed to set PIN block: %v", err)
+				}
+				continue
+			}
+			if err := message.Field(id, value); err != nil {
+				t.Fatalf("Failed to set field %d: %v", id, err)
+			}
+		}
+		return message
+	}
+	authorize := func(pan string, fields map[int]string) string {
+		response, err := rt.HandleMessage(context.Background(), request(pan, fields))
+		if err != nil {
+			t.Fatalf("HandleMessage failed: %v", err)
+		}
+		code, _ := response.GetString(39)
+		return code
+	}
+
+	cases := []struct {
+		name   string
+		pan    string
+		fields map[int]string
+		code   string
+	}{
+		{"card not present", "4111111111111111", map[int]string{14: "4912", 48: "123"}, "00"},
+		{"unknown card", "4111111111111129", nil, "14"},
+		{"blocked card", "4000000000000002", nil, "62"},
+		{"lost card", "4000000000000028", nil, "41"},
+		{"stolen card", "4000000000000036", nil, "43"},
+		{"expired card", "4000000000000044", nil, "54"},
+		{"wrong expiration date", "4111111111111111", map[int]string{14: "4911"}, "54"},
+		{"wrong CVV", "4111111111111111", map[int]string{14: "4912", 48: "321"}, "82"},
+		{"track 2 without PIN", "4111111111111111", map[int]string{35: "4111111111111111=49122010000"}, "00"},
+		{"altered service code", "4111111111111111", map[int]string{35: "4111111111111111=49121010000"}, "59"},
+		{"track 2 with wrong expiry", "4111111111111111", map[int]string{35: "4111111111111111D49012010000"}, "54"},
+		{"PIN required", "5555555555554444", map[int]string{35: "5555555555554444=49122200000"}, "55"},
+		{"wrong PIN", "5555555555554444", map[int]string{35: "5555555555554444=49122200000",
+			52: pinBlock("1234", "5555555555554444")}, "55"},
+		{"correct PIN", "5555555555554444", map[int]string{35: "5555555555554444=49122200000",
+			52: pinBlock("4321", "5555555555554444")}, "00"},
+	}
+	for _, tc := range cases {
+		if code := authorize(tc.pan, tc.fields); code != tc.code {
+			t.Errorf("%s: expected response code %s but got %s", tc.name, tc.code, code)
+		}
+	}
+
+	// Malformed track 2 data is a format error
+	malformed := request("4111111111111111", map[int]string{35: "4111111111111111"})
+	if _, err := rt.HandleMessage(context.Background(), malformed); iso.ErrorClass(err) != iso.ErrorFormat {
+		t.Errorf("Expected a format error for malformed track 2 data but got %v", err)
+	}
+
+	// Cards reported lost are declined from then on
+	if err := cards.SetStatus("4111111111111111", issuer.CardLost); err != nil {
+		t.Fatalf("SetStatus failed: %v", err)
+	}
+	if code := authorize("4111111111111111", nil); code != "41" {
+		t.Errorf("Expected the card reported lost to be declined with 41 but got %s", code)
+	}
+}
+
+func TestCardSeedValidation(t *testing.T) {
+	cards, err := issuer.NewCardRegistry(issuer.CardRegistryConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create card registry: %v", err)
+	}
+	for _, card := range []issuer.Card{
+		{PAN: "4111111111111111", Expiry: "4913", ServiceCode: "201"},
+		{PAN: "4111111111111111", Expiry: "4912", ServiceCode: "20"},
+		{PAN: "4111111111111111", Expiry: "4912", ServiceCode: "201", Status: "closed"},
+	} {
+		if err := cards.Add(card); err == nil {
+			t.Errorf("Expected card %+v to be rejected", card)
+		}
+	}
+
+	// The sample seed file loads
+	if _, err := issuer.NewCardRegistry(issuer.CardRegistryConfig{SeedFile: "../config/cards.yaml"}); err != nil {
+		t.Errorf("Failed to load the sample card seed: %v", err)
+	}
+}
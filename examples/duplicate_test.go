@@ -0,0 +1,114 @@
+package examples
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+	"github.com/TFMV/pulse/router"
+	"github.com/TFMV/pulse/storage"
+)
+
+// callCountingIssuer counts the requests that reach the issuer
+type callCountingIssuer struct {
+	proto.AuthServiceServer
+	calls atomic.Int32
+}
+
+func (c *callCountingIssuer) ProcessAuth(ctx context.Context, req *proto.AuthRequest) (*proto.AuthResponse, error) {
+	if req.Mti != "0800" {
+		c.calls.Add(1)
+	}
+	return c.AuthServiceServer.ProcessAuth(ctx, req)
+}
+
+func TestDuplicateTransmission(t *testing.T) {
+	usEast := &callCountingIssuer{AuthServiceServer: newUSEastIssuer(t)}
+	rt := router.NewRouter(router.Config{
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east": startIssuer(t, usEast),
+		},
+		Duplicates: router.DuplicateConfig{Enabled: true, TTL: time.Minute},
+	}, nil, nil, nil)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	request := map[int]string{
+		0:  "0100",
+		2:  "4111111111111111",
+		4:  "50",
+		7:  "0102150405",
+		11: "000301",
+		41: "TERM0001",
+	}
+
+	// The original and two retransmissions arrive together
+	var wg sync.WaitGroup
+	codes := make([]string, 3)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fields := make(map[int]string, len(request))
+			for id, value := range request {
+				fields[id] = value
+			}
+			if i == 2 {
+				fields[0] = "0101" // Repeat
+			}
+			response, err := rt.HandleMessage(context.Background(), newMessage(t, fields))
+			if err != nil {
+				t.Errorf("Request %d failed: %v", i, err)
+				return
+			}
+			codes[i], _ = response.GetString(39)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != "00" {
+			t.Errorf("Request %d: expected response code 00 but got %s", i, code)
+		}
+	}
+	if calls := usEast.calls.Load(); calls != 1 {
+		t.Errorf("Expected 1 issuer call but got %d", calls)
+	}
+
+	// The same STAN from another terminal is a different transaction
+	request[41] = "TERM0002"
+	if _, err := rt.HandleMessage(context.Background(), newMessage(t, request)); err != nil {
+		t.Fatalf("Request from another terminal failed: %v", err)
+	}
+	if calls := usEast.calls.Load(); calls != 2 {
+		t.Errorf("Expected 2 issuer calls but got %d", calls)
+	}
+}
+
+func TestDuplicateResponsesExpire(t *testing.T) {
+	detector := router.NewDuplicateDetector(storage.NewMemoryStore(), 50*time.Millisecond)
+	ctx := context.Background()
+
+	_, transmission, err := detector.Begin(ctx, "key")
+	if err != nil || transmission == nil {
+		t.Fatalf("Expected a new transmission, got %v", err)
+	}
+	transmission.Complete(ctx, &proto.AuthResponse{ResponseCode: "00"})
+
+	if response, _, _ := detector.Begin(ctx, "key"); response == nil {
+		t.Fatal("Expected the stored response for a retransmission")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	response, transmission, _ := detector.Begin(ctx, "key")
+	if response != nil {
+		t.Fatal("Expected the response to expire")
+	}
+	transmission.Release()
+}
@@ -0,0 +1,67 @@
+package examples
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/TFMV/pulse/iso"
+	"github.com/TFMV/pulse/spec"
+	"github.com/moov-io/iso8583"
+)
+
+func TestErrorResponse(t *testing.T) {
+	messageSpec := spec.Default()
+
+	request := iso8583.NewMessage(messageSpec)
+	fields := map[int]string{
+		0:  "0100",
+		2:  "4111111111111111",
+		11: "000042",
+		41: "TERM0001",
+	}
+	for id, value := range fields {
+		if err := request.Field(id, value); err != nil {
+			t.Fatalf("Failed to set field %d: %v", id, err)
+		}
+	}
+
+	err := iso.NewProcessingError(iso.ErrorIssuerUnavailable, errors.New("no client available"))
+	class := iso.ErrorClass(fmt.Errorf("routing failed: %w", err))
+	if class != iso.ErrorIssuerUnavailable {
+		t.Fatalf("Expected class %s but got %s", iso.ErrorIssuerUnavailable, class)
+	}
+
+	response, err := iso.NewResponse(request, iso.DefaultErrorResponseCodes[class])
+	if err != nil {
+		t.Fatalf("Failed to build error response: %v", err)
+	}
+
+	expected := map[int]string{0: "0110", 11: "000042", 41: "TERM0001", 39: "91"}
+	for id, value := range expected {
+		got, err := response.GetString(id)
+		if err != nil {
+			t.Fatalf("Failed to get field %d: %v", id, err)
+		}
+		if got != value {
+			t.Errorf("Field %d: expected %q but got %q", id, value, got)
+		}
+	}
+
+	if class := iso.ErrorClass(errors.New("boom")); class != iso.ErrorSystem {
+		t.Errorf("Expected untagged errors to be %s but got %s", iso.ErrorSystem, class)
+	}
+}
+
+func TestResponseMTI(t *testing.T) {
+	cases := map[string]string{"0100": "0110", "0200": "0210", "0420": "0430", "0401": "0410", "0800": "0810"}
+	for mti, expected := range cases {
+		got, err := iso.ResponseMTI(mti)
+		if err != nil {
+			t.Fatalf("Failed to get response MTI for %s: %v", mti, err)
+		}
+		if got != expected {
+			t.Errorf("Response MTI for %s: expected %s but got %s", mti, expected, got)
+		}
+	}
+}
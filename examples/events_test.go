@@ -0,0 +1,67 @@
+package examples
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+	"github.com/TFMV/pulse/router"
+)
+
+func TestCircuitEvents(t *testing.T) {
+	rt := router.NewRouter(router.Config{
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east": startIssuer(t, proto.UnimplementedAuthServiceServer{}),
+		},
+	}, nil, nil, nil)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	events, unsubscribe := rt.SubscribeCircuitEvents(4)
+	defer unsubscribe()
+
+	for i := 0; i < router.DefaultFailureThreshold; i++ {
+		rt.HandleMessage(context.Background(), newMessage(t, map[int]string{
+			0:  "0100",
+			2:  "4111111111111111",
+			4:  "50",
+			7:  "0102150405",
+			11: fmt.Sprintf("%06d", 900+i),
+		}))
+	}
+
+	select {
+	case event := <-events:
+		if event.Region != "us-east" || event.From != router.CircuitClosed ||
+			event.To != router.CircuitOpen || event.Cause != router.CauseConsecutiveFailures {
+			t.Errorf("Unexpected circuit event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a circuit event")
+	}
+
+	statuses := rt.RegionStatuses()
+	if len(statuses) != 1 {
+		t.Fatalf("Expected 1 region status but got %d", len(statuses))
+	}
+	status := statuses[0]
+	if status.State != router.CircuitOpen || status.ConsecutiveFailures != router.DefaultFailureThreshold ||
+		status.LastCause != router.CauseConsecutiveFailures {
+		t.Errorf("Unexpected region status %+v", status)
+	}
+
+	data, err := json.Marshal(statuses)
+	if err != nil {
+		t.Fatalf("Failed to encode region statuses: %v", err)
+	}
+	if !strings.Contains(string(data), `"state":"open"`) {
+		t.Errorf("Expected the state to be encoded by name but got %s", data)
+	}
+}
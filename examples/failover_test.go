@@ -0,0 +1,105 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/TFMV/pulse/proto"
+	"github.com/TFMV/pulse/router"
+	"github.com/TFMV/pulse/storage"
+)
+
+func TestFailoverChain(t *testing.T) {
+	euWest := &callCountingIssuer{AuthServiceServer: newEUWestIssuer(t)}
+	apSouth := &callCountingIssuer{AuthServiceServer: newUSEastIssuer(t)}
+
+	store := storage.NewMemoryStore()
+	rt := router.NewRouter(router.Config{
+		BinRoutes:     map[string]string{"4": "us-east"},
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			// Issuers that fail every request
+			"us-east":  startIssuer(t, proto.UnimplementedAuthServiceServer{}),
+			"us-west":  startIssuer(t, proto.UnimplementedAuthServiceServer{}),
+			"eu-west":  startIssuer(t, euWest),
+			"ap-south": startIssuer(t, apSouth),
+		},
+		FailoverChains: map[string][]router.FailoverTarget{
+			"us-east": {
+				{Region: "us-west"},
+				{Region: "eu-west", Priority: 2, Weight: 3},
+				{Region: "ap-south", Priority: 2, Weight: 1},
+			},
+		},
+	}, nil, nil, store)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	authorize := func(i int) (string, error) {
+		response, err := rt.HandleMessage(context.Background(), newMessage(t, map[int]string{
+			0:  "0100",
+			2:  "4111111111111111",
+			4:  "50",
+			7:  "0102150405",
+			11: fmt.Sprintf("%06d", 700+i),
+		}))
+		if err != nil {
+			return "", err
+		}
+		code, _ := response.GetString(39)
+		return code, nil
+	}
+
+	// Open the circuits of us-east and then of us-west, its first failover
+	for i := 0; i < 2*router.DefaultFailureThreshold; i++ {
+		if _, err := authorize(i); err == nil {
+			t.Fatalf("Request %d: expected the failing regions to return an error", i)
+		}
+	}
+
+	// The second priority shares traffic by weight
+	const requests = 40
+	for i := 0; i < requests; i++ {
+		code, err := authorize(100 + i)
+		if err != nil || code != "00" {
+			t.Fatalf("Request %d: expected approval after failover but got %q, %v", i, code, err)
+		}
+	}
+	euWestCalls, apSouthCalls := euWest.calls.Load(), apSouth.calls.Load()
+	if euWestCalls+apSouthCalls != requests || apSouthCalls == 0 || euWestCalls <= apSouthCalls {
+		t.Errorf("Expected a 3:1 split between eu-west and ap-south but got %d and %d", euWestCalls, apSouthCalls)
+	}
+
+	last := storage.TransactionKey{Stan: fmt.Sprintf("%06d", 700+100+requests-1), TransmissionTime: "0102150405"}
+	record := waitForRecord(t, store, last)
+	if record.PrimaryRegion != "us-east" || record.FailoverHops != 2 ||
+		(record.Region != "eu-west" && record.Region != "ap-south") {
+		t.Errorf("Expected a record routed from us-east after 2 hops but got region %s, primary %s, hops %d",
+			record.Region, record.PrimaryRegion, record.FailoverHops)
+	}
+}
+
+func TestFailoverChainValidation(t *testing.T) {
+	regions := map[string]router.RegionConfig{
+		"us-east": {Host: "127.0.0.1", Port: 1},
+		"eu-west": {Host: "127.0.0.1", Port: 2},
+	}
+	tests := map[string][]router.FailoverTarget{
+		"unknown region":  {{Region: "ap-south"}},
+		"primary region":  {{Region: "us-east"}},
+		"negative weight": {{Region: "eu-west", Weight: -1}},
+	}
+	for name, chain := range tests {
+		config := router.Config{
+			DefaultRegion:  "us-east",
+			Regions:        regions,
+			FailoverChains: map[string][]router.FailoverTarget{"us-east": chain},
+		}
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: expected chain %v to be rejected", name, chain)
+		}
+	}
+}
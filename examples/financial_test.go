@@ -0,0 +1,105 @@
+package examples
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/iso"
+	"github.com/TFMV/pulse/proto"
+	"github.com/TFMV/pulse/router"
+	"github.com/TFMV/pulse/storage"
+)
+
+// waitForRecord waits for an asynchronously stored transaction
+func waitForRecord(t *testing.T, store storage.Storage, key storage.TransactionKey) *proto.AuthRecord {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if record, _ := store.GetTransaction(context.Background(), key); record != nil {
+			return record
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Transaction %s was not stored", key)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFinancialAndCompletionMessages(t *testing.T) {
+	store := storage.NewMemoryStore()
+	rt := router.NewRouter(router.Config{
+		BinRoutes:     map[string]string{"4": "us-east"},
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east": startIssuer(t, newUSEastIssuer(t)),
+		},
+	}, nil, nil, store)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	ctx := context.Background()
+	cases := []struct {
+		name  string
+		mti   string
+		stan  string
+		extra map[int]string
+		class proto.MessageClass
+	}{
+		{"financial", "0200", "000201", nil, proto.MessageClass_MESSAGE_CLASS_FINANCIAL},
+		{"pre-auth", "0100", "000202", nil, proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION},
+		{"completion", "0220", "000203", map[int]string{
+			90: "010000020201021504050000000000000000000000",
+		}, proto.MessageClass_MESSAGE_CLASS_COMPLETION},
+	}
+
+	for _, tc := range cases {
+		fields := map[int]string{
+			0:  tc.mti,
+			2:  "4111111111111111",
+			4:  "75",
+			7:  "0102150405",
+			11: tc.stan,
+		}
+		for id, value := range tc.extra {
+			fields[id] = value
+		}
+
+		response, err := rt.HandleMessage(ctx, newMessage(t, fields))
+		if err != nil {
+			t.Fatalf("%s failed: %v", tc.name, err)
+		}
+
+		expectedMti, _ := iso.ResponseMTI(tc.mti)
+		if mti, _ := response.GetString(0); mti != expectedMti {
+			t.Errorf("%s: expected MTI %s but got %s", tc.name, expectedMti, mti)
+		}
+		if code, _ := response.GetString(39); code != "00" {
+			t.Errorf("%s: expected response code 00 but got %s", tc.name, code)
+		}
+
+		record := waitForRecord(t, store, storage.TransactionKey{Stan: tc.stan, TransmissionTime: "0102150405"})
+		if record.MessageClass != tc.class {
+			t.Errorf("%s: expected stored class %s but got %s", tc.name, tc.class, record.MessageClass)
+		}
+	}
+
+	// A completion for an unknown authorization cannot be matched
+	response, err := rt.HandleMessage(ctx, newMessage(t, map[int]string{
+		0:  "0220",
+		2:  "4111111111111111",
+		4:  "75",
+		7:  "0102150405",
+		11: "000204",
+		90: "010099999901021504050000000000000000000000",
+	}))
+	if err != nil {
+		t.Fatalf("Unknown completion failed: %v", err)
+	}
+	if code, _ := response.GetString(39); code != "25" {
+		t.Errorf("Expected response code 25 for an unknown original but got %s", code)
+	}
+}
@@ -0,0 +1,149 @@
+package examples
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/TFMV/pulse/iso"
+)
+
+func TestFramerRoundTrip(t *testing.T) {
+	message := []byte("0100 packed message")
+
+	cases := []struct {
+		framing string
+		prefix  []byte
+	}{
+		{iso.FramingBinary2, []byte{0x00, 0x13}},
+		{iso.FramingBinary2Inclusive, []byte{0x00, 0x15}},
+		{iso.FramingASCII4, []byte("0019")},
+	}
+	for _, tc := range cases {
+		framer, err := iso.NewFramer(iso.FramerConfig{Type: tc.framing})
+		if err != nil {
+			t.Fatalf("%s: NewFramer failed: %v", tc.framing, err)
+		}
+
+		var wire bytes.Buffer
+		if err := framer.WriteFrame(&wire, message, nil); err != nil {
+			t.Fatalf("%s: WriteFrame failed: %v", tc.framing, err)
+		}
+		if !bytes.HasPrefix(wire.Bytes(), tc.prefix) {
+			t.Errorf("%s: expected length prefix %q but got %q", tc.framing, tc.prefix, wire.Bytes()[:len(tc.prefix)])
+		}
+
+		frame, err := framer.ReadFrame(&wire)
+		if err != nil {
+			t.Fatalf("%s: ReadFrame failed: %v", tc.framing, err)
+		}
+		if !bytes.Equal(frame.Message, message) {
+			t.Errorf("%s: expected message %q but got %q", tc.framing, message, frame.Message)
+		}
+		if wire.Len() != 0 {
+			t.Errorf("%s: expected the whole frame to be read but %d bytes are left", tc.framing, wire.Len())
+		}
+	}
+}
+
+func TestTPDUFramer(t *testing.T) {
+	framer, err := iso.NewFramer(iso.FramerConfig{Type: iso.FramingBinary2, TPDU: "6000010002"})
+	if err != nil {
+		t.Fatalf("NewFramer failed: %v", err)
+	}
+
+	// Messages that do not answer a frame carry the configured TPDU
+	var wire bytes.Buffer
+	if err := framer.WriteFrame(&wire, []byte("0800"), nil); err != nil {
+		t.Fatalf("WriteFrame failed: %v", err)
+	}
+	if expected := []byte{0x00, 0x09, 0x60, 0x00, 0x01, 0x00, 0x02, '0', '8', '0', '0'}; !bytes.Equal(wire.Bytes(), expected) {
+		t.Errorf("Expected frame % X but got % X", expected, wire.Bytes())
+	}
+
+	request, err := framer.ReadFrame(bytes.NewReader([]byte{0x00, 0x09, 0x60, 0x00, 0x05, 0x00, 0x07, '0', '1', '0', '0'}))
+	if err != nil {
+		t.Fatalf("ReadFrame failed: %v", err)
+	}
+	if !bytes.Equal(request.Header, []byte{0x60, 0x00, 0x05, 0x00, 0x07}) || string(request.Message) != "0100" {
+		t.Errorf("Expected TPDU 60 00 05 00 07 and message 0100 but got % X and %q", request.Header, request.Message)
+	}
+
+	// Replies swap the destination and source NII of the request
+	wire.Reset()
+	if err := framer.WriteFrame(&wire, []byte("0110"), request); err != nil {
+		t.Fatalf("WriteFrame failed: %v", err)
+	}
+	if expected := []byte{0x00, 0x09, 0x60, 0x00, 0x07, 0x00, 0x05, '0', '1', '1', '0'}; !bytes.Equal(wire.Bytes(), expected) {
+		t.Errorf("Expected reply % X but got % X", expected, wire.Bytes())
+	}
+
+	// Frames too short to hold a TPDU are rejected
+	if _, err := framer.ReadFrame(bytes.NewReader([]byte{0x00, 0x03, 0x60, 0x00, 0x01})); err == nil {
+		t.Error("Expected a frame shorter than a TPDU to be rejected")
+	}
+
+	for _, tpdu := range []string{"60000100", "60000100zz"} {
+		if _, err := iso.NewFramer(iso.FramerConfig{TPDU: tpdu}); err == nil {
+			t.Errorf("Expected TPDU %q to be rejected", tpdu)
+		}
+	}
+}
+
+func TestFramerInvalidLengths(t *testing.T) {
+	framers := map[string]iso.Framer{}
+	for _, framing := range []string{iso.FramingBinary2, iso.FramingBinary2Inclusive, iso.FramingASCII4} {
+		framer, err := iso.NewFramer(iso.FramerConfig{Type: framing})
+		if err != nil {
+			t.Fatalf("%s: NewFramer failed: %v", framing, err)
+		}
+		framers[framing] = framer
+	}
+
+	// Truncated length prefixes and bodies
+	reads := []struct {
+		name    string
+		framing string
+		wire    []byte
+	}{
+		{"truncated binary prefix", iso.FramingBinary2, []byte{0x00}},
+		{"truncated binary body", iso.FramingBinary2, []byte{0x00, 0x0A, '0', '1', '0', '0'}},
+		{"inclusive length below the prefix size", iso.FramingBinary2Inclusive, []byte{0x00, 0x01}},
+		{"truncated inclusive body", iso.FramingBinary2Inclusive, []byte{0x00, 0x0A, '0', '1', '0', '0'}},
+		{"truncated ASCII prefix", iso.FramingASCII4, []byte("00")},
+		{"non-numeric ASCII prefix", iso.FramingASCII4, []byte("00A4")},
+		{"negative ASCII prefix", iso.FramingASCII4, []byte("-004")},
+		{"signed ASCII prefix", iso.FramingASCII4, []byte("+0040100")},
+		{"space-padded ASCII prefix", iso.FramingASCII4, []byte(" 0040100")},
+		{"truncated ASCII body", iso.FramingASCII4, []byte("00100100")},
+	}
+	for _, tc := range reads {
+		frame, err := framers[tc.framing].ReadFrame(bytes.NewReader(tc.wire))
+		if err == nil {
+			t.Errorf("%s: expected an error but read %q", tc.name, frame.Message)
+		} else if errors.Is(err, io.EOF) {
+			t.Errorf("%s: expected an error other than a clean EOF but got %v", tc.name, err)
+		}
+	}
+
+	// Messages too long for the length prefix
+	writes := []struct {
+		framing string
+		length  int
+	}{
+		{iso.FramingBinary2, 0x10000},
+		{iso.FramingBinary2Inclusive, 0xFFFE},
+		{iso.FramingASCII4, 10000},
+	}
+	for _, tc := range writes {
+		var wire bytes.Buffer
+		if err := framers[tc.framing].WriteFrame(&wire, []byte(strings.Repeat("0", tc.length)), nil); err == nil {
+			t.Errorf("%s: expected a %d-byte message to be rejected", tc.framing, tc.length)
+		}
+		if wire.Len() != 0 {
+			t.Errorf("%s: expected nothing to be written but got %d bytes", tc.framing, wire.Len())
+		}
+	}
+}
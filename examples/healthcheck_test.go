@@ -0,0 +1,81 @@
+package examples
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/issuer"
+	"github.com/TFMV/pulse/proto"
+	"github.com/TFMV/pulse/router"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthChecking(t *testing.T) {
+	for _, watch := range []bool{false, true} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		grpcServer := grpc.NewServer()
+		proto.RegisterAuthServiceServer(grpcServer, newUSEastIssuer(t))
+		healthServer := issuer.RegisterHealth(grpcServer)
+		go grpcServer.Serve(listener)
+		defer grpcServer.Stop()
+
+		rt := router.NewRouter(router.Config{
+			DefaultRegion: "us-east",
+			Regions: map[string]router.RegionConfig{
+				"us-east": {Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port, TimeoutMs: 5000},
+			},
+			HealthCheck: router.HealthCheckConfig{Interval: 20 * time.Millisecond, Watch: watch},
+		}, nil, nil, nil)
+		if err := rt.Initialize(); err != nil {
+			t.Fatalf("Failed to initialize router: %v", err)
+		}
+		defer rt.Close()
+
+		events, unsubscribe := rt.SubscribeCircuitEvents(4)
+		defer unsubscribe()
+
+		// The issuer starts draining
+		healthServer.SetServingStatus(proto.AuthService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+
+		select {
+		case event := <-events:
+			if event.To != router.CircuitOpen || event.Cause != router.CauseNotServing {
+				t.Errorf("Watch %v: unexpected circuit event %+v", watch, event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Watch %v: expected the circuit to open when the issuer stops serving", watch)
+		}
+
+		// The issuer serves again, and requests probe it
+		healthServer.SetServingStatus(proto.AuthService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+		select {
+		case event := <-events:
+			if event.To != router.CircuitHalfOpen || event.Cause != router.CauseServing {
+				t.Errorf("Watch %v: unexpected circuit event %+v", watch, event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Watch %v: expected the circuit to half-open when the issuer serves again", watch)
+		}
+	}
+}
+
+func TestUSEastIssuerWithoutPAN(t *testing.T) {
+	resp, err := newUSEastIssuer(t).ProcessAuth(context.Background(), &proto.AuthRequest{
+		Mti:    "0100",
+		Amount: "50",
+		Stan:   "000950",
+	})
+	if err != nil {
+		t.Fatalf("ProcessAuth failed: %v", err)
+	}
+	if resp.ResponseCode != "14" {
+		t.Errorf("Expected response code 14 for a missing PAN but got %s", resp.ResponseCode)
+	}
+}
@@ -0,0 +1,103 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+	"github.com/TFMV/pulse/router"
+	"github.com/TFMV/pulse/storage"
+)
+
+// slowIssuer delays authorizations while slow is set
+type slowIssuer struct {
+	proto.AuthServiceServer
+	slow atomic.Bool
+}
+
+func (s *slowIssuer) ProcessAuth(ctx context.Context, req *proto.AuthRequest) (*proto.AuthResponse, error) {
+	if s.slow.Load() && req.MessageClass == proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION {
+		time.Sleep(time.Second)
+	}
+	return s.AuthServiceServer.ProcessAuth(ctx, req)
+}
+
+func TestHedgedRequests(t *testing.T) {
+	slow := &slowIssuer{AuthServiceServer: newUSEastIssuer(t)}
+	euWest := &countingIssuer{AuthServiceServer: slow}
+
+	store := storage.NewMemoryStore()
+	rt := router.NewRouter(router.Config{
+		BinRoutes:     map[string]string{"4": "eu-west"},
+		DefaultRegion: "eu-west",
+		Regions: map[string]router.RegionConfig{
+			"eu-west": startIssuer(t, euWest),
+			"us-east": startIssuer(t, newUSEastIssuer(t)),
+		},
+		FailoverMap: map[string]string{"eu-west": "us-east"},
+		Hedging: router.HedgingConfig{
+			Bins:     []string{"411111"},
+			MinDelay: 20 * time.Millisecond,
+		},
+	}, nil, nil, store)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	authorize := func(pan string, stan int) string {
+		response, err := rt.HandleMessage(context.Background(), newMessage(t, map[int]string{
+			0:  "0100",
+			2:  pan,
+			4:  "50",
+			7:  "0102150405",
+			11: fmt.Sprintf("%06d", stan),
+		}))
+		if err != nil {
+			t.Fatalf("Transaction %d failed: %v", stan, err)
+		}
+		code, _ := response.GetString(39)
+		return code
+	}
+
+	// Learn the primary region's latency
+	for i := 0; i < 25; i++ {
+		authorize("4111111111111111", 600+i)
+	}
+
+	slow.slow.Store(true)
+
+	// BINs that are not hedged wait for the primary
+	authorize("4222222222222222", 650)
+	record := waitForRecord(t, store, storage.TransactionKey{Stan: "000650", TransmissionTime: "0102150405"})
+	if record.Region != "eu-west" {
+		t.Errorf("Expected a BIN that is not hedged to wait for eu-west but it was answered by %s", record.Region)
+	}
+
+	reversals := euWest.reversals.Load()
+	start := time.Now()
+	if code := authorize("4111111111111111", 651); code != "00" {
+		t.Fatalf("Expected the hedge to approve but got %s", code)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the hedge to answer before the slow primary but took %v", elapsed)
+	}
+
+	record = waitForRecord(t, store, storage.TransactionKey{Stan: "000651", TransmissionTime: "0102150405"})
+	if record.Region != "us-east" || record.PrimaryRegion != "eu-west" || record.FailoverHops != 1 {
+		t.Errorf("Expected the hedge winner to be stored but got region %s, primary %s, hops %d",
+			record.Region, record.PrimaryRegion, record.FailoverHops)
+	}
+
+	// The primary's approval of the losing call is reversed
+	deadline := time.Now().Add(2 * time.Second)
+	for euWest.reversals.Load() == reversals {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the losing region's authorization to be reversed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,237 @@
+package examples
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/issuer"
+	"github.com/TFMV/pulse/ledger"
+	"github.com/TFMV/pulse/proto"
+)
+
+func TestLedgerHoldsAndDeclines(t *testing.T) {
+	seedFile := filepath.Join(t.TempDir(), "accounts.yaml")
+	if err := os.WriteFile(seedFile, []byte(`
+accounts:
+  - id: "ACC-1"
+    balance: "300.00"
+    cards: ["4111111111111111"]
+`), 0o644); err != nil {
+		t.Fatalf("Failed to write seed file: %v", err)
+	}
+	accounts, err := ledger.NewLedger(ledger.Config{Enabled: true, SeedFile: seedFile})
+	if err != nil {
+		t.Fatalf("Failed to create ledger: %v", err)
+	}
+	usEast, err := issuer.NewRuleIssuer(builtinConfig(t, issuer.USEastConfig), nil, accounts)
+	if err != nil {
+		t.Fatalf("Failed to create issuer: %v", err)
+	}
+
+	send := func(req *proto.AuthRequest) string {
+		if req.TransmissionTime == "" {
+			req.TransmissionTime = "0102150405"
+		}
+		response, err := usEast.ProcessAuth(context.Background(), req)
+		if err != nil {
+			t.Fatalf("ProcessAuth failed: %v", err)
+		}
+		return response.ResponseCode
+	}
+	authorize := func(pan, amount, stan string) string {
+		return send(&proto.AuthRequest{Mti: "0100", Pan: pan, Amount: amount, Stan: stan,
+			MessageClass: proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION})
+	}
+	expectBalance := func(step string, ledgerBalance, available int64) {
+		balance, err := accounts.Balance("ACC-1")
+		if err != nil {
+			t.Fatalf("Balance failed: %v", err)
+		}
+		if balance.Ledger != ledgerBalance || balance.Available != available {
+			t.Errorf("%s: expected ledger %d and available %d but got %d and %d",
+				step, ledgerBalance, available, balance.Ledger, balance.Available)
+		}
+	}
+
+	// Authorizations hold funds until the available balance runs out
+	if code := authorize("4111111111111111", "200", "000001"); code != "00" {
+		t.Fatalf("Expected the first authorization to be approved but got %s", code)
+	}
+	expectBalance("after hold", 30000, 10000)
+	if code := authorize("4111111111111111", "150", "000002"); code != "51" {
+		t.Errorf("Expected insufficient funds but got %s", code)
+	}
+	if code := authorize("4111111111111112", "10", "000003"); code != "14" {
+		t.Errorf("Expected a card without an account to be declined with 14 but got %s", code)
+	}
+
+	// A completion converts the hold into a posting of the completed amount
+	send(&proto.AuthRequest{Mti: "0220", Pan: "4111111111111111", Amount: "180", Stan: "000004",
+		MessageClass: proto.MessageClass_MESSAGE_CLASS_COMPLETION,
+		OriginalData: &proto.OriginalData{Mti: "0100", Stan: "000001", TransmissionTime: "0102150405"}})
+	expectBalance("after completion", 12000, 12000)
+
+	// A reversal releases a hold or refunds captured funds
+	if code := authorize("4111111111111111", "100", "000005"); code != "00" {
+		t.Fatalf("Expected the authorization to be approved but got %s", code)
+	}
+	expectBalance("after second hold", 12000, 2000)
+
+	// Another day's authorization with the same STAN gets its own hold
+	if code := send(&proto.AuthRequest{Mti: "0100", Pan: "4111111111111111", Amount: "10", Stan: "000005",
+		TransmissionTime: "0103150405", MessageClass: proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION}); code != "00" {
+		t.Fatalf("Expected the authorization to be approved but got %s", code)
+	}
+	expectBalance("after reused STAN", 12000, 1000)
+
+	for _, reversal := range []struct{ stan, original, originalTime string }{
+		{"000006", "000005", "0102150405"},
+		{"000007", "000001", "0102150405"},
+	} {
+		send(&proto.AuthRequest{Mti: "0400", Pan: "4111111111111111", Amount: "0", Stan: reversal.stan,
+			MessageClass: proto.MessageClass_MESSAGE_CLASS_REVERSAL,
+			OriginalData: &proto.OriginalData{Mti: "0100", Stan: reversal.original, TransmissionTime: reversal.originalTime}})
+	}
+	expectBalance("after reversals", 30000, 29000)
+
+	// Every posting balances, so all accounts add up to zero
+	var total int64
+	for _, posting := range accounts.Postings() {
+		var sum int64
+		for _, entry := range posting.Entries {
+			sum += entry.Amount
+			total += entry.Amount
+		}
+		if sum != 0 {
+			t.Errorf("Expected posting %d (%s) to balance but it sums to %d", posting.ID, posting.Description, sum)
+		}
+	}
+	if total != 0 {
+		t.Errorf("Expected the ledger to balance but it sums to %d", total)
+	}
+}
+
+func TestLedgerHoldExpiry(t *testing.T) {
+	accounts, err := ledger.NewLedger(ledger.Config{Enabled: true, HoldTTL: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create ledger: %v", err)
+	}
+	if err := accounts.Open("ACC-1", []string{"4111111111111111"}, 10000); err != nil {
+		t.Fatalf("Failed to open account: %v", err)
+	}
+
+	if err := accounts.Hold("US-EAST", "4111111111111111", ledger.Reference{Stan: "000001"}, 10000, false); err != nil {
+		t.Fatalf("Expected the hold to succeed but got %v", err)
+	}
+	if err := accounts.Hold("US-EAST", "4111111111111111", ledger.Reference{Stan: "000002"}, 1, false); err != ledger.ErrInsufficientFunds {
+		t.Errorf("Expected insufficient funds while the hold is active but got %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if err := accounts.Hold("US-EAST", "4111111111111111", ledger.Reference{Stan: "000002"}, 10000, false); err != nil {
+		t.Errorf("Expected the expired hold to be released but got %v", err)
+	}
+}
+
+func TestLedgerSharedByIssuers(t *testing.T) {
+	accounts, err := ledger.NewLedger(ledger.Config{Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create ledger: %v", err)
+	}
+	if err := accounts.Open("ACC-1", []string{"4111111111111111"}, 30000); err != nil {
+		t.Fatalf("Failed to open account: %v", err)
+	}
+	usEast, err := issuer.NewRuleIssuer(builtinConfig(t, issuer.USEastConfig), nil, accounts)
+	if err != nil {
+		t.Fatalf("Failed to create issuer: %v", err)
+	}
+	euWest, err := issuer.NewRuleIssuer(builtinConfig(t, issuer.EUWestConfig), nil, accounts)
+	if err != nil {
+		t.Fatalf("Failed to create issuer: %v", err)
+	}
+
+	send := func(region *issuer.RuleIssuer, req *proto.AuthRequest) {
+		if _, err := region.ProcessAuth(context.Background(), req); err != nil {
+			t.Fatalf("ProcessAuth failed: %v", err)
+		}
+	}
+	expectAvailable := func(step string, available int64) {
+		balance, err := accounts.Balance("ACC-1")
+		if err != nil {
+			t.Fatalf("Balance failed: %v", err)
+		}
+		if balance.Available != available {
+			t.Errorf("%s: expected available %d but got %d", step, available, balance.Available)
+		}
+	}
+	authorization := &proto.AuthRequest{Mti: "0100", Pan: "4111111111111111", Amount: "100", Stan: "000001",
+		TransmissionTime: "0102150405", MessageClass: proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION}
+	reversal := &proto.AuthRequest{Mti: "0420", Pan: "4111111111111111", Amount: "100", Stan: "000001",
+		TransmissionTime: "0102150405", MessageClass: proto.MessageClass_MESSAGE_CLASS_REVERSAL,
+		OriginalData: &proto.OriginalData{Mti: "0100", Stan: "000001", TransmissionTime: "0102150405"}}
+
+	// A hedged authorization approved by both regions holds its funds once
+	send(usEast, authorization)
+	send(euWest, authorization)
+	expectAvailable("hedged authorization", 20000)
+
+	// Reversing the losing region leaves the winner's hold in place
+	send(euWest, reversal)
+	expectAvailable("hedge loser reversed", 20000)
+	send(euWest, reversal)
+	expectAvailable("hedge loser reversed again", 20000)
+	send(usEast, reversal)
+	expectAvailable("authorization reversed", 30000)
+
+	// A financial request approved by both regions is captured once
+	financial := &proto.AuthRequest{Mti: "0200", Pan: "4111111111111111", Amount: "50", Stan: "000002",
+		TransmissionTime: "0102160405", MessageClass: proto.MessageClass_MESSAGE_CLASS_FINANCIAL}
+	send(usEast, financial)
+	send(euWest, financial)
+	expectAvailable("hedged financial request", 25000)
+
+	// Shadow copies move no funds
+	shadow := &proto.AuthRequest{Mti: "0100", Pan: "4111111111111111", Amount: "100", Stan: "000003",
+		TransmissionTime: "0102170405", MessageClass: proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION, Shadow: true}
+	send(euWest, shadow)
+	expectAvailable("shadow authorization", 25000)
+}
+
+func TestLedgerStandInCompletion(t *testing.T) {
+	accounts, err := ledger.NewLedger(ledger.Config{Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create ledger: %v", err)
+	}
+	if err := accounts.Open("ACC-1", []string{"4111111111111111"}, 30000); err != nil {
+		t.Fatalf("Failed to open account: %v", err)
+	}
+	usEast, err := issuer.NewRuleIssuer(builtinConfig(t, issuer.USEastConfig), nil, accounts)
+	if err != nil {
+		t.Fatalf("Failed to create issuer: %v", err)
+	}
+
+	for _, req := range []*proto.AuthRequest{
+		{Mti: "0100", Pan: "4111111111111111", Amount: "200", Stan: "000001", TransmissionTime: "0102150405",
+			MessageClass: proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION},
+		// The completion was stood in while the issuer was unavailable
+		{Mti: "0120", Pan: "4111111111111111", Amount: "180", Stan: "000002", TransmissionTime: "0102160405",
+			MessageClass: proto.MessageClass_MESSAGE_CLASS_COMPLETION, StandInResponseCode: "00",
+			OriginalData: &proto.OriginalData{Mti: "0100", Stan: "000001", TransmissionTime: "0102150405"}},
+	} {
+		if _, err := usEast.ProcessAuth(context.Background(), req); err != nil {
+			t.Fatalf("ProcessAuth failed: %v", err)
+		}
+	}
+
+	// The completion advice converts the pre-authorization hold
+	balance, err := accounts.Balance("ACC-1")
+	if err != nil {
+		t.Fatalf("Balance failed: %v", err)
+	}
+	if balance.Ledger != 12000 || balance.Available != 12000 || balance.Holds != 0 {
+		t.Errorf("Expected ledger and available balances of 12000 without holds but got %+v", balance)
+	}
+}
@@ -0,0 +1,117 @@
+package examples
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TFMV/pulse/iso"
+	"github.com/TFMV/pulse/router"
+	"github.com/moov-io/iso8583"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	usEast := &callCountingIssuer{AuthServiceServer: newUSEastIssuer(t)}
+
+	rt := router.NewRouter(router.Config{
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east": startIssuer(t, usEast),
+		},
+		Middleware: []string{"chaos", "prescreen", "duplicates", "parse", "enrich", "health", "metrics", "storage"},
+	}, nil, nil, nil)
+
+	// Decline blocked cards before they reach the issuer
+	rt.RegisterMiddleware("prescreen", func(next iso.MessageHandler) iso.MessageHandler {
+		return iso.MessageHandlerFunc(func(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+			if pan, _ := message.GetString(2); pan == "4999999999999999" {
+				return iso.NewResponse(message, "62")
+			}
+			return next.HandleMessage(ctx, message)
+		})
+	})
+
+	// See the parsed request and the region that answered it
+	var parsedStan, answeredBy string
+	rt.RegisterMiddleware("enrich", func(next iso.MessageHandler) iso.MessageHandler {
+		return iso.MessageHandlerFunc(func(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+			exchange, _ := router.ExchangeFromContext(ctx)
+			parsedStan = exchange.Request.GetStan()
+			response, err := next.HandleMessage(ctx, message)
+			answeredBy = exchange.Region
+			return response, err
+		})
+	})
+
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	authorize := func(pan, stan string) string {
+		response, err := rt.HandleMessage(context.Background(), newMessage(t, map[int]string{
+			0:  "0100",
+			2:  pan,
+			4:  "50",
+			7:  "0102150405",
+			11: stan,
+		}))
+		if err != nil {
+			t.Fatalf("HandleMessage failed: %v", err)
+		}
+		code, _ := response.GetString(39)
+		return code
+	}
+
+	if code := authorize("4999999999999999", "000501"); code != "62" || usEast.calls.Load() != 0 {
+		t.Errorf("Expected the prescreen to decline with 62 before the issuer but got %s after %d calls",
+			code, usEast.calls.Load())
+	}
+
+	if code := authorize("4111111111111111", "000502"); code != "00" {
+		t.Errorf("Expected approval but got %s", code)
+	}
+	if parsedStan != "000502" || answeredBy != "us-east" {
+		t.Errorf("Expected the middleware to see STAN 000502 answered by us-east but got %q and %q",
+			parsedStan, answeredBy)
+	}
+}
+
+func TestInvalidMiddleware(t *testing.T) {
+	chains := map[string][]string{
+		"an unregistered middleware": {"parse", "fraud_score", "health"},
+		"a chain without health":     {"parse", "metrics"},
+	}
+	for name, chain := range chains {
+		rt := router.NewRouter(router.Config{
+			DefaultRegion: "us-east",
+			Regions: map[string]router.RegionConfig{
+				"us-east": {Host: "127.0.0.1", Port: 1},
+			},
+			Middleware: chain,
+		}, nil, nil, nil)
+		if err := rt.Initialize(); err == nil {
+			rt.Close()
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestUninitializedRouter(t *testing.T) {
+	rt := router.NewRouter(router.Config{
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east": {Host: "127.0.0.1", Port: 1},
+		},
+	}, nil, nil, nil)
+
+	_, err := rt.HandleMessage(context.Background(), newMessage(t, map[int]string{
+		0:  "0100",
+		2:  "4111111111111111",
+		4:  "50",
+		7:  "0102150405",
+		11: "000801",
+	}))
+	if iso.ErrorClass(err) != iso.ErrorSystem {
+		t.Errorf("Expected a system error before the router is initialized but got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package examples
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TFMV/pulse/iso"
+	"github.com/moov-io/iso8583"
+)
+
+// approveHandler approves every message it receives
+type approveHandler struct{}
+
+func (approveHandler) HandleMessage(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+	return iso.NewResponse(message, "00")
+}
+
+func TestNetworkManagement(t *testing.T) {
+	handler := iso.NewNetworkHandler(iso.NetworkConfig{RequireSignOn: true}, approveHandler{})
+	session := &iso.Session{}
+	ctx := iso.ContextWithSession(context.Background(), session)
+
+	auth := newMessage(t, map[int]string{0: "0100", 2: "4111111111111111", 11: "000001"})
+
+	// Financial traffic is rejected before sign-on
+	if _, err := handler.HandleMessage(ctx, auth); iso.ErrorClass(err) != iso.ErrorNotSignedOn {
+		t.Fatalf("Expected a %s error before sign-on but got %v", iso.ErrorNotSignedOn, err)
+	}
+
+	// Echo tests are answered without a sign-on
+	echo := newMessage(t, map[int]string{0: "0800", 11: "000002", 70: iso.NetworkEcho})
+	response, err := handler.HandleMessage(ctx, echo)
+	if err != nil {
+		t.Fatalf("Echo failed: %v", err)
+	}
+	if mti, _ := response.GetString(0); mti != "0810" {
+		t.Errorf("Expected MTI 0810 but got %s", mti)
+	}
+	if code, _ := response.GetString(70); code != iso.NetworkEcho {
+		t.Errorf("Expected field 70 %s but got %s", iso.NetworkEcho, code)
+	}
+
+	signOn := newMessage(t, map[int]string{0: "0800", 11: "000003", 70: iso.NetworkSignOn})
+	if _, err := handler.HandleMessage(ctx, signOn); err != nil {
+		t.Fatalf("Sign-on failed: %v", err)
+	}
+	if !session.SignedOn() {
+		t.Fatal("Expected the session to be signed on")
+	}
+
+	response, err = handler.HandleMessage(ctx, auth)
+	if err != nil {
+		t.Fatalf("Authorization after sign-on failed: %v", err)
+	}
+	if code, _ := response.GetString(39); code != "00" {
+		t.Errorf("Expected response code 00 but got %s", code)
+	}
+
+	cutover := newMessage(t, map[int]string{0: "0800", 11: "000004", 15: "1231", 70: iso.NetworkCutover})
+	if _, err := handler.HandleMessage(ctx, cutover); err != nil {
+		t.Fatalf("Cutover failed: %v", err)
+	}
+	if date := handler.BusinessDate(); date != "1231" {
+		t.Errorf("Expected business date 1231 but got %s", date)
+	}
+
+	signOff := newMessage(t, map[int]string{0: "0800", 11: "000005", 70: iso.NetworkSignOff})
+	if _, err := handler.HandleMessage(ctx, signOff); err != nil {
+		t.Fatalf("Sign-off failed: %v", err)
+	}
+	if _, err := handler.HandleMessage(ctx, auth); iso.ErrorClass(err) != iso.ErrorNotSignedOn {
+		t.Errorf("Expected a %s error after sign-off but got %v", iso.ErrorNotSignedOn, err)
+	}
+}
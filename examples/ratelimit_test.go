@@ -0,0 +1,133 @@
+package examples
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/router"
+)
+
+func TestRateLimits(t *testing.T) {
+	usEast := &callCountingIssuer{AuthServiceServer: newUSEastIssuer(t)}
+
+	rt := router.NewRouter(router.Config{
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east": startIssuer(t, usEast),
+		},
+		RateLimits: router.RateLimitConfig{
+			Rules: []router.RateLimitRule{
+				{Key: router.RateLimitByTerminal, Rate: 0.1, Burst: 2},
+			},
+		},
+		Duplicates: router.DuplicateConfig{Enabled: true, TTL: time.Minute},
+	}, nil, nil, nil)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	authorize := func(terminal, stan string) string {
+		response, err := rt.HandleMessage(context.Background(), newMessage(t, map[int]string{
+			0:  "0100",
+			2:  "4111111111111111",
+			4:  "50",
+			7:  "0102150405",
+			11: stan,
+			41: terminal,
+		}))
+		if err != nil {
+			t.Fatalf("HandleMessage failed: %v", err)
+		}
+		code, _ := response.GetString(39)
+		return code
+	}
+
+	// The burst of TERM0001 allows two requests
+	for _, stan := range []string{"000601", "000602"} {
+		if code := authorize("TERM0001", stan); code != "00" {
+			t.Fatalf("Expected approval within the burst but got %s", code)
+		}
+	}
+	if code := authorize("TERM0001", "000603"); code != "91" {
+		t.Errorf("Expected the request over the limit to be declined with 91 but got %s", code)
+	}
+	if calls := usEast.calls.Load(); calls != 2 {
+		t.Errorf("Expected the declined request not to reach the issuer but got %d calls", calls)
+	}
+
+	// A retransmission of an answered request gets the original response
+	// although the terminal's limit is exhausted
+	if code := authorize("TERM0001", "000601"); code != "00" {
+		t.Errorf("Expected the retransmission to replay the approval but got %s", code)
+	}
+	if calls := usEast.calls.Load(); calls != 2 {
+		t.Errorf("Expected the retransmission not to reach the issuer but got %d calls", calls)
+	}
+
+	// Other terminals have their own limit
+	if code := authorize("TERM0002", "000604"); code != "00" {
+		t.Errorf("Expected approval for another terminal but got %s", code)
+	}
+}
+
+func TestLoadShedding(t *testing.T) {
+	slow := &slowIssuer{AuthServiceServer: newUSEastIssuer(t)}
+	usEast := &callCountingIssuer{AuthServiceServer: slow}
+
+	rt := router.NewRouter(router.Config{
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east": startIssuer(t, usEast),
+		},
+		LoadShedding: router.LoadSheddingConfig{MaxInFlight: 1},
+	}, nil, nil, nil)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	authorize := func(stan string) string {
+		response, err := rt.HandleMessage(context.Background(), newMessage(t, map[int]string{
+			0:  "0100",
+			2:  "4111111111111111",
+			4:  "50",
+			7:  "0102150405",
+			11: stan,
+		}))
+		if err != nil {
+			t.Errorf("HandleMessage failed: %v", err)
+			return ""
+		}
+		code, _ := response.GetString(39)
+		return code
+	}
+
+	// Hold the only in-flight slot with a slow authorization
+	slow.slow.Store(true)
+	done := make(chan string)
+	go func() { done <- authorize("000611") }()
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	if code := authorize("000612"); code != "91" {
+		t.Errorf("Expected the request to be shed with 91 but got %s", code)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the shed request to be answered at once but it took %v", elapsed)
+	}
+
+	if code := <-done; code != "00" {
+		t.Errorf("Expected the slow authorization to be approved but got %s", code)
+	}
+	if calls := usEast.calls.Load(); calls != 1 {
+		t.Errorf("Expected the shed request not to reach the issuer but got %d calls", calls)
+	}
+
+	// Requests are accepted again once the slot is free
+	slow.slow.Store(false)
+	if code := authorize("000613"); code != "00" {
+		t.Errorf("Expected approval after the load dropped but got %s", code)
+	}
+}
@@ -0,0 +1,74 @@
+package examples
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TFMV/pulse/router"
+)
+
+func TestRouterReload(t *testing.T) {
+	usEast := &callCountingIssuer{AuthServiceServer: newUSEastIssuer(t)}
+	euWest := &callCountingIssuer{AuthServiceServer: newEUWestIssuer(t)}
+	usEastRegion := startIssuer(t, usEast)
+	euWestRegion := startIssuer(t, euWest)
+
+	rt := router.NewRouter(router.Config{
+		BinRoutes:     map[string]string{"4": "us-east"},
+		DefaultRegion: "us-east",
+		Regions:       map[string]router.RegionConfig{"us-east": usEastRegion},
+	}, nil, nil, nil)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	authorize := func(stan string) string {
+		response, err := rt.HandleMessage(context.Background(), newMessage(t, map[int]string{
+			0:  "0100",
+			2:  "4111111111111111",
+			4:  "50",
+			7:  "0102150405",
+			11: stan,
+		}))
+		if err != nil {
+			t.Fatalf("Authorization %s failed: %v", stan, err)
+		}
+		code, _ := response.GetString(39)
+		return code
+	}
+
+	if code := authorize("000501"); code != "00" || usEast.calls.Load() != 1 {
+		t.Fatalf("Expected approval by us-east but got %s with %d us-east calls", code, usEast.calls.Load())
+	}
+
+	// Invalid configurations are rejected and the running one is kept
+	invalid := router.Config{
+		BinRoutes:     map[string]string{"4": "ap-south"},
+		DefaultRegion: "us-east",
+		Regions:       map[string]router.RegionConfig{"us-east": usEastRegion},
+	}
+	if err := rt.Reload(invalid); err == nil {
+		t.Fatal("Expected a route to an unknown region to be rejected")
+	}
+	if code := authorize("000502"); code != "00" || usEast.calls.Load() != 2 {
+		t.Fatalf("Expected us-east to keep routing but got %s with %d us-east calls", code, usEast.calls.Load())
+	}
+
+	// Move the BIN to a new region and remove the old one
+	err := rt.Reload(router.Config{
+		BinRoutes:     map[string]string{"4": "eu-west"},
+		DefaultRegion: "eu-west",
+		Regions:       map[string]router.RegionConfig{"eu-west": euWestRegion},
+	})
+	if err != nil {
+		t.Fatalf("Failed to reload router: %v", err)
+	}
+	if code := authorize("000503"); code != "00" {
+		t.Fatalf("Expected approval after reload but got %s", code)
+	}
+	if usEast.calls.Load() != 2 || euWest.calls.Load() != 1 {
+		t.Errorf("Expected the reloaded BIN to reach eu-west only but got %d us-east and %d eu-west calls",
+			usEast.calls.Load(), euWest.calls.Load())
+	}
+}
@@ -0,0 +1,136 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+	"github.com/TFMV/pulse/router"
+	"github.com/TFMV/pulse/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unavailableIssuer fails the first failures calls with UNAVAILABLE
+type unavailableIssuer struct {
+	proto.AuthServiceServer
+	failures int32
+	calls    atomic.Int32
+}
+
+func (u *unavailableIssuer) ProcessAuth(ctx context.Context, req *proto.AuthRequest) (*proto.AuthResponse, error) {
+	if u.calls.Add(1) <= u.failures {
+		return nil, status.Error(codes.Unavailable, "issuer restarting")
+	}
+	return u.AuthServiceServer.ProcessAuth(ctx, req)
+}
+
+func TestRetries(t *testing.T) {
+	flaky := &unavailableIssuer{AuthServiceServer: newUSEastIssuer(t), failures: 1}
+	down := &unavailableIssuer{AuthServiceServer: newUSEastIssuer(t), failures: 1000}
+
+	store := storage.NewMemoryStore()
+	rt := router.NewRouter(router.Config{
+		BinRoutes:     map[string]string{"4": "us-east", "5": "us-west"},
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east": startIssuer(t, flaky),
+			"us-west": startIssuer(t, down),
+			"eu-west": startIssuer(t, newEUWestIssuer(t)),
+		},
+		FailoverMap: map[string]string{"us-west": "eu-west"},
+		Retry:       router.RetryConfig{MaxAttempts: 3},
+	}, nil, nil, store)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	authorize := func(pan, stan string) (string, error) {
+		response, err := rt.HandleMessage(context.Background(), newMessage(t, map[int]string{
+			0:  "0100",
+			2:  pan,
+			4:  "50",
+			7:  "0102150405",
+			11: stan,
+		}))
+		if err != nil {
+			return "", err
+		}
+		code, _ := response.GetString(39)
+		return code, nil
+	}
+
+	// A transient failure is retried in the same region
+	if code, err := authorize("4111111111111111", "000401"); err != nil || code != "00" {
+		t.Fatalf("Expected the retry to approve but got %q, %v", code, err)
+	}
+	if calls := flaky.calls.Load(); calls != 2 {
+		t.Errorf("Expected 2 calls to us-east but got %d", calls)
+	}
+
+	// A region that stays unavailable is retried in its failover region
+	if code, err := authorize("5111111111111111", "000402"); err != nil || code != "00" {
+		t.Fatalf("Expected the failover region to approve the retry but got %q, %v", code, err)
+	}
+	record := waitForRecord(t, store, storage.TransactionKey{Stan: "000402", TransmissionTime: "0102150405"})
+	if record.Region != "eu-west" || record.PrimaryRegion != "us-west" || record.FailoverHops != 1 {
+		t.Errorf("Expected the retry to be stored for eu-west but got region %s, primary %s, hops %d",
+			record.Region, record.PrimaryRegion, record.FailoverHops)
+	}
+}
+
+func TestRetryBudgetAndDeadline(t *testing.T) {
+	down := &unavailableIssuer{AuthServiceServer: newUSEastIssuer(t), failures: 1000}
+
+	rt := router.NewRouter(router.Config{
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east": startIssuer(t, down),
+		},
+		Retry: router.RetryConfig{
+			MaxAttempts: 3,
+			Backoff:     500 * time.Millisecond,
+			BudgetRatio: 0.01,
+			BudgetMax:   1,
+		},
+	}, nil, nil, nil)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	authorize := func(ctx context.Context, i int) error {
+		_, err := rt.HandleMessage(ctx, newMessage(t, map[int]string{
+			0:  "0100",
+			2:  "4111111111111111",
+			4:  "50",
+			7:  "0102150405",
+			11: fmt.Sprintf("%06d", 410+i),
+		}))
+		return err
+	}
+
+	// A deadline shorter than the backoff leaves no time to retry
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := authorize(ctx, 0); err == nil {
+		t.Fatal("Expected the unavailable issuer to fail the request")
+	}
+	if calls := down.calls.Load(); calls != 1 {
+		t.Fatalf("Expected no retry past the deadline but got %d calls", calls)
+	}
+
+	// The budget holds a single retry
+	for i := 1; i <= 2; i++ {
+		if err := authorize(context.Background(), i); err == nil {
+			t.Fatal("Expected the unavailable issuer to fail the request")
+		}
+	}
+	if calls := down.calls.Load(); calls != 4 {
+		t.Errorf("Expected 1 retry within the budget and 4 calls in total but got %d calls", calls)
+	}
+}
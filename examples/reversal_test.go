@@ -0,0 +1,157 @@
+package examples
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/issuer"
+	"github.com/TFMV/pulse/proto"
+	"github.com/TFMV/pulse/router"
+	"github.com/TFMV/pulse/storage"
+	"google.golang.org/grpc"
+)
+
+// countingIssuer counts the reversals that reach the issuer
+type countingIssuer struct {
+	proto.AuthServiceServer
+	reversals atomic.Int32
+}
+
+func (c *countingIssuer) ProcessAuth(ctx context.Context, req *proto.AuthRequest) (*proto.AuthResponse, error) {
+	if req.MessageClass == proto.MessageClass_MESSAGE_CLASS_REVERSAL {
+		c.reversals.Add(1)
+	}
+	return c.AuthServiceServer.ProcessAuth(ctx, req)
+}
+
+// startIssuer serves an issuer on a local port and returns its router region config
+func startIssuer(t *testing.T, server proto.AuthServiceServer) router.RegionConfig {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	proto.RegisterAuthServiceServer(grpcServer, server)
+	issuer.RegisterHealth(grpcServer)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return router.RegionConfig{Host: "127.0.0.1", Port: addr.Port, TimeoutMs: 5000}
+}
+
+func TestReversalRoutesToApprovingRegion(t *testing.T) {
+	usEast := &countingIssuer{AuthServiceServer: newUSEastIssuer(t)}
+	euWest := &countingIssuer{AuthServiceServer: newEUWestIssuer(t)}
+
+	store := storage.NewMemoryStore()
+	rt := router.NewRouter(router.Config{
+		BinRoutes:     map[string]string{"4": "us-east", "5": "eu-west"},
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east": startIssuer(t, usEast),
+			"eu-west": startIssuer(t, euWest),
+		},
+	}, nil, nil, store)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	// Two terminals authorize with the same STAN and transmission time, one
+	// in each region
+	ctx := context.Background()
+	for _, auth := range []struct{ pan, terminalID string }{
+		{"4111111111111111", "TERM0001"},
+		{"5555555555554444", "TERM0002"},
+	} {
+		response, err := rt.HandleMessage(ctx, newMessage(t, map[int]string{
+			0:  "0100",
+			2:  auth.pan,
+			4:  "50",
+			7:  "0102150405",
+			11: "000101",
+			41: auth.terminalID,
+		}))
+		if err != nil {
+			t.Fatalf("Authorization from %s failed: %v", auth.terminalID, err)
+		}
+		if code, _ := response.GetString(39); code != "00" {
+			t.Fatalf("Expected the authorization from %s to be approved but got %s", auth.terminalID, code)
+		}
+	}
+
+	// The authorizations are stored asynchronously
+	original := storage.TransactionKey{Stan: "000101", TransmissionTime: "0102150405", TerminalID: "TERM0001"}
+	other := storage.TransactionKey{Stan: "000101", TransmissionTime: "0102150405", TerminalID: "TERM0002"}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		first, _ := store.GetTransaction(ctx, original)
+		second, _ := store.GetTransaction(ctx, other)
+		if first != nil && second != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Authorizations were not stored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Send the same reversal advice twice; only the first reaches the issuer
+	for i, stan := range []string{"000102", "000103"} {
+		reversal := newMessage(t, map[int]string{
+			0:  "0420",
+			2:  "4111111111111111",
+			4:  "50",
+			7:  "0102150410",
+			11: stan,
+			41: "TERM0001",
+			90: "010000010101021504050000000000000000000000",
+		})
+		response, err := rt.HandleMessage(ctx, reversal)
+		if err != nil {
+			t.Fatalf("Reversal %d failed: %v", i+1, err)
+		}
+		if mti, _ := response.GetString(0); mti != "0430" {
+			t.Errorf("Expected MTI 0430 but got %s", mti)
+		}
+		if code, _ := response.GetString(39); code != "00" {
+			t.Errorf("Expected reversal %d to be acknowledged but got %s", i+1, code)
+		}
+	}
+
+	if got := usEast.reversals.Load(); got != 1 {
+		t.Errorf("Expected 1 reversal at us-east but got %d", got)
+	}
+	if got := euWest.reversals.Load(); got != 0 {
+		t.Errorf("Expected no reversals at eu-west but got %d", got)
+	}
+
+	if record, _ := store.GetTransaction(ctx, original); !record.Reversed {
+		t.Error("Expected the original to be marked reversed")
+	}
+	if record, _ := store.GetTransaction(ctx, other); record.Reversed {
+		t.Error("Expected the authorization from the other terminal not to be marked reversed")
+	}
+
+	// A reversal for an unknown original cannot be matched
+	unknown := newMessage(t, map[int]string{
+		0:  "0400",
+		7:  "0102150410",
+		11: "000104",
+		90: "010099999901021504050000000000000000000000",
+	})
+	response, err := rt.HandleMessage(ctx, unknown)
+	if err != nil {
+		t.Fatalf("Unknown reversal failed: %v", err)
+	}
+	if code, _ := response.GetString(39); code != "25" {
+		t.Errorf("Expected response code 25 for an unknown original but got %s", code)
+	}
+}
@@ -0,0 +1,147 @@
+package examples
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TFMV/pulse/issuer"
+	"github.com/TFMV/pulse/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// builtinConfig loads the rule configuration of a built-in issuer
+func builtinConfig(t *testing.T, load func() (issuer.RuleIssuerConfig, error)) issuer.RuleIssuerConfig {
+	t.Helper()
+
+	config, err := load()
+	if err != nil {
+		t.Fatalf("Failed to load built-in issuer rules: %v", err)
+	}
+	return config
+}
+
+// newUSEastIssuer creates the built-in US East issuer
+func newUSEastIssuer(t *testing.T) *issuer.RuleIssuer {
+	t.Helper()
+
+	usEast, err := issuer.NewUSEastIssuer()
+	if err != nil {
+		t.Fatalf("Failed to create US East issuer: %v", err)
+	}
+	return usEast
+}
+
+// newEUWestIssuer creates the built-in EU West issuer
+func newEUWestIssuer(t *testing.T) *issuer.RuleIssuer {
+	t.Helper()
+
+	euWest, err := issuer.NewEUWestIssuer()
+	if err != nil {
+		t.Fatalf("Failed to create EU West issuer: %v", err)
+	}
+	return euWest
+}
+
+func TestBuiltInIssuerRules(t *testing.T) {
+	usEast, euWest := newUSEastIssuer(t), newEUWestIssuer(t)
+
+	cases := []struct {
+		name   string
+		issuer proto.AuthServiceServer
+		pan    string
+		amount string
+		time   string
+		code   string
+	}{
+		{"us-east approval", usEast, "4111111111111111", "50", "0102150405", "00"},
+		{"us-east over limit", usEast, "4111111111111111", "550", "0102150405", "05"},
+		{"us-east PAN ending in 0", usEast, "4111111111111110", "50", "0102150405", "14"},
+		{"us-east invalid amount", usEast, "4111111111111111", "abc", "0102150405", "12"},
+		{"eu-west approval", euWest, "5555555555554444", "100", "0102150405", "00"},
+		{"eu-west over limit", euWest, "5555555555554444", "450", "0102150405", "05"},
+		{"eu-west night fraud", euWest, "5555555555554444", "250", "0102230405", "59"},
+		{"eu-west night fraud over limit", euWest, "5555555555554444", "450", "0102020405", "59"},
+		{"eu-west small night amount", euWest, "5555555555554444", "150", "0102020405", "00"},
+	}
+
+	for _, tc := range cases {
+		response, err := tc.issuer.ProcessAuth(context.Background(), &proto.AuthRequest{
+			Mti:              "0100",
+			Pan:              tc.pan,
+			Amount:           tc.amount,
+			TransmissionTime: tc.time,
+			Stan:             "000001",
+			MessageClass:     proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION,
+		})
+		if err != nil {
+			t.Fatalf("%s: ProcessAuth failed: %v", tc.name, err)
+		}
+		if response.ResponseCode != tc.code {
+			t.Errorf("%s: expected response code %s but got %s", tc.name, tc.code, response.ResponseCode)
+		}
+	}
+}
+
+func TestConfiguredIssuerRules(t *testing.T) {
+	var config issuer.RuleIssuerConfig
+	if err := yaml.Unmarshal([]byte(`
+name: "AP-SOUTH"
+currency: "₹"
+rules:
+  - name: "blocked_bin"
+    when: [{field: "pan", op: "prefix", value: "6011"}]
+    response_code: "62"
+    reason: "restricted BIN"
+  - name: "financial_limit"
+    when:
+      - {field: "message_class", op: "eq", value: "financial"}
+      - {field: "amount", op: "gte", value: 1000}
+    response_code: "61"
+    reason: "financial amount limit"
+`), &config); err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	apSouth, err := issuer.NewRuleIssuer(config, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create issuer: %v", err)
+	}
+
+	cases := []struct {
+		pan    string
+		mti    string
+		amount string
+		code   string
+	}{
+		{"6011000000000004", "0100", "10", "62"},
+		{"4111111111111111", "0200", "1000", "61"},
+		{"4111111111111111", "0100", "1000", "00"},
+		{"4111111111111111", "0200", "999.99", "00"},
+	}
+	for _, tc := range cases {
+		class := proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION
+		if tc.mti == "0200" {
+			class = proto.MessageClass_MESSAGE_CLASS_FINANCIAL
+		}
+		response, err := apSouth.ProcessAuth(context.Background(), &proto.AuthRequest{
+			Mti:              tc.mti,
+			Pan:              tc.pan,
+			Amount:           tc.amount,
+			TransmissionTime: "0102150405",
+			Stan:             "000002",
+			MessageClass:     class,
+		})
+		if err != nil {
+			t.Fatalf("ProcessAuth failed: %v", err)
+		}
+		if response.ResponseCode != tc.code {
+			t.Errorf("%s %s for %s: expected response code %s but got %s",
+				tc.mti, tc.pan, tc.amount, tc.code, response.ResponseCode)
+		}
+	}
+
+	// Rules are checked when the issuer is created
+	config.Rules[1].When[0].Op = "gt"
+	if _, err := issuer.NewRuleIssuer(config, nil, nil); err == nil {
+		t.Error("Expected a numeric operator on a string field to be rejected")
+	}
+}
@@ -0,0 +1,218 @@
+package examples
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/client"
+	"github.com/TFMV/pulse/iso"
+	"github.com/TFMV/pulse/spec"
+	"github.com/moov-io/iso8583"
+)
+
+// amountDelayHandler approves every message after as many milliseconds as its
+// amount, so requests with smaller amounts are answered first
+type amountDelayHandler struct{}
+
+func (amountDelayHandler) HandleMessage(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+	amount, _ := message.GetString(4)
+	delay, _ := strconv.Atoi(amount)
+	time.Sleep(time.Duration(delay) * time.Millisecond)
+	return iso.NewResponse(message, "00")
+}
+
+// delayedHandler approves every message after a delay, signalling each
+// message it starts on
+type delayedHandler struct {
+	delay   time.Duration
+	started chan struct{}
+}
+
+func (h *delayedHandler) HandleMessage(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+	h.started <- struct{}{}
+	time.Sleep(h.delay)
+	return iso.NewResponse(message, "00")
+}
+
+// gatedHandler approves every message once it is let through the gate
+type gatedHandler struct {
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func (h *gatedHandler) HandleMessage(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+	h.started <- struct{}{}
+	<-h.gate
+	return iso.NewResponse(message, "00")
+}
+
+func newMessage(t *testing.T, fields map[int]string) *iso8583.Message {
+	t.Helper()
+
+	message := iso8583.NewMessage(spec.Default())
+	for id, value := range fields {
+		if err := message.Field(id, value); err != nil {
+			t.Fatalf("Failed to set field %d: %v", id, err)
+		}
+	}
+	return message
+}
+
+// startISOServer serves ISO8583 messages on a local port and returns the
+// server and its address
+func startISOServer(t *testing.T, config iso.ServerConfig, handler iso.MessageHandler) (*iso.Server, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	if config.Spec == nil {
+		config.Spec = spec.Default()
+	}
+
+	server := iso.NewServer(config, handler)
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	})
+	return server, listener.Addr().String()
+}
+
+// connectClient connects an ISO8583 client to addr
+func connectClient(t *testing.T, config client.Config) *client.Client {
+	t.Helper()
+
+	if config.Spec == nil {
+		config.Spec = spec.Default()
+	}
+	c := client.NewClient(config)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestPipelinedResponsesMatchRequests(t *testing.T) {
+	_, addr := startISOServer(t, iso.ServerConfig{}, amountDelayHandler{})
+	c := connectClient(t, client.Config{ServerAddr: addr})
+
+	// The same STAN from two terminals, and the slowest request sent first
+	requests := []struct{ stan, terminalID, amount string }{
+		{"000001", "TERM0001", "300"},
+		{"000001", "TERM0002", "100"},
+		{"000002", "TERM0001", "200"},
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, request := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := c.Send(newMessage(t, map[int]string{
+				0:  "0100",
+				2:  "4111111111111111",
+				4:  request.amount,
+				7:  "0102150405",
+				11: request.stan,
+				41: request.terminalID,
+			}))
+			if err != nil {
+				t.Errorf("Request %s/%s failed: %v", request.stan, request.terminalID, err)
+				return
+			}
+			stan, _ := response.GetString(11)
+			terminalID, _ := response.GetString(41)
+			amount, _ := response.GetString(4)
+			if stan != request.stan || terminalID != request.terminalID || amount != request.amount {
+				t.Errorf("Request %s/%s for %s got the response %s/%s for %s",
+					request.stan, request.terminalID, request.amount, stan, terminalID, amount)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The requests were processed concurrently, not one after another
+	if elapsed := time.Since(start); elapsed > 550*time.Millisecond {
+		t.Errorf("Expected pipelined requests to be processed concurrently but they took %v", elapsed)
+	}
+}
+
+func TestMaxInFlightBlocksReader(t *testing.T) {
+	handler := &gatedHandler{started: make(chan struct{}, 2), gate: make(chan struct{})}
+	server, addr := startISOServer(t, iso.ServerConfig{MaxInFlight: 1}, handler)
+	c := connectClient(t, client.Config{ServerAddr: addr})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.SendAuthRequest("4111111111111111", "50"); err != nil {
+				t.Errorf("Request failed: %v", err)
+			}
+		}()
+	}
+
+	// The second message waits until the first is answered
+	<-handler.started
+	select {
+	case <-handler.started:
+		t.Fatal("Expected the second message to wait for the in-flight limit")
+	case <-time.After(200 * time.Millisecond):
+	}
+	stats := server.Stats()
+	if len(stats.Connections) != 1 || stats.Connections[0].InFlight != 1 || stats.Connections[0].MessagesReceived != 1 {
+		t.Errorf("Expected one message in flight and the reader blocked but got %+v", stats.Connections)
+	}
+
+	handler.gate <- struct{}{}
+	<-handler.started
+	handler.gate <- struct{}{}
+	wg.Wait()
+}
+
+func TestShutdownDrainsInFlightMessages(t *testing.T) {
+	// Shutting down right after a message is read races the read loop
+	// setting its next deadline, so repeat it
+	for i := 0; i < 10; i++ {
+		handler := &delayedHandler{delay: 50 * time.Millisecond, started: make(chan struct{}, 1)}
+		server, addr := startISOServer(t, iso.ServerConfig{}, handler)
+		c := connectClient(t, client.Config{ServerAddr: addr})
+
+		responses := make(chan string, 1)
+		go func() {
+			response, err := c.SendAuthRequest("4111111111111111", "50")
+			if err != nil {
+				t.Errorf("Request failed: %v", err)
+				responses <- ""
+				return
+			}
+			code, _ := response.GetString(39)
+			responses <- code
+		}()
+		<-handler.started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		start := time.Now()
+		err := server.Shutdown(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("Expected the in-flight message to drain but shutdown failed after %v: %v", time.Since(start), err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected shutdown to finish once the message was answered but it took %v", elapsed)
+		}
+		if code := <-responses; code != "00" {
+			t.Errorf("Expected the in-flight message to be answered with 00 but got %q", code)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+	"github.com/TFMV/pulse/router"
+)
+
+// candidateIssuer is a slow issuer build that declines every request
+type candidateIssuer struct {
+	proto.UnimplementedAuthServiceServer
+	calls atomic.Int32
+}
+
+func (c *candidateIssuer) ProcessAuth(ctx context.Context, req *proto.AuthRequest) (*proto.AuthResponse, error) {
+	c.calls.Add(1)
+	time.Sleep(500 * time.Millisecond)
+	return &proto.AuthResponse{
+		Mti:              "0110",
+		Pan:              req.Pan,
+		Amount:           req.Amount,
+		TransmissionTime: req.TransmissionTime,
+		Stan:             req.Stan,
+		ResponseCode:     "05",
+	}, nil
+}
+
+func TestShadowTraffic(t *testing.T) {
+	usEast := &callCountingIssuer{AuthServiceServer: newUSEastIssuer(t)}
+	candidate := &candidateIssuer{}
+
+	rt := router.NewRouter(router.Config{
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east":        startIssuer(t, usEast),
+			"us-east-canary": startIssuer(t, candidate),
+		},
+		Shadow: router.ShadowConfig{
+			Region:     "us-east-canary",
+			SampleRate: 1,
+			Bins:       []string{"4"},
+		},
+	}, nil, nil, nil)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	authorize := func(pan string, i int) string {
+		start := time.Now()
+		response, err := rt.HandleMessage(context.Background(), newMessage(t, map[int]string{
+			0:  "0100",
+			2:  pan,
+			4:  "50",
+			7:  "0102150405",
+			11: fmt.Sprintf("%06d", 900+i),
+		}))
+		if err != nil {
+			t.Fatalf("HandleMessage failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+			t.Errorf("Expected the live response not to wait for the shadow region but it took %v", elapsed)
+		}
+		code, _ := response.GetString(39)
+		return code
+	}
+
+	// The live answer is returned although the shadow region declines
+	for i := 0; i < 3; i++ {
+		if code := authorize("4111111111111111", i); code != "00" {
+			t.Errorf("Expected the live approval but got %s", code)
+		}
+	}
+
+	// BINs outside the filter are not mirrored
+	if code := authorize("5111111111111111", 3); code != "00" {
+		t.Errorf("Expected the live approval but got %s", code)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for candidate.calls.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if calls, live := candidate.calls.Load(), usEast.calls.Load(); calls != 3 || live != 4 {
+		t.Errorf("Expected 3 mirrored and 4 live requests but got %d and %d", calls, live)
+	}
+}
+
+func TestShadowValidation(t *testing.T) {
+	regions := map[string]router.RegionConfig{
+		"us-east":        {Host: "127.0.0.1", Port: 1},
+		"eu-west":        {Host: "127.0.0.1", Port: 2},
+		"us-east-canary": {Host: "127.0.0.1", Port: 3},
+	}
+	valid := func() router.Config {
+		return router.Config{
+			BinRoutes:     map[string]string{"4": "us-east", "5": "eu-west"},
+			DefaultRegion: "us-east",
+			Regions:       regions,
+			FailoverMap:   map[string]string{"us-east": "eu-west"},
+			Shadow:        router.ShadowConfig{Region: "us-east-canary", SampleRate: 0.1},
+		}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Expected a shadow region outside live routing to be accepted but got %v", err)
+	}
+
+	invalid := map[string]func(*router.Config){
+		"an unconfigured shadow region": func(c *router.Config) { c.Shadow.Region = "ap-south" },
+		"a sample rate above 1":         func(c *router.Config) { c.Shadow.SampleRate = 1.5 },
+		"a shadow default region":       func(c *router.Config) { c.DefaultRegion = "us-east-canary" },
+		"a shadow BIN route":            func(c *router.Config) { c.BinRoutes["6"] = "us-east-canary" },
+		"a shadow failover region":      func(c *router.Config) { c.FailoverMap["eu-west"] = "us-east-canary" },
+		"a shadow region in a failover chain": func(c *router.Config) {
+			c.FailoverChains = map[string][]router.FailoverTarget{
+				"eu-west": {{Region: "us-east"}, {Region: "us-east-canary", Priority: 1}},
+			}
+		},
+	}
+	for name, change := range invalid {
+		config := valid()
+		change(&config)
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package examples
+
+import (
+	"testing"
+
+	"github.com/TFMV/pulse/spec"
+	"github.com/moov-io/iso8583"
+)
+
+func TestBuiltinSpecs(t *testing.T) {
+	for _, name := range []string{spec.ISO87ASCII, spec.ISO93ASCII} {
+		t.Run(name, func(t *testing.T) {
+			messageSpec, err := spec.Load(name)
+			if err != nil {
+				t.Fatalf("Failed to load spec: %v", err)
+			}
+
+			// Build an authorization that uses an LLVAR PAN and a secondary bitmap field
+			message := iso8583.NewMessage(messageSpec)
+			fields := map[int]string{
+				0:   "0100",
+				2:   "4111111111111111",
+				4:   "5000",
+				7:   "0102150405",
+				11:  "123456",
+				102: "ACCT-1",
+			}
+			for id, value := range fields {
+				if err := message.Field(id, value); err != nil {
+					t.Fatalf("Failed to set field %d: %v", id, err)
+				}
+			}
+
+			packed, err := message.Pack()
+			if err != nil {
+				t.Fatalf("Failed to pack message: %v", err)
+			}
+
+			unpacked := iso8583.NewMessage(messageSpec)
+			if err := unpacked.Unpack(packed); err != nil {
+				t.Fatalf("Failed to unpack message: %v", err)
+			}
+
+			for id, expected := range fields {
+				value, err := unpacked.GetString(id)
+				if err != nil {
+					t.Fatalf("Failed to get field %d: %v", id, err)
+				}
+				if value != expected {
+					t.Errorf("Field %d: expected %q but got %q", id, expected, value)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSpecFormats(t *testing.T) {
+	yamlSpec := []byte(`
+name: "Test"
+fields:
+  0: {type: String, length: 4, description: "MTI", enc: ASCII, prefix: ASCII.Fixed}
+  1: {type: Bitmap, length: 8, description: "Bitmap", enc: Binary, prefix: Binary.Fixed}
+  2: {type: String, length: 19, description: "PAN", enc: ASCII, prefix: ASCII.LL}
+`)
+	if _, err := spec.Parse(yamlSpec, "yaml"); err != nil {
+		t.Errorf("Failed to parse YAML spec: %v", err)
+	}
+
+	jsonSpec := []byte(`{"name": "Test", "fields": {
+		"0": {"type": "String", "length": 4, "description": "MTI", "enc": "ASCII", "prefix": "ASCII.Fixed"},
+		"1": {"type": "Bitmap", "length": 8, "description": "Bitmap", "enc": "Binary", "prefix": "Binary.Fixed"}
+	}}`)
+	if _, err := spec.Parse(jsonSpec, "json"); err != nil {
+		t.Errorf("Failed to parse JSON spec: %v", err)
+	}
+
+	// A spec without a bitmap cannot be used to build messages
+	noBitmap := []byte(`{"name": "Test", "fields": {
+		"0": {"type": "String", "length": 4, "description": "MTI", "enc": "ASCII", "prefix": "ASCII.Fixed"}
+	}}`)
+	if _, err := spec.Parse(noBitmap, "json"); err == nil {
+		t.Error("Expected an error for a spec without a bitmap")
+	}
+}
@@ -0,0 +1,106 @@
+package examples
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TFMV/pulse/router"
+	"github.com/TFMV/pulse/storage"
+)
+
+func TestStickyRouting(t *testing.T) {
+	usEast := &unavailableIssuer{AuthServiceServer: newUSEastIssuer(t), failures: 1}
+	euWest := &callCountingIssuer{AuthServiceServer: newEUWestIssuer(t)}
+
+	store := storage.NewMemoryStore()
+	rt := router.NewRouter(router.Config{
+		BinRoutes:     map[string]string{"4": "us-east"},
+		DefaultRegion: "us-east",
+		Regions: map[string]router.RegionConfig{
+			"us-east": startIssuer(t, usEast),
+			"eu-west": startIssuer(t, euWest),
+		},
+		FailoverMap: map[string]string{"us-east": "eu-west"},
+		Retry:       router.RetryConfig{MaxAttempts: 2},
+	}, nil, nil, store)
+	if err := rt.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize router: %v", err)
+	}
+	defer rt.Close()
+
+	send := func(mti, stan, terminalID, original string) string {
+		fields := map[int]string{
+			0:  mti,
+			2:  "4111111111111111",
+			4:  "75",
+			7:  "0102150405",
+			11: stan,
+		}
+		if terminalID != "" {
+			fields[41] = terminalID
+		}
+		if original != "" {
+			fields[90] = original
+		}
+		response, err := rt.HandleMessage(context.Background(), newMessage(t, fields))
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", mti, stan, err)
+		}
+		code, _ := response.GetString(39)
+		return code
+	}
+
+	// The authorization fails in us-east and is retried in eu-west
+	if code := send("0100", "000801", "", ""); code != "00" {
+		t.Fatalf("Expected the retried authorization to be approved but got %s", code)
+	}
+	record := waitForRecord(t, store, storage.TransactionKey{Stan: "000801", TransmissionTime: "0102150405"})
+	if record.Region != "eu-west" || record.PrimaryRegion != "us-east" || record.FailoverHops != 1 ||
+		record.FailoverReason != router.FailoverReasonRetry {
+		t.Fatalf("Expected the routing decision us-east to eu-west after a retry but got region %s, primary %s, hops %d, reason %q",
+			record.Region, record.PrimaryRegion, record.FailoverHops, record.FailoverReason)
+	}
+
+	// us-east is healthy again. Another terminal's authorization with the
+	// same STAN and transmission time goes there.
+	if code := send("0100", "000801", "TERM0002", ""); code != "00" {
+		t.Fatalf("Expected the other terminal's authorization to be approved but got %s", code)
+	}
+	other := waitForRecord(t, store, storage.TransactionKey{Stan: "000801", TransmissionTime: "0102150405", TerminalID: "TERM0002"})
+	if other.Region != "us-east" {
+		t.Fatalf("Expected the other terminal's authorization to be routed to us-east but got %s", other.Region)
+	}
+
+	// Follow-ups of the first authorization still go to eu-west
+	original := "010000080101021504050000000000000000000000"
+	for _, followUp := range []struct{ mti, stan string }{
+		{"0220", "000802"}, // Completion
+		{"0200", "000803"}, // Refund
+		{"0100", "000804"}, // Incremental authorization
+	} {
+		before := euWest.calls.Load()
+		if code := send(followUp.mti, followUp.stan, "", original); code != "00" {
+			t.Fatalf("Expected %s to be approved but got %s", followUp.mti, code)
+		}
+		if euWest.calls.Load() != before+1 {
+			t.Errorf("Expected %s to follow the original to eu-west", followUp.mti)
+		}
+		key := storage.TransactionKey{Stan: followUp.stan, TransmissionTime: "0102150405"}
+		if record := waitForRecord(t, store, key); record.Region != "eu-west" || record.FailoverReason != "" {
+			t.Errorf("Expected %s to be stored for eu-west without failover but got region %s, reason %q",
+				followUp.mti, record.Region, record.FailoverReason)
+		}
+	}
+	if calls := usEast.calls.Load(); calls != 2 {
+		t.Errorf("Expected only the first authorization attempt and the other terminal's authorization in us-east but got %d calls", calls)
+	}
+
+	// Messages without original data are routed by BIN
+	if code := send("0100", "000805", "", ""); code != "00" {
+		t.Fatalf("Expected approval but got %s", code)
+	}
+	record = waitForRecord(t, store, storage.TransactionKey{Stan: "000805", TransmissionTime: "0102150405"})
+	if record.Region != "us-east" {
+		t.Errorf("Expected a new authorization to be routed to us-east but got %s", record.Region)
+	}
+}
@@ -0,0 +1,107 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/TFMV/pulse/proto"
+	"github.com/TFMV/pulse/router"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// adviceIssuer records the stand-in advices that reach the issuer
+type adviceIssuer struct {
+	proto.AuthServiceServer
+	mutex   sync.Mutex
+	advices []*proto.AuthRequest
+}
+
+func (a *adviceIssuer) ProcessAuth(ctx context.Context, req *proto.AuthRequest) (*proto.AuthResponse, error) {
+	if req.StandInResponseCode != "" {
+		a.mutex.Lock()
+		a.advices = append(a.advices, req)
+		a.mutex.Unlock()
+	}
+	return a.AuthServiceServer.ProcessAuth(ctx, req)
+}
+
+func TestStandInProcessing(t *testing.T) {
+	stip := router.NewStandInProcessor(router.StandInConfig{
+		Enabled: true,
+		Limits: []router.StandInLimit{
+			{BIN: "4", MaxAmount: 100},
+			{BIN: "411111", MaxAmount: 200, MaxCardAmount: 300},
+		},
+	}, nil)
+
+	tests := []struct {
+		pan    string
+		amount string
+		code   string
+	}{
+		{"4111111111111111", "150", "00"}, // Within the 6-digit BIN limit
+		{"4111111111111111", "150", "00"}, // Card total reaches 300
+		{"4111111111111111", "10", "05"},  // Card total over 300
+		{"4222222222222222", "150", "05"}, // Over the 1-digit BIN limit
+		{"4222222222222222", "abc", "12"}, // Invalid amount
+		{"5111111111111111", "10", "91"},  // No stand-in limit for the BIN
+	}
+	for i, test := range tests {
+		resp := stip.Authorize(&proto.AuthRequest{
+			Mti:          "0100",
+			Pan:          test.pan,
+			Amount:       test.amount,
+			Stan:         fmt.Sprintf("%06d", i+1),
+			MessageClass: proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION,
+		}, "us-east")
+		if resp.ResponseCode != test.code {
+			t.Errorf("Request %d: expected response code %s but got %s", i, test.code, resp.ResponseCode)
+		}
+		if resp.Mti != "0110" {
+			t.Errorf("Request %d: expected MTI 0110 but got %s", i, resp.Mti)
+		}
+	}
+	if pending := stip.Pending("us-east"); pending != 2 {
+		t.Fatalf("Expected 2 queued advices but got %d", pending)
+	}
+
+	// Replay the approvals once the issuer is back
+	usEast := &adviceIssuer{AuthServiceServer: newUSEastIssuer(t)}
+	region := startIssuer(t, usEast)
+	conn, err := grpc.Dial(fmt.Sprintf("%s:%d", region.Host, region.Port),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect to issuer: %v", err)
+	}
+	defer conn.Close()
+
+	replayed, err := stip.Replay(context.Background(), "us-east", proto.NewAuthServiceClient(conn))
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if replayed != 2 || stip.Pending("us-east") != 0 {
+		t.Fatalf("Expected 2 advices replayed and none pending but replayed %d with %d pending",
+			replayed, stip.Pending("us-east"))
+	}
+
+	for i, advice := range usEast.advices {
+		if advice.Mti != "0120" || advice.StandInResponseCode != "00" || advice.Stan != fmt.Sprintf("%06d", i+1) {
+			t.Errorf("Advice %d: unexpected MTI %s, stand-in code %s or STAN %s",
+				i, advice.Mti, advice.StandInResponseCode, advice.Stan)
+		}
+	}
+
+	// Replayed approvals no longer count against the card limit
+	resp := stip.Authorize(&proto.AuthRequest{
+		Mti:    "0100",
+		Pan:    "4111111111111111",
+		Amount: "150",
+		Stan:   "000007",
+	}, "us-east")
+	if resp.ResponseCode != "00" {
+		t.Errorf("Expected approval after replay but got %s", resp.ResponseCode)
+	}
+}
@@ -0,0 +1,113 @@
+package iso
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnectionStats describes a live client connection
+type ConnectionStats struct {
+	RemoteAddr       string    `json:"remote_addr"`
+	ConnectedAt      time.Time `json:"connected_at"`
+	InFlight         int       `json:"in_flight"`
+	MessagesReceived uint64    `json:"messages_received"`
+	LastMessageAt    time.Time `json:"last_message_at,omitempty"`
+	SignedOn         bool      `json:"signed_on"`
+}
+
+// connection holds the per-connection state shared by the read loop and the
+// goroutines processing messages received on it
+type connection struct {
+	conn        net.Conn
+	remoteAddr  string
+	connectedAt time.Time
+	framer      Framer
+	session     *Session
+	writeMu     sync.Mutex
+	// inFlight bounds the number of messages processed concurrently
+	inFlight chan struct{}
+	// pending tracks messages that are still being processed
+	pending sync.WaitGroup
+	// draining is set when the server stops reading from the connection.
+	// deadlineMu orders it with the read deadlines the read loop sets, so
+	// the immediate deadline of a drain is never overwritten.
+	draining   atomic.Bool
+	deadlineMu sync.Mutex
+
+	messagesReceived atomic.Uint64
+	lastMessageAt    atomic.Int64
+}
+
+// newConnection wraps a network connection
+func newConnection(conn net.Conn, framer Framer, maxInFlight int) *connection {
+	return &connection{
+		conn:        conn,
+		remoteAddr:  conn.RemoteAddr().String(),
+		connectedAt: time.Now(),
+		framer:      framer,
+		session:     &Session{remoteAddr: conn.RemoteAddr().String()},
+		inFlight:    make(chan struct{}, maxInFlight),
+	}
+}
+
+// acquire blocks until another message may be processed on the connection
+func (c *connection) acquire() {
+	c.inFlight <- struct{}{}
+	c.pending.Add(1)
+	c.messagesReceived.Add(1)
+	c.lastMessageAt.Store(time.Now().UnixNano())
+}
+
+// release marks a message as done
+func (c *connection) release() {
+	c.pending.Done()
+	<-c.inFlight
+}
+
+// drain stops the read loop so no new messages are accepted, while messages
+// already in flight still complete and write their responses
+func (c *connection) drain() {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+
+	c.draining.Store(true)
+	c.conn.SetReadDeadline(time.Now())
+}
+
+// extendReadDeadline lets the read loop wait timeout for the next message. It
+// reports false without touching the deadline when the connection is draining.
+func (c *connection) extendReadDeadline(timeout time.Duration) (bool, error) {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+
+	if c.draining.Load() {
+		return false, nil
+	}
+	return true, c.conn.SetReadDeadline(time.Now().Add(timeout))
+}
+
+// writeFrame writes a framed message, serializing writers so that responses
+// completed concurrently are never interleaved on the wire
+func (c *connection) writeFrame(message []byte, request *Frame) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.framer.WriteFrame(c.conn, message, request)
+}
+
+// stats returns a snapshot of the connection's statistics
+func (c *connection) stats() ConnectionStats {
+	stats := ConnectionStats{
+		RemoteAddr:       c.remoteAddr,
+		ConnectedAt:      c.connectedAt,
+		InFlight:         len(c.inFlight),
+		MessagesReceived: c.messagesReceived.Load(),
+		SignedOn:         c.session.SignedOn(),
+	}
+	if last := c.lastMessageAt.Load(); last != 0 {
+		stats.LastMessageAt = time.Unix(0, last)
+	}
+	return stats
+}
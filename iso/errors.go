@@ -0,0 +1,126 @@
+package iso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/moov-io/iso8583"
+)
+
+// Error classes used to map message processing failures to response codes
+const (
+	// ErrorFormat is a message that could not be unpacked or is missing required fields
+	ErrorFormat = "format"
+	// ErrorIssuerUnavailable is an issuer or region that could not be reached
+	ErrorIssuerUnavailable = "issuer_unavailable"
+	// ErrorTimeout is an issuer that did not answer before the deadline
+	ErrorTimeout = "timeout"
+	// ErrorNotSignedOn is financial traffic on a connection that has not signed on
+	ErrorNotSignedOn = "not_signed_on"
+	// ErrorSystem is any other processing failure
+	ErrorSystem = "system"
+)
+
+// DefaultErrorResponseCodes maps error classes to the field 39 response codes
+// returned when a message cannot be processed
+var DefaultErrorResponseCodes = map[string]string{
+	ErrorFormat:            "30", // Format error
+	ErrorIssuerUnavailable: "91", // Issuer or switch inoperative
+	ErrorTimeout:           "91", // Issuer or switch inoperative
+	ErrorNotSignedOn:       "91", // Issuer or switch inoperative
+	ErrorSystem:            "96", // System malfunction
+}
+
+// EchoedFields lists the request fields copied into responses. Terminals match
+// responses to requests on STAN (11) and terminal ID (41).
+var EchoedFields = []int{2, 3, 4, 7, 11, 12, 13, 37, 41, 42, 49}
+
+// ProcessingError is a message handler error tagged with an error class
+type ProcessingError struct {
+	Class string
+	Err   error
+}
+
+// NewProcessingError tags err with an error class
+func NewProcessingError(class string, err error) error {
+	return &ProcessingError{Class: class, Err: err}
+}
+
+// Error implements the error interface
+func (e *ProcessingError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ProcessingError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorClass returns the error class of a message handler error. Untagged
+// deadline errors are timeouts and all other untagged errors are system errors.
+func ErrorClass(err error) string {
+	var processingErr *ProcessingError
+	if errors.As(err, &processingErr) {
+		return processingErr.Class
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorTimeout
+	}
+	return ErrorSystem
+}
+
+// ResponseMTI returns the response MTI for a request MTI, e.g. 0100 -> 0110,
+// 0420 -> 0430 and 0401 -> 0410
+func ResponseMTI(mti string) (string, error) {
+	if len(mti) != 4 || mti[2] < '0' || mti[2] > '9' {
+		return "", fmt.Errorf("invalid MTI %q", mti)
+	}
+
+	return mti[:2] + string(mti[2]|1) + "0", nil
+}
+
+// NewResponse builds a response to request with the response MTI, the
+// EchoedFields and extra fields that are present in the request, and the
+// given response code in field 39. Only fields that were unpacked are echoed,
+// so a partially unpacked request can still be answered.
+func NewResponse(request *iso8583.Message, responseCode string, extraFields ...int) (*iso8583.Message, error) {
+	fields := request.GetFields()
+	if _, ok := fields[0]; !ok {
+		return nil, errors.New("request has no MTI")
+	}
+
+	mti, err := request.GetString(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MTI: %w", err)
+	}
+	responseMti, err := ResponseMTI(mti)
+	if err != nil {
+		return nil, err
+	}
+
+	response := iso8583.NewMessage(request.GetSpec())
+	if err := response.Field(0, responseMti); err != nil {
+		return nil, fmt.Errorf("failed to set MTI: %w", err)
+	}
+
+	echoed := append(append([]int{}, EchoedFields...), extraFields...)
+	for _, id := range echoed {
+		if _, ok := fields[id]; !ok {
+			continue
+		}
+		value, err := request.GetString(id)
+		if err != nil {
+			continue
+		}
+		if err := response.Field(id, value); err != nil {
+			return nil, fmt.Errorf("failed to set field %d: %w", id, err)
+		}
+	}
+
+	if err := response.Field(39, responseCode); err != nil {
+		return nil, fmt.Errorf("failed to set response code: %w", err)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,209 @@
+package iso
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Framing types supported by NewFramer
+const (
+	// FramingBinary2 is a 2-byte big-endian binary length that excludes the length bytes
+	FramingBinary2 = "binary2"
+	// FramingBinary2Inclusive is a 2-byte big-endian binary length that includes the length bytes
+	FramingBinary2Inclusive = "binary2_inclusive"
+	// FramingASCII4 is a 4-digit ASCII decimal length that excludes the length bytes
+	FramingASCII4 = "ascii4"
+)
+
+// tpduLength is the size of a TPDU header: ID, destination NII and source NII
+const tpduLength = 5
+
+// FramerConfig holds the network framing configuration of a listener or client
+type FramerConfig struct {
+	// Type is one of binary2, binary2_inclusive or ascii4 (default: binary2)
+	Type string `yaml:"type"`
+	// TPDU is a hex-encoded 5-byte TPDU header (e.g. "6000010000"). When set,
+	// every message is preceded by a TPDU inside the length prefix.
+	TPDU string `yaml:"tpdu"`
+}
+
+// Frame is a single message read from the network
+type Frame struct {
+	// Header holds protocol headers that precede the message, such as a TPDU
+	Header []byte
+	// Message holds the packed ISO8583 message
+	Message []byte
+}
+
+// Framer reads and writes framed ISO8583 messages on a stream
+type Framer interface {
+	// ReadFrame reads the next framed message
+	ReadFrame(r io.Reader) (*Frame, error)
+
+	// WriteFrame writes a framed message. request is the frame being answered,
+	// or nil when the message does not answer a received frame.
+	WriteFrame(w io.Writer, message []byte, request *Frame) error
+}
+
+// NewFramer creates a Framer from configuration
+func NewFramer(config FramerConfig) (Framer, error) {
+	var framer Framer
+	switch config.Type {
+	case "", FramingBinary2:
+		framer = &BinaryLengthFramer{}
+	case FramingBinary2Inclusive:
+		framer = &BinaryLengthFramer{IncludeLength: true}
+	case FramingASCII4:
+		framer = &ASCIILengthFramer{}
+	default:
+		return nil, fmt.Errorf("unknown framing type: %s", config.Type)
+	}
+
+	if config.TPDU == "" {
+		return framer, nil
+	}
+
+	tpdu, err := hex.DecodeString(config.TPDU)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TPDU %q: %w", config.TPDU, err)
+	}
+	if len(tpdu) != tpduLength {
+		return nil, fmt.Errorf("invalid TPDU %q: expected %d bytes, got %d", config.TPDU, tpduLength, len(tpdu))
+	}
+
+	return &TPDUFramer{Next: framer, TPDU: tpdu}, nil
+}
+
+// BinaryLengthFramer frames messages with a 2-byte big-endian binary length
+type BinaryLengthFramer struct {
+	// IncludeLength makes the length value count the 2 length bytes themselves
+	IncludeLength bool
+}
+
+// ReadFrame implements the Framer interface
+func (f *BinaryLengthFramer) ReadFrame(r io.Reader) (*Frame, error) {
+	lengthBytes := make([]byte, 2)
+	if _, err := io.ReadFull(r, lengthBytes); err != nil {
+		return nil, err
+	}
+
+	messageLength := int(lengthBytes[0])*256 + int(lengthBytes[1])
+	if f.IncludeLength {
+		messageLength -= len(lengthBytes)
+		if messageLength < 0 {
+			return nil, fmt.Errorf("invalid message length %d", messageLength+len(lengthBytes))
+		}
+	}
+
+	return readMessage(r, messageLength)
+}
+
+// WriteFrame implements the Framer interface
+func (f *BinaryLengthFramer) WriteFrame(w io.Writer, message []byte, request *Frame) error {
+	length := len(message)
+	if f.IncludeLength {
+		length += 2
+	}
+	if length > 0xFFFF {
+		return fmt.Errorf("message length %d exceeds 2-byte length prefix", length)
+	}
+
+	return writeAll(w, []byte{byte(length / 256), byte(length % 256)}, message)
+}
+
+// ASCIILengthFramer frames messages with a 4-digit ASCII decimal length
+type ASCIILengthFramer struct{}
+
+// ReadFrame implements the Framer interface
+func (f *ASCIILengthFramer) ReadFrame(r io.Reader) (*Frame, error) {
+	lengthBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, lengthBytes); err != nil {
+		return nil, err
+	}
+
+	// Atoi accepts a leading sign, so check for plain digits first
+	for _, b := range lengthBytes {
+		if b < '0' || b > '9' {
+			return nil, fmt.Errorf("invalid ASCII length header %q", lengthBytes)
+		}
+	}
+	messageLength, err := strconv.Atoi(string(lengthBytes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ASCII length header %q", lengthBytes)
+	}
+
+	return readMessage(r, messageLength)
+}
+
+// WriteFrame implements the Framer interface
+func (f *ASCIILengthFramer) WriteFrame(w io.Writer, message []byte, request *Frame) error {
+	if len(message) > 9999 {
+		return fmt.Errorf("message length %d exceeds 4-digit length header", len(message))
+	}
+
+	return writeAll(w, []byte(fmt.Sprintf("%04d", len(message))), message)
+}
+
+// TPDUFramer adds a 5-byte TPDU header inside the length prefix of another framer.
+// Replies echo the request TPDU with the source and destination NII swapped.
+type TPDUFramer struct {
+	// Next frames the TPDU and message together
+	Next Framer
+	// TPDU is the header used for messages that do not answer a received frame
+	TPDU []byte
+}
+
+// ReadFrame implements the Framer interface
+func (f *TPDUFramer) ReadFrame(r io.Reader) (*Frame, error) {
+	frame, err := f.Next.ReadFrame(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(frame.Message) < tpduLength {
+		return nil, fmt.Errorf("frame of %d bytes is too short for a TPDU", len(frame.Message))
+	}
+
+	return &Frame{
+		Header:  frame.Message[:tpduLength],
+		Message: frame.Message[tpduLength:],
+	}, nil
+}
+
+// WriteFrame implements the Framer interface
+func (f *TPDUFramer) WriteFrame(w io.Writer, message []byte, request *Frame) error {
+	tpdu := make([]byte, tpduLength)
+	if request != nil && len(request.Header) == tpduLength {
+		// Swap the destination (bytes 1-2) and source (bytes 3-4) NII
+		tpdu[0] = request.Header[0]
+		copy(tpdu[1:3], request.Header[3:5])
+		copy(tpdu[3:5], request.Header[1:3])
+	} else {
+		copy(tpdu, f.TPDU)
+	}
+
+	return f.Next.WriteFrame(w, append(tpdu, message...), nil)
+}
+
+// readMessage reads a message body of the given length
+func readMessage(r io.Reader, length int) (*Frame, error) {
+	message := make([]byte, length)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, fmt.Errorf("failed to read message body: %w", err)
+	}
+
+	return &Frame{Message: message}, nil
+}
+
+// writeAll writes the length header and message with a single write so that
+// frames from concurrent writers are never interleaved
+func writeAll(w io.Writer, header, message []byte) error {
+	buf := make([]byte, 0, len(header)+len(message))
+	buf = append(buf, header...)
+	buf = append(buf, message...)
+
+	_, err := w.Write(buf)
+	return err
+}
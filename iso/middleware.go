@@ -0,0 +1,27 @@
+package iso
+
+import (
+	"context"
+
+	"github.com/moov-io/iso8583"
+)
+
+// MessageHandlerFunc adapts a function to the MessageHandler interface
+type MessageHandlerFunc func(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error)
+
+// HandleMessage implements the MessageHandler interface
+func (f MessageHandlerFunc) HandleMessage(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+	return f(ctx, message)
+}
+
+// Middleware wraps a MessageHandler with behavior that runs before or after it
+type Middleware func(next MessageHandler) MessageHandler
+
+// Chain wraps handler in middlewares. The first middleware receives messages
+// first and sees the response last.
+func Chain(handler MessageHandler, middlewares ...Middleware) MessageHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
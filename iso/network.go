@@ -0,0 +1,201 @@
+package iso
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/moov-io/iso8583"
+)
+
+// Network management information codes (field 70)
+const (
+	NetworkSignOn  = "001"
+	NetworkSignOff = "002"
+	NetworkCutover = "201"
+	NetworkEcho    = "301"
+)
+
+// NetworkConfig holds network management configuration for a listener
+type NetworkConfig struct {
+	// RequireSignOn rejects authorization, financial and reversal messages on
+	// connections that have not sent a sign-on (0800 with field 70 = 001)
+	RequireSignOn bool `yaml:"require_sign_on"`
+}
+
+// Session holds the state of a single client connection
+type Session struct {
+	remoteAddr string
+	signedOn   atomic.Bool
+	signedOnAt atomic.Int64
+}
+
+// RemoteAddr returns the address of the client
+func (s *Session) RemoteAddr() string {
+	return s.remoteAddr
+}
+
+// SignedOn reports whether the client has signed on
+func (s *Session) SignedOn() bool {
+	return s.signedOn.Load()
+}
+
+// SignedOnAt returns when the client last signed on
+func (s *Session) SignedOnAt() time.Time {
+	if at := s.signedOnAt.Load(); at != 0 {
+		return time.Unix(0, at)
+	}
+	return time.Time{}
+}
+
+// SignOn marks the client as signed on
+func (s *Session) SignOn() {
+	s.signedOnAt.Store(time.Now().UnixNano())
+	s.signedOn.Store(true)
+}
+
+// SignOff marks the client as signed off
+func (s *Session) SignOff() {
+	s.signedOn.Store(false)
+}
+
+// sessionKey is the context key for the session of the connection a message arrived on
+type sessionKey struct{}
+
+// ContextWithSession returns a context carrying the connection session
+func ContextWithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext returns the session of the connection a message arrived on
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(*Session)
+	return session, ok
+}
+
+// NetworkHandler answers network management messages (08xx) and passes all
+// other messages to the next handler
+type NetworkHandler struct {
+	config NetworkConfig
+	next   MessageHandler
+
+	// businessDate is the settlement date (MMDD) set by the last cutover
+	businessDate string
+	dateMutex    sync.RWMutex
+}
+
+// NewNetworkHandler creates a network management handler in front of next
+func NewNetworkHandler(config NetworkConfig, next MessageHandler) *NetworkHandler {
+	return &NetworkHandler{
+		config:       config,
+		next:         next,
+		businessDate: time.Now().Format("0102"),
+	}
+}
+
+// BusinessDate returns the current settlement date (MMDD)
+func (h *NetworkHandler) BusinessDate() string {
+	h.dateMutex.RLock()
+	defer h.dateMutex.RUnlock()
+	return h.businessDate
+}
+
+// HandleMessage implements the MessageHandler interface
+func (h *NetworkHandler) HandleMessage(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+	mti, err := message.GetString(0)
+	if err != nil {
+		return nil, NewProcessingError(ErrorFormat, fmt.Errorf("failed to get MTI: %w", err))
+	}
+	if len(mti) != 4 {
+		return nil, NewProcessingError(ErrorFormat, fmt.Errorf("invalid MTI %q", mti))
+	}
+
+	session, _ := SessionFromContext(ctx)
+
+	// Network management requests and advices
+	if mti[1] == '8' {
+		return h.handleNetworkMessage(message, session)
+	}
+
+	// Authorization, financial and reversal messages require a sign-on
+	if h.config.RequireSignOn && requiresSignOn(mti) && session != nil && !session.SignedOn() {
+		return nil, NewProcessingError(ErrorNotSignedOn,
+			fmt.Errorf("rejecting %s from %s: connection has not signed on", mti, session.RemoteAddr()))
+	}
+
+	return h.next.HandleMessage(ctx, message)
+}
+
+// handleNetworkMessage answers a network management message
+func (h *NetworkHandler) handleNetworkMessage(message *iso8583.Message, session *Session) (*iso8583.Message, error) {
+	code, err := message.GetString(70)
+	if err != nil || code == "" {
+		return nil, NewProcessingError(ErrorFormat, fmt.Errorf("network management message without field 70"))
+	}
+
+	remoteAddr := "unknown"
+	if session != nil {
+		remoteAddr = session.RemoteAddr()
+	}
+
+	responseCode := "00"
+	switch code {
+	case NetworkSignOn:
+		if session != nil {
+			session.SignOn()
+		}
+		log.Printf("Sign-on from %s", remoteAddr)
+
+	case NetworkSignOff:
+		if session != nil {
+			session.SignOff()
+		}
+		log.Printf("Sign-off from %s", remoteAddr)
+
+	case NetworkEcho:
+		// Echo tests only confirm the link is up
+
+	case NetworkCutover:
+		// Switch to the settlement date sent by the host, or to today
+		businessDate := time.Now().Format("0102")
+		if date, err := message.GetString(15); err == nil && len(date) == 4 {
+			businessDate = date
+		}
+		h.dateMutex.Lock()
+		previous := h.businessDate
+		h.businessDate = businessDate
+		h.dateMutex.Unlock()
+		log.Printf("Cutover from %s: business date %s -> %s", remoteAddr, previous, businessDate)
+
+	default:
+		log.Printf("Unsupported network management code %s from %s", code, remoteAddr)
+		responseCode = "12" // Invalid transaction
+	}
+
+	response, err := NewResponse(message, responseCode, 70)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build network management response: %w", err)
+	}
+
+	if code == NetworkCutover && responseCode == "00" {
+		if err := response.Field(15, h.BusinessDate()); err != nil {
+			return nil, fmt.Errorf("failed to set settlement date: %w", err)
+		}
+	}
+
+	return response, nil
+}
+
+// requiresSignOn reports whether messages of the MTI's class are only
+// accepted on signed-on connections
+func requiresSignOn(mti string) bool {
+	switch mti[1] {
+	case '1', '2', '4': // Authorization, financial and reversal
+		return true
+	default:
+		return false
+	}
+}
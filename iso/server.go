@@ -7,22 +7,68 @@ import (
 	"io"
 	"log"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/TFMV/pulse/metrics"
 	"github.com/moov-io/iso8583"
-	"github.com/moov-io/iso8583/encoding"
-	"github.com/moov-io/iso8583/field"
-	"github.com/moov-io/iso8583/prefix"
 )
 
+// ServerConfig holds configuration for an ISO8583 TCP server
+type ServerConfig struct {
+	// Name identifies the listener in logs and metrics
+	Name string
+	// Address is the TCP address to listen on
+	Address string
+	// Spec is the message spec used to unpack requests
+	Spec *iso8583.MessageSpec
+	// Framer frames messages on the wire (default: 2-byte binary length)
+	Framer Framer
+	// MaxInFlight limits the messages processed concurrently per connection (default: 100)
+	MaxInFlight int
+	// Metrics receives connection and in-flight gauges (optional)
+	Metrics *metrics.Metrics
+	// ErrorResponseCodes overrides the response codes sent for each error class
+	// (default: DefaultErrorResponseCodes)
+	ErrorResponseCodes map[string]string
+}
+
+// defaultMaxInFlight is the per-connection concurrency limit when none is configured
+const defaultMaxInFlight = 100
+
+// readTimeout closes connections that send no message for this long
+const readTimeout = 30 * time.Second
+
 // Server represents the ISO8583 TCP server
 type Server struct {
+	name        string
 	address     string
 	handler     MessageHandler
 	listener    net.Listener
-	isShutdown  bool
-	connections map[string]net.Conn
+	isShutdown  atomic.Bool
+	connections map[string]*connection
+	connMutex   sync.RWMutex
+	// connWG tracks connection goroutines so Shutdown can wait for them
+	connWG      sync.WaitGroup
 	spec        *iso8583.MessageSpec
+	framer      Framer
+	maxInFlight int
+	metrics     *metrics.Metrics
+	// errorResponseCodes maps error classes to response codes
+	errorResponseCodes map[string]string
+	// baseCtx is cancelled when Shutdown gives up waiting for in-flight messages
+	baseCtx    context.Context
+	cancelBase context.CancelFunc
+}
+
+// ServerStats describes the live state of the server
+type ServerStats struct {
+	Name         string            `json:"name"`
+	Address      string            `json:"address"`
+	ShuttingDown bool              `json:"shutting_down"`
+	InFlight     int               `json:"in_flight"`
+	Connections  []ConnectionStats `json:"connections"`
 }
 
 // MessageHandler defines the interface for handling ISO8583 messages
@@ -30,159 +76,301 @@ type MessageHandler interface {
 	HandleMessage(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error)
 }
 
+// receivedAtKey is the context key for the time a message was read from its connection
+type receivedAtKey struct{}
+
+// ReceivedAtFromContext returns when the message being handled was read from
+// its connection. The time until the handler is called is spent waiting for
+// the connection's in-flight limit.
+func ReceivedAtFromContext(ctx context.Context) (time.Time, bool) {
+	receivedAt, ok := ctx.Value(receivedAtKey{}).(time.Time)
+	return receivedAt, ok
+}
+
 // NewServer creates a new ISO8583 TCP server
-func NewServer(address string, handler MessageHandler) *Server {
-	// Create a default ISO8583 message spec for the server
-	spec := &iso8583.MessageSpec{
-		Name: "ISO 8583 v1987",
-		Fields: map[int]field.Field{
-			0:  field.NewString(field.NewSpec(4, "Message Type Indicator", encoding.ASCII, prefix.None.Fixed)),
-			2:  field.NewString(field.NewSpec(19, "Primary Account Number", encoding.ASCII, prefix.None.Fixed)),
-			4:  field.NewString(field.NewSpec(12, "Amount, Transaction", encoding.ASCII, prefix.None.Fixed)),
-			7:  field.NewString(field.NewSpec(10, "Transmission Date and Time", encoding.ASCII, prefix.None.Fixed)),
-			11: field.NewString(field.NewSpec(6, "System Trace Audit Number", encoding.ASCII, prefix.None.Fixed)),
-			39: field.NewString(field.NewSpec(2, "Response Code", encoding.ASCII, prefix.None.Fixed)),
-		},
+func NewServer(config ServerConfig, handler MessageHandler) *Server {
+	framer := config.Framer
+	if framer == nil {
+		framer = &BinaryLengthFramer{}
+	}
+
+	maxInFlight := config.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = defaultMaxInFlight
 	}
 
+	name := config.Name
+	if name == "" {
+		name = config.Address
+	}
+
+	errorResponseCodes := make(map[string]string, len(DefaultErrorResponseCodes))
+	for class, code := range DefaultErrorResponseCodes {
+		errorResponseCodes[class] = code
+	}
+	for class, code := range config.ErrorResponseCodes {
+		errorResponseCodes[class] = code
+	}
+
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+
 	return &Server{
-		address:     address,
-		handler:     handler,
-		connections: make(map[string]net.Conn),
-		spec:        spec,
+		name:               name,
+		address:            config.Address,
+		handler:            handler,
+		connections:        make(map[string]*connection),
+		spec:               config.Spec,
+		framer:             framer,
+		maxInFlight:        maxInFlight,
+		metrics:            config.Metrics,
+		errorResponseCodes: errorResponseCodes,
+		baseCtx:            baseCtx,
+		cancelBase:         cancelBase,
 	}
 }
 
 // Start starts the ISO8583 TCP server
 func (s *Server) Start() error {
-	var err error
-	s.listener, err = net.Listen("tcp", s.address)
+	listener, err := net.Listen("tcp", s.address)
 	if err != nil {
 		return fmt.Errorf("failed to start TCP server: %w", err)
 	}
+	return s.Serve(listener)
+}
 
-	log.Printf("ISO8583 server listening on %s", s.address)
+// Serve accepts connections on listener until the server is shut down
+func (s *Server) Serve(listener net.Listener) error {
+	s.connMutex.Lock()
+	if s.isShutdown.Load() {
+		s.connMutex.Unlock()
+		listener.Close()
+		return nil
+	}
+	s.listener = listener
+	s.connMutex.Unlock()
 
-	for !s.isShutdown {
-		conn, err := s.listener.Accept()
+	log.Printf("ISO8583 server %s listening on %s", s.name, listener.Addr())
+
+	for {
+		conn, err := listener.Accept()
 		if err != nil {
-			if s.isShutdown {
+			if s.isShutdown.Load() {
 				return nil
 			}
 			log.Printf("Error accepting connection: %v", err)
 			continue
 		}
 
-		clientAddr := conn.RemoteAddr().String()
-		s.connections[clientAddr] = conn
-		log.Printf("New connection from %s", clientAddr)
+		c := newConnection(conn, s.framer, s.maxInFlight)
+		if !s.addConnection(c) {
+			// Shutdown started after the connection was accepted
+			conn.Close()
+			continue
+		}
+		log.Printf("New connection from %s", c.remoteAddr)
 
-		go s.handleConnection(conn)
+		go s.handleConnection(c)
 	}
-
-	return nil
 }
 
-// Shutdown gracefully shuts down the server
-func (s *Server) Shutdown() error {
-	s.isShutdown = true
+// Shutdown stops accepting connections, stops reading new messages and waits
+// for in-flight messages to write their responses before closing connections.
+// When ctx expires first, remaining connections are closed and in-flight
+// handlers are cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.isShutdown.Store(true)
+
+	s.connMutex.Lock()
+	listener := s.listener
+	for _, c := range s.connections {
+		c.drain()
+	}
+	s.connMutex.Unlock()
+
+	var listenerErr error
+	if listener != nil {
+		listenerErr = listener.Close()
+	}
+
+	// Wait for the connections to drain
+	drained := make(chan struct{})
+	go func() {
+		s.connWG.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		log.Printf("ISO8583 server %s drained", s.name)
+	case <-ctx.Done():
+		log.Printf("ISO8583 server %s drain deadline exceeded, closing %d connections",
+			s.name, len(s.Stats().Connections))
+		s.cancelBase()
 
-	if s.listener != nil {
-		if err := s.listener.Close(); err != nil {
-			return err
+		s.connMutex.RLock()
+		for _, c := range s.connections {
+			c.conn.Close()
 		}
+		s.connMutex.RUnlock()
+
+		<-drained
+		return ctx.Err()
 	}
 
-	// Close all active connections
-	for addr, conn := range s.connections {
-		log.Printf("Closing connection from %s", addr)
-		conn.Close()
-		delete(s.connections, addr)
+	s.cancelBase()
+	return listenerErr
+}
+
+// Stats returns a snapshot of the server's connections
+func (s *Server) Stats() ServerStats {
+	s.connMutex.RLock()
+	defer s.connMutex.RUnlock()
+
+	stats := ServerStats{
+		Name:         s.name,
+		Address:      s.address,
+		ShuttingDown: s.isShutdown.Load(),
+		Connections:  make([]ConnectionStats, 0, len(s.connections)),
+	}
+	for _, c := range s.connections {
+		connStats := c.stats()
+		stats.InFlight += connStats.InFlight
+		stats.Connections = append(stats.Connections, connStats)
 	}
 
-	return nil
+	return stats
+}
+
+// addConnection registers a connection unless the server is shutting down
+func (s *Server) addConnection(c *connection) bool {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+
+	if s.isShutdown.Load() {
+		return false
+	}
+
+	s.connections[c.remoteAddr] = c
+	s.connWG.Add(1)
+
+	if s.metrics != nil {
+		s.metrics.IsoConnections.WithLabelValues(s.name).Set(float64(len(s.connections)))
+	}
+
+	return true
 }
 
-// handleConnection handles a single client connection
-func (s *Server) handleConnection(conn net.Conn) {
+// removeConnection unregisters a closed connection
+func (s *Server) removeConnection(c *connection) {
+	s.connMutex.Lock()
+	delete(s.connections, c.remoteAddr)
+	if s.metrics != nil {
+		s.metrics.IsoConnections.WithLabelValues(s.name).Set(float64(len(s.connections)))
+	}
+	s.connMutex.Unlock()
+
+	s.connWG.Done()
+}
+
+// handleConnection reads messages from a single client connection and
+// dispatches each one to its own goroutine, so a slow issuer call does not
+// hold up later messages on the same connection
+func (s *Server) handleConnection(c *connection) {
 	defer func() {
-		conn.Close()
-		clientAddr := conn.RemoteAddr().String()
-		delete(s.connections, clientAddr)
-		log.Printf("Connection from %s closed", clientAddr)
+		// Let in-flight messages write their responses before closing
+		c.pending.Wait()
+		c.conn.Close()
+		s.removeConnection(c)
+		log.Printf("Connection from %s closed", c.remoteAddr)
 	}()
 
-	reader := bufio.NewReader(conn)
+	reader := bufio.NewReader(c.conn)
 	for {
-		// Handle potential timeout
-		if err := conn.SetReadDeadline(time.Now().Add(30 * time.Second)); err != nil {
+		// Handle potential timeout, unless the connection is draining
+		reading, err := c.extendReadDeadline(readTimeout)
+		if err != nil {
 			log.Printf("Error setting read deadline: %v", err)
 			return
 		}
+		if !reading {
+			return
+		}
 
-		// Read message length (2 bytes)
-		lengthBytes := make([]byte, 2)
-		if _, err := io.ReadFull(reader, lengthBytes); err != nil {
-			if err == io.EOF {
+		// Read the next framed message
+		frame, err := s.framer.ReadFrame(reader)
+		if err != nil {
+			if err == io.EOF || c.draining.Load() {
 				return
 			}
-			log.Printf("Error reading message length: %v", err)
+			log.Printf("Error reading message frame: %v", err)
 			return
 		}
+		receivedAt := time.Now()
 
-		// Convert length bytes to int
-		messageLength := int(lengthBytes[0])*256 + int(lengthBytes[1])
+		// Process the message concurrently, waiting while the connection is at its in-flight limit
+		c.acquire()
+		s.trackInFlight(1)
+		go func() {
+			defer s.trackInFlight(-1)
+			defer c.release()
 
-		// Read the ISO message
-		messageBytes := make([]byte, messageLength)
-		if _, err := io.ReadFull(reader, messageBytes); err != nil {
-			log.Printf("Error reading message body: %v", err)
-			return
-		}
+			if err := s.processMessage(c, frame, receivedAt); err != nil {
+				log.Printf("Error processing message: %v", err)
+				c.conn.Close()
+			}
+		}()
+	}
+}
 
-		// Process the message
-		if err := s.processMessage(conn, messageBytes); err != nil {
-			log.Printf("Error processing message: %v", err)
-			return
-		}
+// trackInFlight updates the in-flight gauge
+func (s *Server) trackInFlight(delta float64) {
+	if s.metrics != nil {
+		s.metrics.IsoInFlight.WithLabelValues(s.name).Add(delta)
 	}
 }
 
-// processMessage processes an ISO8583 message and sends a response
-func (s *Server) processMessage(conn net.Conn, messageBytes []byte) error {
+// processMessage processes an ISO8583 message and sends a response. Messages
+// that cannot be unpacked or handled are answered with an error response
+// code, so only a failure to write the response is returned.
+func (s *Server) processMessage(c *connection, frame *Frame, receivedAt time.Time) error {
 	start := time.Now()
+	if s.metrics != nil {
+		s.metrics.IsoQueueLatency.WithLabelValues(s.name).Observe(start.Sub(receivedAt).Seconds())
+	}
 
 	// Parse the ISO message
 	message := iso8583.NewMessage(s.spec)
-	if err := message.Unpack(messageBytes); err != nil {
+	if err := message.Unpack(frame.Message); err != nil {
 		log.Printf("Error unpacking ISO message: %v", err)
-		return err
+		return s.writeErrorResponse(c, frame, message, ErrorFormat)
 	}
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(s.baseCtx, 10*time.Second)
 	defer cancel()
+	ctx = ContextWithSession(ctx, c.session)
+	ctx = context.WithValue(ctx, receivedAtKey{}, receivedAt)
 
 	// Pass to handler
 	responseMessage, err := s.handler.HandleMessage(ctx, message)
 	if err != nil {
 		log.Printf("Error handling message: %v", err)
-		return err
+		return s.writeErrorResponse(c, frame, message, ErrorClass(err))
+	}
+	if responseMessage == nil {
+		log.Printf("Handler returned no response")
+		return s.writeErrorResponse(c, frame, message, ErrorSystem)
 	}
 
 	// Pack the response
 	responseBytes, err := responseMessage.Pack()
 	if err != nil {
 		log.Printf("Error packing response message: %v", err)
-		return err
+		return s.writeErrorResponse(c, frame, message, ErrorSystem)
 	}
 
-	// Create length-prefixed message
-	responseLengthBytes := []byte{byte(len(responseBytes) / 256), byte(len(responseBytes) % 256)}
-	fullResponse := append(responseLengthBytes, responseBytes...)
-
-	// Send the response
-	if _, err := conn.Write(fullResponse); err != nil {
+	// Send the framed response
+	if err := c.writeFrame(responseBytes, frame); err != nil {
 		log.Printf("Error writing response: %v", err)
 		return err
 	}
@@ -192,3 +380,33 @@ func (s *Server) processMessage(conn net.Conn, messageBytes []byte) error {
 
 	return nil
 }
+
+// writeErrorResponse answers a request that could not be processed with the
+// response code configured for the error class
+func (s *Server) writeErrorResponse(c *connection, frame *Frame, request *iso8583.Message, class string) error {
+	responseCode, ok := s.errorResponseCodes[class]
+	if !ok {
+		responseCode = s.errorResponseCodes[ErrorSystem]
+	}
+
+	response, err := NewResponse(request, responseCode)
+	if err != nil {
+		// Without an MTI there is nothing the terminal could match a response to
+		log.Printf("Unable to build %s error response from %s: %v", class, c.remoteAddr, err)
+		return nil
+	}
+
+	responseBytes, err := response.Pack()
+	if err != nil {
+		log.Printf("Error packing %s error response: %v", class, err)
+		return nil
+	}
+
+	if err := c.writeFrame(responseBytes, frame); err != nil {
+		log.Printf("Error writing response: %v", err)
+		return err
+	}
+
+	log.Printf("Sent %s error response with code %s to %s", class, responseCode, c.remoteAddr)
+	return nil
+}
@@ -0,0 +1,28 @@
+package issuer
+
+import (
+	"embed"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// builtinRules holds the rule configurations of the built-in issuers
+//
+//go:embed builtin/*.yaml
+var builtinRules embed.FS
+
+// builtinConfig reads the rule configuration of a built-in issuer from its
+// embedded YAML file
+func builtinConfig(file string) (RuleIssuerConfig, error) {
+	data, err := builtinRules.ReadFile("builtin/" + file)
+	if err != nil {
+		return RuleIssuerConfig{}, fmt.Errorf("failed to read built-in issuer rules %s: %w", file, err)
+	}
+
+	var config RuleIssuerConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return RuleIssuerConfig{}, fmt.Errorf("failed to parse built-in issuer rules %s: %w", file, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,216 @@
+package issuer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Card statuses
+const (
+	CardActive  = "active"
+	CardBlocked = "blocked"
+	CardLost    = "lost"
+	CardStolen  = "stolen"
+)
+
+// Response codes of the card checks
+const (
+	lostCardCode         = "41" // Lost card, pick up
+	stolenCardCode       = "43" // Stolen card, pick up
+	expiredCardCode      = "54"
+	incorrectPINCode     = "55"
+	suspectedFraudCode   = "59"
+	restrictedCardCode   = "62"
+	cardVerificationCode = "82" // Card verification value does not match
+)
+
+// statusCodes are the response codes of the cards that are not active
+var statusCodes = map[string]string{
+	CardBlocked: restrictedCardCode,
+	CardLost:    lostCardCode,
+	CardStolen:  stolenCardCode,
+}
+
+// CardRegistryConfig holds card registry configuration
+type CardRegistryConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// SeedFile is a YAML file of the cards the issuers know
+	SeedFile string `yaml:"seed_file"`
+}
+
+// Card is a card the issuers have issued
+type Card struct {
+	PAN string `yaml:"pan"`
+	// Status is active, blocked, lost or stolen (default: active)
+	Status string `yaml:"status"`
+	// Expiry is the expiration date as YYMM. The card is valid through the
+	// last day of that month.
+	Expiry string `yaml:"expiry"`
+	// ServiceCode is the service code encoded on the card, e.g. 201. A third
+	// digit of 0, 3 or 5 requires a PIN for card-present requests.
+	ServiceCode string `yaml:"service_code"`
+	// PIN and CVV are the values PIN blocks and card verification values are
+	// checked against. They are test values kept in the clear.
+	PIN string `yaml:"pin"`
+	CVV string `yaml:"cvv"`
+}
+
+// CardRegistry holds the cards the issuers check requests against
+type CardRegistry struct {
+	mutex sync.RWMutex
+	cards map[string]*Card
+}
+
+// NewCardRegistry creates a card registry, loading the cards of the seed file
+// when one is configured
+func NewCardRegistry(config CardRegistryConfig) (*CardRegistry, error) {
+	registry := &CardRegistry{cards: make(map[string]*Card)}
+	if config.SeedFile != "" {
+		if err := registry.LoadSeed(config.SeedFile); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// LoadSeed adds the cards of a YAML seed file
+func (r *CardRegistry) LoadSeed(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read card seed file: %w", err)
+	}
+
+	var seed struct {
+		Cards []Card `yaml:"cards"`
+	}
+	if err := yaml.Unmarshal(data, &seed); err != nil {
+		return fmt.Errorf("failed to parse card seed file: %w", err)
+	}
+	for _, card := range seed.Cards {
+		if err := r.Add(card); err != nil {
+			return fmt.Errorf("failed to seed card %s: %w", maskPAN(card.PAN), err)
+		}
+	}
+	return nil
+}
+
+// Add adds a card to the registry, replacing a card with the same PAN
+func (r *CardRegistry) Add(card Card) error {
+	if card.Status == "" {
+		card.Status = CardActive
+	}
+	if err := card.validate(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cards[card.PAN] = &card
+	return nil
+}
+
+// SetStatus changes the status of a card, e.g. when it is reported lost
+func (r *CardRegistry) SetStatus(pan, status string) error {
+	if status != CardActive && statusCodes[status] == "" {
+		return fmt.Errorf("unknown card status %q", status)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	card, ok := r.cards[pan]
+	if !ok {
+		return fmt.Errorf("card %s not found", maskPAN(pan))
+	}
+	card.Status = status
+	return nil
+}
+
+// Card returns the card with a PAN
+func (r *CardRegistry) Card(pan string) (Card, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	card, ok := r.cards[pan]
+	if !ok {
+		return Card{}, false
+	}
+	return *card, true
+}
+
+// validate checks the status, expiration date and service code of a card
+func (c Card) validate() error {
+	if c.PAN == "" {
+		return fmt.Errorf("card without a PAN")
+	}
+	if c.Status != CardActive && statusCodes[c.Status] == "" {
+		return fmt.Errorf("unknown card status %q", c.Status)
+	}
+	if _, err := expiresAt(c.Expiry); err != nil {
+		return err
+	}
+	if len(c.ServiceCode) != 3 || strings.Trim(c.ServiceCode, "0123456789") != "" {
+		return fmt.Errorf("service code %q must have 3 digits", c.ServiceCode)
+	}
+	return nil
+}
+
+// check verifies the card of a request: its status, expiration date, service
+// code, PIN and card verification value. It returns the approval code, or the
+// response code and reason of the first check that fails.
+func (r *CardRegistry) check(req *proto.AuthRequest, now time.Time) (string, string) {
+	card, ok := r.Card(req.Pan)
+	if !ok {
+		return invalidCardCode, "unknown card"
+	}
+
+	if code, ok := statusCodes[card.Status]; ok {
+		return code, "card is " + card.Status
+	}
+
+	// Expiration dates were checked when the card was added
+	if expiry, _ := expiresAt(card.Expiry); !now.Before(expiry) {
+		return expiredCardCode, "card expired"
+	}
+	if req.ExpirationDate != "" && req.ExpirationDate != card.Expiry {
+		return expiredCardCode, "expiration date does not match"
+	}
+
+	// Track data with another service code was not encoded by the issuer
+	if req.ServiceCode != "" && req.ServiceCode != card.ServiceCode {
+		return suspectedFraudCode, "service code does not match"
+	}
+
+	if req.PinBlock != "" {
+		if pin, err := decodePINBlock(req.PinBlock, req.Pan); err != nil || pin != card.PIN {
+			return incorrectPINCode, "incorrect PIN"
+		}
+	} else if req.ServiceCode != "" && strings.ContainsRune("035", rune(card.ServiceCode[2])) {
+		return incorrectPINCode, "PIN required by the service code"
+	}
+
+	if req.Cvv != "" && req.Cvv != card.CVV {
+		return cardVerificationCode, "card verification value does not match"
+	}
+
+	return approvedCode, ""
+}
+
+// expiresAt returns when a card with the expiration date YYMM expires: the
+// start of the month after the expiration date
+func expiresAt(expiry string) (time.Time, error) {
+	if len(expiry) != 4 || strings.Trim(expiry, "0123456789") != "" {
+		return time.Time{}, fmt.Errorf("expiration date %q must be YYMM", expiry)
+	}
+	year, _ := strconv.Atoi(expiry[:2])
+	month, _ := strconv.Atoi(expiry[2:])
+	if month < 1 || month > 12 {
+		return time.Time{}, fmt.Errorf("expiration date %q must be YYMM", expiry)
+	}
+	return time.Date(2000+year, time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC), nil
+}
@@ -0,0 +1,19 @@
+package issuer
+
+import (
+	"github.com/TFMV/pulse/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// RegisterHealth registers the standard grpc.health.v1 service on server and
+// reports the server and the auth service as serving. Call Shutdown on the
+// returned server before stopping so routers stop sending requests.
+func RegisterHealth(server *grpc.Server) *health.Server {
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(proto.AuthService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	return healthServer
+}
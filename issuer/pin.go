@@ -0,0 +1,72 @@
+package issuer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// pinBlockLength is the length of an ISO 9564 PIN block in bytes
+const pinBlockLength = 8
+
+// EncodePINBlock returns the ISO 9564 format 0 PIN block of a PIN and PAN in
+// hex, as carried in field 52. Pulse exchanges PIN blocks in the clear for
+// local testing; they are not encrypted under a PIN key.
+func EncodePINBlock(pin, pan string) (string, error) {
+	if len(pin) < 4 || len(pin) > 12 || strings.Trim(pin, "0123456789") != "" {
+		return "", fmt.Errorf("PIN must have 4 to 12 digits")
+	}
+	pinField, err := hex.DecodeString(fmt.Sprintf("0%X%s", len(pin), pin) + strings.Repeat("F", 14-len(pin)))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode PIN field: %w", err)
+	}
+	panField, err := panBlock(pan)
+	if err != nil {
+		return "", err
+	}
+
+	block := make([]byte, pinBlockLength)
+	for i := range block {
+		block[i] = pinField[i] ^ panField[i]
+	}
+	return strings.ToUpper(hex.EncodeToString(block)), nil
+}
+
+// decodePINBlock returns the PIN of an ISO 9564 format 0 PIN block in hex
+func decodePINBlock(pinBlock, pan string) (string, error) {
+	block, err := hex.DecodeString(pinBlock)
+	if err != nil || len(block) != pinBlockLength {
+		return "", fmt.Errorf("PIN block must have %d bytes", pinBlockLength)
+	}
+	panField, err := panBlock(pan)
+	if err != nil {
+		return "", err
+	}
+
+	for i := range block {
+		block[i] ^= panField[i]
+	}
+	pinField := hex.EncodeToString(block)
+	length := int(block[0] & 0x0F)
+	if block[0]>>4 != 0 || length < 4 || length > 12 {
+		return "", fmt.Errorf("PIN block is not in format 0")
+	}
+	pin := pinField[2 : 2+length]
+	if strings.Trim(pin, "0123456789") != "" || strings.Trim(pinField[2+length:], "f") != "" {
+		return "", fmt.Errorf("PIN block is not in format 0")
+	}
+	return pin, nil
+}
+
+// panBlock returns the PAN field of a format 0 PIN block: four zeros and the
+// 12 rightmost digits of the PAN excluding the check digit
+func panBlock(pan string) ([]byte, error) {
+	if len(pan) < 13 || strings.Trim(pan, "0123456789") != "" {
+		return nil, fmt.Errorf("PAN must have at least 13 digits")
+	}
+	field, err := hex.DecodeString("0000" + pan[len(pan)-13:len(pan)-1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode PAN field: %w", err)
+	}
+	return field, nil
+}
@@ -0,0 +1,455 @@
+package issuer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TFMV/pulse/iso"
+	"github.com/TFMV/pulse/ledger"
+	"github.com/TFMV/pulse/proto"
+)
+
+// Rule condition fields
+const (
+	FieldMTI              = "mti"
+	FieldPAN              = "pan"
+	FieldAmount           = "amount"
+	FieldSTAN             = "stan"
+	FieldTransmissionTime = "transmission_time"
+	FieldTransmissionHour = "transmission_hour" // Hour of the transmission time (MMDDhhmmss), 0-23
+	FieldMessageClass     = "message_class"     // authorization or financial
+	FieldRegion           = "region"
+)
+
+// Rule condition operators
+const (
+	OpEq     = "eq"
+	OpNe     = "ne"
+	OpGt     = "gt"
+	OpGte    = "gte"
+	OpLt     = "lt"
+	OpLte    = "lte"
+	OpPrefix = "prefix"
+	OpSuffix = "suffix"
+	OpIn     = "in"
+)
+
+// Response codes the rule issuer gives outside of its rules
+const (
+	approvedCode           = "00"
+	invalidTransactionCode = "12"
+	invalidCardCode        = "14"
+	insufficientFundsCode  = "51"
+)
+
+// RuleIssuerConfig configures an issuer whose authorization decisions come
+// from an ordered list of rules
+type RuleIssuerConfig struct {
+	// Name prefixes the issuer's log lines, e.g. US-EAST
+	Name string `yaml:"name"`
+	// Currency is the symbol amounts are logged with, e.g. $
+	Currency string `yaml:"currency"`
+	// MinLatency and MaxLatency bound the simulated processing time
+	MinLatency time.Duration `yaml:"min_latency"`
+	MaxLatency time.Duration `yaml:"max_latency"`
+	// Rules are evaluated in order and the first matching rule decides.
+	// Requests no rule matches are approved.
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule declines or approves the requests that meet all of its conditions
+type Rule struct {
+	Name string      `yaml:"name"`
+	When []Condition `yaml:"when"`
+	// ResponseCode answers the matching requests, e.g. 05
+	ResponseCode string `yaml:"response_code"`
+	// Reason is logged with the decision
+	Reason string `yaml:"reason"`
+}
+
+// Condition compares a field of the authorization request with a value.
+// Amounts and transmission hours are compared as numbers, other fields as
+// strings.
+type Condition struct {
+	Field string `yaml:"field"`
+	Op    string `yaml:"op"`
+	// Value is compared with the field for every operator but in
+	Value string `yaml:"value"`
+	// Values lists the values the field may have for the in operator
+	Values []string `yaml:"values"`
+}
+
+// numericFields are the fields compared as numbers
+var numericFields = map[string]bool{FieldAmount: true, FieldTransmissionHour: true}
+
+// compiledCondition is a condition with its numeric value parsed
+type compiledCondition struct {
+	Condition
+	number  float64
+	numbers []float64
+}
+
+// compiledRule is a rule with its conditions compiled
+type compiledRule struct {
+	Rule
+	when []compiledCondition
+}
+
+// RuleIssuer implements the authorization service with configured rules
+type RuleIssuer struct {
+	proto.UnimplementedAuthServiceServer
+	config RuleIssuerConfig
+	rules  []compiledRule
+	cards  *CardRegistry
+	ledger *ledger.Ledger
+}
+
+// NewRuleIssuer creates an issuer that decides with the rules of config. When
+// cards is not nil, requests must first pass the checks of the card they are
+// made with. When accounts is not nil, approvals must also be funded by the
+// cardholder's account, which holds or captures the funds.
+func NewRuleIssuer(config RuleIssuerConfig, cards *CardRegistry, accounts *ledger.Ledger) (*RuleIssuer, error) {
+	if config.MaxLatency < config.MinLatency {
+		return nil, fmt.Errorf("issuer %s max latency %v is below its min latency %v",
+			config.Name, config.MaxLatency, config.MinLatency)
+	}
+
+	rules := make([]compiledRule, 0, len(config.Rules))
+	for _, rule := range config.Rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile rule %q of issuer %s: %w", rule.Name, config.Name, err)
+		}
+		rules = append(rules, compiled)
+	}
+
+	return &RuleIssuer{config: config, rules: rules, cards: cards, ledger: accounts}, nil
+}
+
+// compileRule checks the response code and conditions of a rule and parses
+// its numeric values
+func compileRule(rule Rule) (compiledRule, error) {
+	if len(rule.ResponseCode) != 2 {
+		return compiledRule{}, fmt.Errorf("response code %q must have 2 characters", rule.ResponseCode)
+	}
+
+	compiled := compiledRule{Rule: rule, when: make([]compiledCondition, 0, len(rule.When))}
+	for _, condition := range rule.When {
+		switch condition.Field {
+		case FieldMTI, FieldPAN, FieldAmount, FieldSTAN, FieldTransmissionTime,
+			FieldTransmissionHour, FieldMessageClass, FieldRegion:
+		default:
+			return compiledRule{}, fmt.Errorf("unknown field %q", condition.Field)
+		}
+
+		numeric := numericFields[condition.Field]
+		c := compiledCondition{Condition: condition}
+		switch condition.Op {
+		case OpGt, OpGte, OpLt, OpLte:
+			if !numeric {
+				return compiledRule{}, fmt.Errorf("operator %s needs a numeric field, not %s", condition.Op, condition.Field)
+			}
+			fallthrough
+		case OpEq, OpNe:
+			if numeric {
+				number, err := strconv.ParseFloat(condition.Value, 64)
+				if err != nil {
+					return compiledRule{}, fmt.Errorf("invalid %s value %q: %w", condition.Field, condition.Value, err)
+				}
+				c.number = number
+			}
+		case OpPrefix, OpSuffix:
+			if numeric {
+				return compiledRule{}, fmt.Errorf("operator %s needs a string field, not %s", condition.Op, condition.Field)
+			}
+		case OpIn:
+			if len(condition.Values) == 0 {
+				return compiledRule{}, fmt.Errorf("operator in needs values for %s", condition.Field)
+			}
+			if numeric {
+				for _, value := range condition.Values {
+					number, err := strconv.ParseFloat(value, 64)
+					if err != nil {
+						return compiledRule{}, fmt.Errorf("invalid %s value %q: %w", condition.Field, value, err)
+					}
+					c.numbers = append(c.numbers, number)
+				}
+			}
+		default:
+			return compiledRule{}, fmt.Errorf("unknown operator %q", condition.Op)
+		}
+		compiled.when = append(compiled.when, c)
+	}
+	return compiled, nil
+}
+
+// ProcessAuth processes an authorization request
+func (i *RuleIssuer) ProcessAuth(ctx context.Context, req *proto.AuthRequest) (*proto.AuthResponse, error) {
+	start := time.Now()
+	log.Printf("[%s] Processing auth request for PAN %s, STAN %s", i.config.Name, maskPAN(req.Pan), req.Stan)
+
+	// Simulate processing time
+	processingTime := i.config.MinLatency
+	if spread := i.config.MaxLatency - i.config.MinLatency; spread > 0 {
+		processingTime += time.Duration(rand.Int63n(int64(spread)))
+	}
+	time.Sleep(processingTime)
+
+	// Create the response
+	responseMti, err := iso.ResponseMTI(req.Mti)
+	if err != nil {
+		responseMti = "0110"
+	}
+	resp := &proto.AuthResponse{
+		Mti:              responseMti,
+		Pan:              req.Pan,
+		Amount:           req.Amount,
+		TransmissionTime: req.TransmissionTime,
+		Stan:             req.Stan,
+		ProcessingTimeMs: time.Since(start).Milliseconds(),
+	}
+
+	// Stand-in advices report approvals made while the issuer was unavailable
+	if req.StandInResponseCode != "" {
+		resp.ResponseCode = approvedCode
+		if i.ledger != nil {
+			// Stand-in approvals are recorded even when the balance falls short
+			record := func(req *proto.AuthRequest) error { return i.reserve(req, true) }
+			if req.MessageClass == proto.MessageClass_MESSAGE_CLASS_COMPLETION {
+				record = i.complete
+			}
+			if err := record(req); err != nil {
+				log.Printf("[%s] Failed to record stand-in advice %s in the ledger: %v", i.config.Name, req.Stan, err)
+			}
+		}
+		log.Printf("[%s] Recorded stand-in advice %s with response code %s", i.config.Name, req.Stan, req.StandInResponseCode)
+		return resp, nil
+	}
+
+	switch req.MessageClass {
+	case proto.MessageClass_MESSAGE_CLASS_REVERSAL:
+		// Reversals release the original authorization
+		resp.ResponseCode = approvedCode
+		if i.ledger != nil && !i.ledger.Reverse(i.config.Name, req.Pan, originalReference(req)) {
+			log.Printf("[%s] No held or captured funds to reverse for transaction %s", i.config.Name, req.OriginalData.GetStan())
+		}
+		log.Printf("[%s] Reversed transaction %s", i.config.Name, req.OriginalData.GetStan())
+		return resp, nil
+
+	case proto.MessageClass_MESSAGE_CLASS_COMPLETION:
+		// Completion advices capture funds the issuer already approved and cannot be declined
+		resp.ResponseCode = approvedCode
+		if i.ledger != nil {
+			if err := i.complete(req); err != nil {
+				log.Printf("[%s] Failed to post completion %s to the ledger: %v", i.config.Name, req.Stan, err)
+			}
+		}
+		log.Printf("[%s] Captured completion %s for authorization %s", i.config.Name, req.Stan, req.OriginalData.GetStan())
+		return resp, nil
+	}
+
+	amount, err := strconv.ParseFloat(req.Amount, 64)
+	if err != nil {
+		resp.ResponseCode = invalidTransactionCode
+		return resp, nil
+	}
+
+	resp.ResponseCode = approvedCode
+	reason := fmt.Sprintf("amount %s%.2f", i.config.Currency, amount)
+
+	// Requests with a card that fails its checks are declined before the rules
+	if i.cards != nil {
+		if code, failure := i.cards.check(req, start); code != approvedCode {
+			resp.ResponseCode, reason = code, failure
+		}
+	}
+
+	// The first matching rule decides, and requests no rule matches are approved
+	if resp.ResponseCode == approvedCode {
+		for _, rule := range i.rules {
+			if rule.matches(req, amount) {
+				resp.ResponseCode, reason = rule.ResponseCode, rule.Reason
+				break
+			}
+		}
+	}
+
+	// Approvals must be funded by the cardholder's account
+	if resp.ResponseCode == approvedCode && i.ledger != nil {
+		switch err := i.reserve(req, false); {
+		case errors.Is(err, ledger.ErrUnknownCard):
+			resp.ResponseCode, reason = invalidCardCode, "card has no account"
+		case errors.Is(err, ledger.ErrInsufficientFunds):
+			resp.ResponseCode, reason = insufficientFundsCode, "insufficient funds"
+		case err != nil:
+			resp.ResponseCode, reason = invalidTransactionCode, err.Error()
+		}
+	}
+
+	if resp.ResponseCode == approvedCode {
+		log.Printf("[%s] Approved transaction %s: %s", i.config.Name, req.Stan, reason)
+	} else {
+		log.Printf("[%s] Declining transaction %s with %s: %s", i.config.Name, req.Stan, resp.ResponseCode, reason)
+	}
+
+	// Financial requests capture the funds as soon as they are approved
+	if resp.ResponseCode == approvedCode && req.MessageClass == proto.MessageClass_MESSAGE_CLASS_FINANCIAL {
+		log.Printf("[%s] Captured transaction %s", i.config.Name, req.Stan)
+	}
+
+	return resp, nil
+}
+
+// reserve holds the funds of an authorization, or captures those of a
+// financial request, in the cardholder's account. Forced funds may exceed the
+// available balance. Shadow copies only check the balance, as their answers
+// are discarded and nothing reverses them.
+func (i *RuleIssuer) reserve(req *proto.AuthRequest, force bool) error {
+	amount, err := ledger.ParseAmount(req.Amount)
+	if err != nil {
+		return err
+	}
+	if req.Shadow {
+		if force {
+			return nil
+		}
+		return i.ledger.Check(req.Pan, amount)
+	}
+	ref := ledger.Reference{Stan: req.Stan, TransmissionTime: req.TransmissionTime}
+	if req.MessageClass == proto.MessageClass_MESSAGE_CLASS_FINANCIAL {
+		return i.ledger.Capture(i.config.Name, req.Pan, ref, amount, force)
+	}
+	return i.ledger.Hold(i.config.Name, req.Pan, ref, amount, force)
+}
+
+// complete converts the hold of the authorization a completion refers to into
+// a posting of the completed amount
+func (i *RuleIssuer) complete(req *proto.AuthRequest) error {
+	amount, err := ledger.ParseAmount(req.Amount)
+	if err != nil {
+		return err
+	}
+	return i.ledger.Complete(i.config.Name, req.Pan, originalReference(req), req.Stan, amount)
+}
+
+// originalReference identifies the transaction a follow-up message refers to
+// in the ledger
+func originalReference(req *proto.AuthRequest) ledger.Reference {
+	return ledger.Reference{
+		Stan:             req.OriginalData.GetStan(),
+		TransmissionTime: req.OriginalData.GetTransmissionTime(),
+	}
+}
+
+// matches reports whether a request meets all conditions of the rule
+func (r compiledRule) matches(req *proto.AuthRequest, amount float64) bool {
+	for _, condition := range r.when {
+		if !condition.matches(req, amount) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether a request meets the condition
+func (c compiledCondition) matches(req *proto.AuthRequest, amount float64) bool {
+	if numericFields[c.Field] {
+		var value float64
+		switch c.Field {
+		case FieldAmount:
+			value = amount
+		case FieldTransmissionHour:
+			hour, ok := transmissionHour(req.TransmissionTime)
+			if !ok {
+				return false
+			}
+			value = float64(hour)
+		}
+
+		switch c.Op {
+		case OpEq:
+			return value == c.number
+		case OpNe:
+			return value != c.number
+		case OpGt:
+			return value > c.number
+		case OpGte:
+			return value >= c.number
+		case OpLt:
+			return value < c.number
+		case OpLte:
+			return value <= c.number
+		case OpIn:
+			for _, number := range c.numbers {
+				if value == number {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	value := fieldValue(req, c.Field)
+	switch c.Op {
+	case OpEq:
+		return value == c.Value
+	case OpNe:
+		return value != c.Value
+	case OpPrefix:
+		return strings.HasPrefix(value, c.Value)
+	case OpSuffix:
+		return strings.HasSuffix(value, c.Value)
+	case OpIn:
+		for _, candidate := range c.Values {
+			if value == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldValue returns a string field of the request
+func fieldValue(req *proto.AuthRequest, field string) string {
+	switch field {
+	case FieldMTI:
+		return req.Mti
+	case FieldPAN:
+		return req.Pan
+	case FieldSTAN:
+		return req.Stan
+	case FieldTransmissionTime:
+		return req.TransmissionTime
+	case FieldMessageClass:
+		return strings.ToLower(strings.TrimPrefix(req.MessageClass.String(), "MESSAGE_CLASS_"))
+	case FieldRegion:
+		return req.Region
+	}
+	return ""
+}
+
+// transmissionHour returns the hour of a transmission time (MMDDhhmmss)
+func transmissionHour(timestamp string) (int, bool) {
+	if len(timestamp) < 10 {
+		return 0, false
+	}
+	hour, err := strconv.Atoi(timestamp[4:6])
+	if err != nil {
+		return 0, false
+	}
+	return hour, true
+}
+
+// maskPAN masks the PAN for logging, e.g., 4111111111111111 -> 411111******1111
+func maskPAN(pan string) string {
+	if len(pan) <= 10 {
+		return pan
+	}
+	return fmt.Sprintf("%s******%s", pan[:6], pan[len(pan)-4:])
+}
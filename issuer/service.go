@@ -48,14 +48,15 @@ func (w *wrappedIssuer) GetTransaction(ctx context.Context, req *proto.GetTransa
 		return nil, fmt.Errorf("storage is not configured")
 	}
 
-	record, err := w.storage.GetTransaction(ctx, req.Stan)
+	key := storage.NewTransactionKey(req.Stan, req.TransmissionTime, req.AcquirerId, req.TerminalId)
+	record, err := w.storage.GetTransaction(ctx, key)
 	if err != nil {
-		log.Printf("Failed to retrieve transaction %s: %v", req.Stan, err)
+		log.Printf("Failed to retrieve transaction %s: %v", key, err)
 		return nil, fmt.Errorf("failed to retrieve transaction: %w", err)
 	}
 
 	if record == nil {
-		log.Printf("Transaction %s not found", req.Stan)
+		log.Printf("Transaction %s not found", key)
 		return nil, fmt.Errorf("transaction not found")
 	}
 
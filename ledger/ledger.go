@@ -0,0 +1,452 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Accounts the ledger moves cardholder funds to and from
+const (
+	// SettlementAccount receives the funds captured from cardholder accounts
+	SettlementAccount = "settlement"
+	// FundingAccount provides the opening balances of cardholder accounts
+	FundingAccount = "funding"
+)
+
+// defaultHoldTTL is how long an authorization hold reduces the available
+// balance when it is neither completed nor reversed
+const defaultHoldTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrUnknownCard is returned for cards that are not linked to an account
+	ErrUnknownCard = errors.New("card is not linked to an account")
+	// ErrInsufficientFunds is returned when the available balance does not
+	// cover an amount
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
+
+// Config holds ledger configuration
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// SeedFile is a YAML file of accounts, their cards and opening balances
+	SeedFile string `yaml:"seed_file"`
+	// HoldTTL is how long an authorization hold lasts (default: 7 days)
+	HoldTTL time.Duration `yaml:"hold_ttl"`
+}
+
+// Balance is the state of an account in minor units
+type Balance struct {
+	// Ledger is the balance of the account's posted entries
+	Ledger int64 `json:"ledger"`
+	// Available is the ledger balance less the active holds
+	Available int64 `json:"available"`
+	// Holds is the number of active holds
+	Holds int `json:"holds"`
+}
+
+// Entry credits (positive amount) or debits (negative amount) an account
+type Entry struct {
+	Account string `json:"account"`
+	Amount  int64  `json:"amount"`
+}
+
+// Posting is a balanced set of entries: its amounts add up to zero
+type Posting struct {
+	ID          int64     `json:"id"`
+	Stan        string    `json:"stan"`
+	Description string    `json:"description"`
+	Entries     []Entry   `json:"entries"`
+	PostedAt    time.Time `json:"posted_at"`
+}
+
+// Reference identifies a transaction by its STAN and transmission date and
+// time (fields 11 and 7), like the router's duplicate detection. STANs alone
+// repeat across terminals and days.
+type Reference struct {
+	Stan             string `json:"stan"`
+	TransmissionTime string `json:"transmission_time"`
+}
+
+// Hold reserves funds of an account for an authorization until it is
+// completed, reversed or expires
+type Hold struct {
+	Reference
+	Account   string    `json:"account"`
+	Amount    int64     `json:"amount"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Issuers are the issuers that approved the authorization
+	Issuers []string `json:"issuers"`
+}
+
+// capture is the posting that captured a transaction's funds and the issuers
+// that approved it
+type capture struct {
+	posting *Posting
+	issuers []string
+}
+
+// account is a ledger account and its active holds by transaction key
+type account struct {
+	balance int64
+	holds   map[string]*Hold
+}
+
+// transactionKey identifies the transaction ref of an account in the holds
+// and captured maps
+func transactionKey(id string, ref Reference) string {
+	return id + "/" + ref.Stan + "/" + ref.TransmissionTime
+}
+
+// Ledger keeps cardholder accounts in double-entry form. Authorizations hold
+// funds, and financial requests and completions post them to the settlement
+// account. Accounts are kept in memory.
+//
+// Issuers in several regions may share a ledger. A transaction approved by
+// more than one of them, such as a hedged request, holds or captures its
+// funds once, and they are released when every approving issuer has reversed
+// it.
+type Ledger struct {
+	mutex    sync.Mutex
+	holdTTL  time.Duration
+	accounts map[string]*account
+	// cards maps PANs to the account they draw on
+	cards map[string]string
+	// holds maps the transaction keys of authorizations to their holds
+	holds map[string]*Hold
+	// captured maps transaction keys to the posting that captured their
+	// funds, so a reversal can refund them
+	captured map[string]*capture
+	postings []*Posting
+}
+
+// NewLedger creates a ledger, loading the accounts of the seed file when one
+// is configured
+func NewLedger(config Config) (*Ledger, error) {
+	holdTTL := config.HoldTTL
+	if holdTTL <= 0 {
+		holdTTL = defaultHoldTTL
+	}
+
+	l := &Ledger{
+		holdTTL: holdTTL,
+		accounts: map[string]*account{
+			SettlementAccount: {holds: make(map[string]*Hold)},
+			FundingAccount:    {holds: make(map[string]*Hold)},
+		},
+		cards:    make(map[string]string),
+		holds:    make(map[string]*Hold),
+		captured: make(map[string]*capture),
+	}
+
+	if config.SeedFile != "" {
+		if err := l.LoadSeed(config.SeedFile); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Open creates an account for cards with an opening balance drawn from the
+// funding account
+func (l *Ledger) Open(id string, cards []string, balance int64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, ok := l.accounts[id]; ok {
+		return fmt.Errorf("account %s already exists", id)
+	}
+	for _, pan := range cards {
+		if existing, ok := l.cards[pan]; ok {
+			return fmt.Errorf("card %s is already linked to account %s", maskPAN(pan), existing)
+		}
+	}
+
+	l.accounts[id] = &account{holds: make(map[string]*Hold)}
+	for _, pan := range cards {
+		l.cards[pan] = id
+	}
+	if balance != 0 {
+		l.post("", "opening balance", FundingAccount, id, balance)
+	}
+	return nil
+}
+
+// Check reports whether the card's account could be charged amount, without
+// holding or posting anything
+func (l *Ledger) Check(pan string, amount int64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	id, ok := l.cards[pan]
+	if !ok {
+		return ErrUnknownCard
+	}
+	if l.available(id) < amount {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+// Hold reserves amount of the card's account for the authorization ref
+// approved by issuer. A forced hold, such as one recording a stand-in
+// approval, may exceed the available balance. Holding the same authorization
+// again replaces its hold, keeping the issuers that approved it before.
+func (l *Ledger) Hold(issuer, pan string, ref Reference, amount int64, force bool) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	id, ok := l.cards[pan]
+	if !ok {
+		return ErrUnknownCard
+	}
+	// A hold replacing the authorization's earlier hold may reuse its funds
+	key := transactionKey(id, ref)
+	available := l.available(id)
+	var issuers []string
+	if existing, ok := l.holds[key]; ok {
+		available += existing.Amount
+		issuers = existing.Issuers
+	}
+	if !force && available < amount {
+		return ErrInsufficientFunds
+	}
+
+	l.release(key)
+	hold := &Hold{
+		Reference: ref,
+		Account:   id,
+		Amount:    amount,
+		ExpiresAt: time.Now().Add(l.holdTTL),
+		Issuers:   addIssuer(issuers, issuer),
+	}
+	l.holds[key] = hold
+	l.accounts[id].holds[key] = hold
+	return nil
+}
+
+// Capture posts amount from the card's account to the settlement account for
+// the financial request ref approved by issuer. A forced capture may exceed
+// the available balance. A financial request that was already captured is
+// not posted again.
+func (l *Ledger) Capture(issuer, pan string, ref Reference, amount int64, force bool) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	id, ok := l.cards[pan]
+	if !ok {
+		return ErrUnknownCard
+	}
+	key := transactionKey(id, ref)
+	if captured, ok := l.captured[key]; ok {
+		captured.issuers = addIssuer(captured.issuers, issuer)
+		return nil
+	}
+	if !force && l.available(id) < amount {
+		return ErrInsufficientFunds
+	}
+
+	l.captured[key] = &capture{
+		posting: l.post(ref.Stan, "financial", id, SettlementAccount, amount),
+		issuers: []string{issuer},
+	}
+	return nil
+}
+
+// Complete converts the hold of the authorization original into a posting
+// of amount to the settlement account, which issuer can reverse. Completions
+// capture funds the issuer already approved, so they are posted even when the
+// hold has expired.
+func (l *Ledger) Complete(issuer, pan string, original Reference, stan string, amount int64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	id, ok := l.cards[pan]
+	if !ok {
+		return ErrUnknownCard
+	}
+
+	key := transactionKey(id, original)
+	l.release(key)
+	l.captured[key] = &capture{
+		posting: l.post(stan, "completion of "+original.Stan, id, SettlementAccount, amount),
+		issuers: []string{issuer},
+	}
+	return nil
+}
+
+// Reverse withdraws issuer's approval of the card's transaction original.
+// Once no issuer's approval is left, the hold is released or the captured
+// funds are refunded. It reports false when issuer has nothing to reverse,
+// such as for a repeated reversal.
+func (l *Ledger) Reverse(issuer, pan string, original Reference) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	id, ok := l.cards[pan]
+	if !ok {
+		return false
+	}
+	key := transactionKey(id, original)
+	if hold, ok := l.holds[key]; ok {
+		issuers, removed := removeIssuer(hold.Issuers, issuer)
+		if !removed {
+			return false
+		}
+		hold.Issuers = issuers
+		if len(issuers) == 0 {
+			l.release(key)
+		}
+		return true
+	}
+
+	captured, ok := l.captured[key]
+	if !ok {
+		return false
+	}
+	issuers, removed := removeIssuer(captured.issuers, issuer)
+	if !removed {
+		return false
+	}
+	captured.issuers = issuers
+	if len(issuers) > 0 {
+		return true
+	}
+	delete(l.captured, key)
+
+	posting := captured.posting
+	refund := &Posting{
+		ID:          int64(len(l.postings) + 1),
+		Stan:        posting.Stan,
+		Description: "reversal of " + original.Stan,
+		Entries:     make([]Entry, len(posting.Entries)),
+		PostedAt:    time.Now(),
+	}
+	for i, entry := range posting.Entries {
+		refund.Entries[i] = Entry{Account: entry.Account, Amount: -entry.Amount}
+		l.accounts[entry.Account].balance -= entry.Amount
+	}
+	l.postings = append(l.postings, refund)
+	return true
+}
+
+// Balance returns the balance of an account
+func (l *Ledger) Balance(id string) (Balance, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	acct, ok := l.accounts[id]
+	if !ok {
+		return Balance{}, fmt.Errorf("account %s not found", id)
+	}
+	available := l.available(id)
+	return Balance{Ledger: acct.balance, Available: available, Holds: len(acct.holds)}, nil
+}
+
+// Account returns the account a card draws on
+func (l *Ledger) Account(pan string) (string, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	id, ok := l.cards[pan]
+	return id, ok
+}
+
+// Postings returns the postings in the order they were made
+func (l *Ledger) Postings() []Posting {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	postings := make([]Posting, len(l.postings))
+	for i, posting := range l.postings {
+		postings[i] = *posting
+		postings[i].Entries = append([]Entry(nil), posting.Entries...)
+	}
+	return postings
+}
+
+// available returns the ledger balance of an account less its active holds,
+// dropping expired holds. It must be called with the mutex held.
+func (l *Ledger) available(id string) int64 {
+	acct := l.accounts[id]
+	available := acct.balance
+	now := time.Now()
+	for key, hold := range acct.holds {
+		if now.After(hold.ExpiresAt) {
+			delete(acct.holds, key)
+			delete(l.holds, key)
+			continue
+		}
+		available -= hold.Amount
+	}
+	return available
+}
+
+// release drops the hold of a transaction key. It must be called with the
+// mutex held.
+func (l *Ledger) release(key string) {
+	if hold, ok := l.holds[key]; ok {
+		delete(l.accounts[hold.Account].holds, key)
+		delete(l.holds, key)
+	}
+}
+
+// addIssuer adds issuer to the issuers that approved a transaction
+func addIssuer(issuers []string, issuer string) []string {
+	for _, existing := range issuers {
+		if existing == issuer {
+			return issuers
+		}
+	}
+	return append(append([]string(nil), issuers...), issuer)
+}
+
+// removeIssuer removes issuer from the issuers that approved a transaction
+// and reports whether it was one of them
+func removeIssuer(issuers []string, issuer string) ([]string, bool) {
+	for i, existing := range issuers {
+		if existing == issuer {
+			return append(append([]string(nil), issuers[:i]...), issuers[i+1:]...), true
+		}
+	}
+	return issuers, false
+}
+
+// post moves amount from one account to another. It must be called with the
+// mutex held.
+func (l *Ledger) post(stan, description, from, to string, amount int64) *Posting {
+	posting := &Posting{
+		ID:          int64(len(l.postings) + 1),
+		Stan:        stan,
+		Description: description,
+		Entries:     []Entry{{Account: from, Amount: -amount}, {Account: to, Amount: amount}},
+		PostedAt:    time.Now(),
+	}
+	l.accounts[from].balance -= amount
+	l.accounts[to].balance += amount
+	l.postings = append(l.postings, posting)
+	return posting
+}
+
+// ParseAmount converts a decimal amount such as "75" or "75.50" to minor units
+func ParseAmount(amount string) (int64, error) {
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", amount, err)
+	}
+	if value < 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, fmt.Errorf("invalid amount %q", amount)
+	}
+	return int64(math.Round(value * 100)), nil
+}
+
+// maskPAN masks the PAN for errors, e.g., 4111111111111111 -> 411111******1111
+func maskPAN(pan string) string {
+	if len(pan) <= 10 {
+		return pan
+	}
+	return fmt.Sprintf("%s******%s", pan[:6], pan[len(pan)-4:])
+}
@@ -0,0 +1,56 @@
+package ledger
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Seed lists the accounts to open in a ledger
+type Seed struct {
+	Accounts []SeedAccount `yaml:"accounts"`
+}
+
+// SeedAccount is an account with its cards and opening balance
+type SeedAccount struct {
+	ID string `yaml:"id"`
+	// Balance is the opening balance as a decimal amount, e.g. "1000.00"
+	Balance string   `yaml:"balance"`
+	Cards   []string `yaml:"cards"`
+}
+
+// LoadSeed opens the accounts of a YAML seed file
+func (l *Ledger) LoadSeed(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read ledger seed file: %w", err)
+	}
+
+	var seed Seed
+	if err := yaml.Unmarshal(data, &seed); err != nil {
+		return fmt.Errorf("failed to parse ledger seed file: %w", err)
+	}
+	return l.Seed(seed)
+}
+
+// Seed opens the accounts of seed
+func (l *Ledger) Seed(seed Seed) error {
+	for _, acct := range seed.Accounts {
+		if acct.ID == "" {
+			return fmt.Errorf("ledger seed account without an ID")
+		}
+		var balance int64
+		if acct.Balance != "" {
+			amount, err := ParseAmount(acct.Balance)
+			if err != nil {
+				return fmt.Errorf("failed to seed account %s: %w", acct.ID, err)
+			}
+			balance = amount
+		}
+		if err := l.Open(acct.ID, acct.Cards, balance); err != nil {
+			return fmt.Errorf("failed to seed account %s: %w", acct.ID, err)
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -10,18 +11,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/TFMV/pulse/chaos"
 	"github.com/TFMV/pulse/client"
 	"github.com/TFMV/pulse/iso"
+	"github.com/TFMV/pulse/ledger"
 	"github.com/TFMV/pulse/metrics"
 	"github.com/TFMV/pulse/router"
+	"github.com/TFMV/pulse/spec"
 	"github.com/TFMV/pulse/storage"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/reflection"
 	"gopkg.in/yaml.v3"
 
@@ -35,6 +40,10 @@ const (
 	defaultIsoAddress     = "0.0.0.0:8583"
 	defaultConfigPath     = "config/routes.yaml"
 	defaultMetricsAddress = "0.0.0.0:9090"
+	defaultAdminAddress   = "127.0.0.1:9091"
+
+	// defaultShutdownTimeout bounds how long in-flight ISO messages may run during shutdown
+	defaultShutdownTimeout = 15 * time.Second
 )
 
 var (
@@ -44,29 +53,75 @@ var (
 	clientServerAddr = flag.String("server", "localhost:8583", "Server address for client mode")
 	injectFaults     = flag.Bool("inject-faults", false, "Enable chaos testing with fault injection")
 	metricsAddr      = flag.String("metrics", defaultMetricsAddress, "Prometheus metrics endpoint address")
+	adminAddr        = flag.String("admin", defaultAdminAddress, "Admin endpoint address (reload, region health and connection stats)")
 	usEastAddr       = flag.String("us-east", "localhost:50051", "US East issuer address")
 	euWestAddr       = flag.String("eu-west", "localhost:50052", "EU West issuer address")
 	chaosFlag        = flag.Bool("chaos", false, "Enable chaos testing")
+	specName         = flag.String("spec", spec.ISO87ASCII, "ISO8583 message spec (built-in name or path to a JSON/YAML spec file)")
+	framing          = flag.String("framing", iso.FramingBinary2, "ISO8583 length framing (binary2, binary2_inclusive, ascii4)")
+	tpdu             = flag.String("tpdu", "", "Hex-encoded 5-byte TPDU header to add to every message (e.g. 6000010000)")
 )
 
 // RegionConfig holds configuration for a region
 type RegionConfig struct {
-	Address string `yaml:"address"`
+	Address string               `yaml:"address"`
+	Breaker router.BreakerConfig `yaml:"breaker"`
+}
+
+// ListenerConfig holds configuration for a single ISO8583 listener
+type ListenerConfig struct {
+	Name        string           `yaml:"name"`
+	Address     string           `yaml:"address"`
+	Spec        string           `yaml:"spec"` // Built-in spec name or path to a JSON/YAML spec file
+	Framing     iso.FramerConfig `yaml:"framing"`
+	MaxInFlight int              `yaml:"max_in_flight"` // Messages processed concurrently per connection
+	// ErrorResponseCodes maps error classes (format, issuer_unavailable, timeout, not_signed_on, system) to response codes
+	ErrorResponseCodes map[string]string `yaml:"error_response_codes"`
+	// NetworkManagement configures 0800 sign-on handling
+	NetworkManagement iso.NetworkConfig `yaml:"network_management"`
+}
+
+// IssuerConfig holds configuration for an issuer service and its rules
+type IssuerConfig struct {
+	Address                 string `yaml:"address"`
+	issuer.RuleIssuerConfig `yaml:",inline"`
 }
 
 // AppConfig holds the complete application configuration
 type AppConfig struct {
 	Iso8583Server struct {
-		Address string `yaml:"address"`
+		Address         string           `yaml:"address"`
+		Spec            string           `yaml:"spec"`
+		Listeners       []ListenerConfig `yaml:"listeners"`
+		ShutdownTimeout time.Duration    `yaml:"shutdown_timeout"` // Time allowed for in-flight messages to finish on shutdown
 	} `yaml:"iso8583_server"`
 
 	Regions map[string]RegionConfig `yaml:"regions"`
 
+	// Issuers are the issuer services to run (default: US East and EU West)
+	Issuers []IssuerConfig `yaml:"issuers"`
+
+	// Cards are the cards the issuers check status, expiry, PIN and CVV against
+	Cards issuer.CardRegistryConfig `yaml:"cards"`
+
+	// Ledger holds the cardholder accounts that fund the issuers' approvals
+	Ledger ledger.Config `yaml:"ledger"`
+
 	Router struct {
-		HealthCheckInterval time.Duration     `yaml:"health_check_interval"`
-		FailoverMap         map[string]string `yaml:"failover_map"`
-		BinRoutes           map[string]string `yaml:"bin_routes"`
-		DefaultRegion       string            `yaml:"default_region"`
+		HealthCheckInterval time.Duration                      `yaml:"health_check_interval"` // Deprecated: use health_check.interval
+		HealthCheck         router.HealthCheckConfig           `yaml:"health_check"`
+		FailoverMap         map[string]string                  `yaml:"failover_map"`
+		BinRoutes           map[string]string                  `yaml:"bin_routes"`
+		DefaultRegion       string                             `yaml:"default_region"`
+		DuplicateDetection  router.DuplicateConfig             `yaml:"duplicate_detection"`
+		StandIn             router.StandInConfig               `yaml:"stand_in"`
+		FailoverChains      map[string][]router.FailoverTarget `yaml:"failover_chains"`
+		Hedging             router.HedgingConfig               `yaml:"hedging"`
+		Retry               router.RetryConfig                 `yaml:"retry"`
+		Shadow              router.ShadowConfig                `yaml:"shadow"`
+		RateLimits          router.RateLimitConfig             `yaml:"rate_limits"`
+		LoadShedding        router.LoadSheddingConfig          `yaml:"load_shedding"`
+		Middleware          []string                           `yaml:"middleware"`
 	} `yaml:"router"`
 
 	Metrics struct {
@@ -99,7 +154,19 @@ func main() {
 
 	// Handle client mode
 	if *clientMode {
-		if err := client.RunInteractiveClient(*clientServerAddr); err != nil {
+		messageSpec, err := spec.Load(*specName)
+		if err != nil {
+			log.Fatalf("Failed to load message spec: %v", err)
+		}
+		framer, err := iso.NewFramer(iso.FramerConfig{Type: *framing, TPDU: *tpdu})
+		if err != nil {
+			log.Fatalf("Failed to configure framing: %v", err)
+		}
+		if err := client.RunInteractiveClient(client.Config{
+			ServerAddr: *clientServerAddr,
+			Spec:       messageSpec,
+			Framer:     framer,
+		}); err != nil {
 			log.Fatalf("Client error: %v", err)
 		}
 		return
@@ -136,41 +203,29 @@ func main() {
 	// Initialize storage if enabled
 	var storageClient storage.Storage
 	if config.Storage.Enabled {
-		var err error
-		spannerClient, err := span.NewStore(ctx, span.Config{
-			ProjectID:  config.Storage.Connection,
-			InstanceID: "pulse-instance",
-			DatabaseID: config.Storage.Database,
-			Enabled:    true,
-		})
-		if err != nil {
-			log.Fatalf("Failed to initialize Spanner storage: %v", err)
-		}
-		defer spannerClient.Close()
-		storageClient = spannerClient
-	}
-
-	// Convert map of RegionConfig to router.RegionConfig
-	routerRegions := make(map[string]router.RegionConfig)
-	for name, cfg := range config.Regions {
-		host, port := parseAddress(cfg.Address)
-		routerRegions[name] = router.RegionConfig{
-			Host:      host,
-			Port:      port,
-			TimeoutMs: 5000, // Default 5 seconds timeout
+		switch config.Storage.Type {
+		case "memory":
+			log.Println("Using in-memory storage")
+			storageClient = storage.NewMemoryStore()
+		case "", "spanner":
+			spannerClient, err := span.NewStore(ctx, span.Config{
+				ProjectID:  config.Storage.Connection,
+				InstanceID: "pulse-instance",
+				DatabaseID: config.Storage.Database,
+				Enabled:    true,
+			})
+			if err != nil {
+				log.Fatalf("Failed to initialize Spanner storage: %v", err)
+			}
+			defer spannerClient.Close()
+			storageClient = spannerClient
+		default:
+			log.Fatalf("Unknown storage type: %s", config.Storage.Type)
 		}
 	}
 
-	// Create router config
-	routerConfig := router.Config{
-		BinRoutes:     config.Router.BinRoutes,
-		DefaultRegion: config.Router.DefaultRegion,
-		Regions:       routerRegions,
-		FailoverMap:   config.Router.FailoverMap,
-	}
-
 	// Initialize the router
-	rt := router.NewRouter(routerConfig, chaosEngine, metricsCollector, storageClient)
+	rt := router.NewRouter(buildRouterConfig(config), chaosEngine, metricsCollector, storageClient)
 	if err := rt.Initialize(); err != nil {
 		log.Fatalf("Failed to initialize router: %v", err)
 	}
@@ -214,11 +269,15 @@ func main() {
 
 		activities := workflow.NewActivities(clients, auditLogger, fraudAnalyzer)
 
-		// We need a function that returns the region based on request
+		// Route workflows with the same BIN table as the router
+		binTable, err := router.NewBinTable(config.Router.BinRoutes)
+		if err != nil {
+			log.Fatalf("Failed to build BIN routing table: %v", err)
+		}
 		getRegionFunc := func(pan string) string {
-			// This is a wrapper to determine region from router's logic
-			// In a real implementation, this would use router's determineRegion
-			// Since that's private, we might just use the default region
+			if region, ok := binTable.Lookup(pan); ok {
+				return region
+			}
 			return config.Router.DefaultRegion
 		}
 
@@ -231,51 +290,234 @@ func main() {
 		defer orchestrator.Close()
 	}
 
-	// Create and start ISO 8583 server
-	isoServer := iso.NewServer(*isoAddress, rt)
-	go func() {
-		if err := isoServer.Start(); err != nil {
-			log.Fatalf("Failed to start ISO 8583 server: %v", err)
+	// Create and start an ISO 8583 server for each listener
+	listeners := config.Iso8583Server.Listeners
+	if len(listeners) == 0 {
+		listenerSpec := config.Iso8583Server.Spec
+		if listenerSpec == "" {
+			listenerSpec = *specName
 		}
-	}()
+		listeners = []ListenerConfig{{
+			Name:    "default",
+			Address: *isoAddress,
+			Spec:    listenerSpec,
+			Framing: iso.FramerConfig{Type: *framing, TPDU: *tpdu},
+		}}
+	}
 
-	// Create issuer instances
-	usEastIssuer := issuer.NewUSEastIssuer()
-	euWestIssuer := issuer.NewEUWestIssuer()
+	isoServers := make([]*iso.Server, 0, len(listeners))
+	for _, listener := range listeners {
+		messageSpec, err := spec.Load(listener.Spec)
+		if err != nil {
+			log.Fatalf("Failed to load message spec for listener %s: %v", listener.Name, err)
+		}
+		framer, err := iso.NewFramer(listener.Framing)
+		if err != nil {
+			log.Fatalf("Failed to configure framing for listener %s: %v", listener.Name, err)
+		}
+		log.Printf("Listener %s using message spec %q", listener.Name, messageSpec.Name)
+
+		isoServer := iso.NewServer(iso.ServerConfig{
+			Name:               listener.Name,
+			Address:            listener.Address,
+			Spec:               messageSpec,
+			Framer:             framer,
+			MaxInFlight:        listener.MaxInFlight,
+			Metrics:            metricsCollector,
+			ErrorResponseCodes: listener.ErrorResponseCodes,
+		}, iso.NewNetworkHandler(listener.NetworkManagement, rt))
+		isoServers = append(isoServers, isoServer)
+		go func(name string) {
+			if err := isoServer.Start(); err != nil {
+				log.Fatalf("Failed to start ISO 8583 server %s: %v", name, err)
+			}
+		}(listener.Name)
+	}
 
-	// Wrap issuers with storage if enabled
-	usEastIssuerWithStorage := issuer.WrapWithStorage(usEastIssuer, storageClient)
-	euWestIssuerWithStorage := issuer.WrapWithStorage(euWestIssuer, storageClient)
+	// Admin endpoints are served apart from the public metrics endpoint
+	adminMux := http.NewServeMux()
 
-	// Create gRPC servers
-	usEastServer := grpc.NewServer()
-	euWestServer := grpc.NewServer()
+	// Expose live connection stats
+	adminMux.HandleFunc("/iso/connections", func(w http.ResponseWriter, r *http.Request) {
+		stats := make([]iso.ServerStats, 0, len(isoServers))
+		for _, isoServer := range isoServers {
+			stats = append(stats, isoServer.Stats())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	})
+
+	// Run the configured issuers, or the built-in US East and EU West issuers
+	issuerConfigs := config.Issuers
+	if len(issuerConfigs) == 0 {
+		usEast, err := issuer.USEastConfig()
+		if err != nil {
+			log.Fatalf("Failed to load US East issuer rules: %v", err)
+		}
+		euWest, err := issuer.EUWestConfig()
+		if err != nil {
+			log.Fatalf("Failed to load EU West issuer rules: %v", err)
+		}
+		issuerConfigs = []IssuerConfig{
+			{Address: *usEastAddr, RuleIssuerConfig: usEast},
+			{Address: *euWestAddr, RuleIssuerConfig: euWest},
+		}
+	}
+	var cards *issuer.CardRegistry
+	if config.Cards.Enabled {
+		var err error
+		if cards, err = issuer.NewCardRegistry(config.Cards); err != nil {
+			log.Fatalf("Failed to create card registry: %v", err)
+		}
+		log.Printf("Loaded cards from %s", config.Cards.SeedFile)
+	}
+	// The issuers share one ledger, so a cardholder's funds are the same in
+	// every region
+	var accounts *ledger.Ledger
+	if config.Ledger.Enabled {
+		var err error
+		if accounts, err = ledger.NewLedger(config.Ledger); err != nil {
+			log.Fatalf("Failed to create ledger: %v", err)
+		}
+		log.Printf("Loaded cardholder accounts from %s", config.Ledger.SeedFile)
+	}
+	issuerServers := make([]*grpc.Server, 0, len(issuerConfigs))
+	issuerHealth := make([]*health.Server, 0, len(issuerConfigs))
+	for _, issuerConfig := range issuerConfigs {
+		ruleIssuer, err := issuer.NewRuleIssuer(issuerConfig.RuleIssuerConfig, cards, accounts)
+		if err != nil {
+			log.Fatalf("Failed to create issuer: %v", err)
+		}
 
-	// Register the services
-	proto.RegisterAuthServiceServer(usEastServer, usEastIssuerWithStorage)
-	proto.RegisterAuthServiceServer(euWestServer, euWestIssuerWithStorage)
+		// Wrap the issuer with storage if enabled and register it
+		issuerServer := grpc.NewServer()
+		proto.RegisterAuthServiceServer(issuerServer, issuer.WrapWithStorage(ruleIssuer, storageClient))
 
-	// Enable reflection on the servers
-	reflection.Register(usEastServer)
-	reflection.Register(euWestServer)
+		// Report issuer health through grpc.health.v1
+		issuerHealth = append(issuerHealth, issuer.RegisterHealth(issuerServer))
+
+		// Enable reflection on the server
+		reflection.Register(issuerServer)
+
+		issuerServers = append(issuerServers, issuerServer)
+		go startGRPCServer(issuerServer, issuerConfig.Address, issuerConfig.Name)
+	}
+
+	// Expose circuit breaker state so on-call can see why traffic moved
+	adminMux.HandleFunc("/health/regions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rt.RegionStatuses())
+	})
+
+	// Reload routing from the config file on request
+	adminMux.HandleFunc("/admin/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := reloadRouter(rt, *configPath); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
 
-	// Start gRPC servers
-	go startGRPCServer(usEastServer, *usEastAddr, "US-East")
-	go startGRPCServer(euWestServer, *euWestAddr, "EU-West")
+	go startAdminServer(*adminAddr, adminMux)
+	log.Printf("Admin server started on %s", *adminAddr)
 
-	// Wait for termination signal
+	// Wait for termination signal, reloading routing on SIGHUP
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if err := reloadRouter(rt, *configPath); err != nil {
+			log.Printf("Failed to reload configuration: %v", err)
+		}
+	}
 
 	log.Println("Shutting down...")
+
+	// Drain the ISO servers first so in-flight authorizations still reach the issuers
+	shutdownTimeout := config.Iso8583Server.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	var wg sync.WaitGroup
+	for _, isoServer := range isoServers {
+		wg.Add(1)
+		go func(isoServer *iso.Server) {
+			defer wg.Done()
+			if err := isoServer.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Error shutting down ISO 8583 server: %v", err)
+			}
+		}(isoServer)
+	}
+	wg.Wait()
+	cancel()
+
 	rt.Close()
-	isoServer.Shutdown()
-	usEastServer.GracefulStop()
-	euWestServer.GracefulStop()
+	for _, healthServer := range issuerHealth {
+		healthServer.Shutdown()
+	}
+	for _, issuerServer := range issuerServers {
+		issuerServer.GracefulStop()
+	}
 	time.Sleep(500 * time.Millisecond)
 }
 
+// buildRouterConfig builds the router configuration from the application configuration
+func buildRouterConfig(config *AppConfig) router.Config {
+	// Convert map of RegionConfig to router.RegionConfig
+	routerRegions := make(map[string]router.RegionConfig)
+	for name, cfg := range config.Regions {
+		host, port := parseAddress(cfg.Address)
+		routerRegions[name] = router.RegionConfig{
+			Host:      host,
+			Port:      port,
+			TimeoutMs: 5000, // Default 5 seconds timeout
+			Breaker:   cfg.Breaker,
+		}
+	}
+
+	healthCheck := config.Router.HealthCheck
+	if healthCheck.Interval <= 0 {
+		healthCheck.Interval = config.Router.HealthCheckInterval
+	}
+
+	return router.Config{
+		BinRoutes:      config.Router.BinRoutes,
+		DefaultRegion:  config.Router.DefaultRegion,
+		Regions:        routerRegions,
+		FailoverMap:    config.Router.FailoverMap,
+		FailoverChains: config.Router.FailoverChains,
+		Duplicates:     config.Router.DuplicateDetection,
+		StandIn:        config.Router.StandIn,
+		HealthCheck:    healthCheck,
+		Hedging:        config.Router.Hedging,
+		Retry:          config.Router.Retry,
+		Shadow:         config.Router.Shadow,
+		RateLimits:     config.Router.RateLimits,
+		LoadShedding:   config.Router.LoadShedding,
+		Middleware:     config.Router.Middleware,
+	}
+}
+
+// reloadRouter reloads the router configuration from the config file. The
+// running configuration is kept when the file is invalid.
+func reloadRouter(rt *router.Router, path string) error {
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	if err := rt.Reload(buildRouterConfig(config)); err != nil {
+		return fmt.Errorf("failed to reload router: %w", err)
+	}
+	return nil
+}
+
 // parseAddress parses a host:port string into separate components
 func parseAddress(address string) (string, int) {
 	host, portStr, err := net.SplitHostPort(address)
@@ -309,6 +551,14 @@ func startMetricsServer(addr string) {
 	}
 }
 
+// startAdminServer starts the admin endpoints. They can reload the routing and
+// expose connection details, so they are bound to localhost by default.
+func startAdminServer(addr string, handler http.Handler) {
+	if err := http.ListenAndServe(addr, handler); err != nil {
+		log.Fatalf("Failed to start admin server: %v", err)
+	}
+}
+
 // loadConfig loads the configuration from a YAML file
 func loadConfig(path string) (*AppConfig, error) {
 	data, err := ioutil.ReadFile(path)
@@ -329,5 +579,10 @@ func loadConfig(path string) (*AppConfig, error) {
 		}
 	}
 
+	// Reject routing that is malformed, ambiguous or refers to unknown regions
+	if err := buildRouterConfig(&config).Validate(); err != nil {
+		return nil, fmt.Errorf("invalid router configuration: %w", err)
+	}
+
 	return &config, nil
 }
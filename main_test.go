@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/TFMV/pulse/issuer"
+	"github.com/TFMV/pulse/ledger"
+)
+
+// TestShippedConfigs loads every configuration file under config/: the
+// application configs with their routing and issuer rules, and the seed files
+// they refer to
+func TestShippedConfigs(t *testing.T) {
+	files, err := filepath.Glob("config/*.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Failed to list config files: %v", err)
+	}
+
+	errs := make(map[string]error)
+	seeds := make(map[string]func(path string) error)
+	for _, file := range files {
+		config, err := loadConfig(file)
+		if err != nil {
+			errs[file] = err
+			continue
+		}
+		for _, issuerConfig := range config.Issuers {
+			if _, err := issuer.NewRuleIssuer(issuerConfig.RuleIssuerConfig, nil, nil); err != nil {
+				t.Errorf("%s: issuer %s: %v", file, issuerConfig.Name, err)
+			}
+		}
+		if seedFile := config.Cards.SeedFile; seedFile != "" {
+			seeds[filepath.Clean(seedFile)] = func(path string) error {
+				_, err := issuer.NewCardRegistry(issuer.CardRegistryConfig{SeedFile: path})
+				return err
+			}
+		}
+		if seedFile := config.Ledger.SeedFile; seedFile != "" {
+			seeds[filepath.Clean(seedFile)] = func(path string) error {
+				_, err := ledger.NewLedger(ledger.Config{SeedFile: path})
+				return err
+			}
+		}
+	}
+
+	for _, file := range files {
+		if load, ok := seeds[file]; ok {
+			if err := load(file); err != nil {
+				t.Errorf("%s: %v", file, err)
+			}
+			continue
+		}
+		if err := errs[file]; err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
@@ -7,10 +7,25 @@ import (
 
 // Metrics holds all the Prometheus metrics for the application
 type Metrics struct {
-	RequestCount       *prometheus.CounterVec
-	ResponseLatency    *prometheus.HistogramVec
-	ErrorCount         *prometheus.CounterVec
-	RegionHealthStatus *prometheus.GaugeVec
+	RequestCount         *prometheus.CounterVec
+	ResponseLatency      *prometheus.HistogramVec
+	ErrorCount           *prometheus.CounterVec
+	RegionHealthStatus   *prometheus.GaugeVec
+	IsoConnections       *prometheus.GaugeVec
+	IsoInFlight          *prometheus.GaugeVec
+	DuplicateCount       *prometheus.CounterVec
+	StandInQueueDepth    *prometheus.GaugeVec
+	FailoverCount        *prometheus.CounterVec
+	CircuitTransitions   *prometheus.CounterVec
+	HedgeCount           *prometheus.CounterVec
+	HedgeWins            *prometheus.CounterVec
+	RetryCount           *prometheus.CounterVec
+	RetryBudgetExhausted *prometheus.CounterVec
+	IsoQueueLatency      *prometheus.HistogramVec
+	RateLimitedCount     *prometheus.CounterVec
+	LoadShedCount        *prometheus.CounterVec
+	ShadowRequests       *prometheus.CounterVec
+	ShadowLatencyDelta   *prometheus.HistogramVec
 }
 
 // NewMetrics creates and registers all metrics
@@ -52,6 +67,143 @@ func NewMetrics() *Metrics {
 			},
 			[]string{"region"},
 		),
+
+		// Track open ISO8583 client connections by listener
+		IsoConnections: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "pulse_iso_connections",
+				Help: "The number of open ISO8583 client connections",
+			},
+			[]string{"listener"},
+		),
+
+		// Track ISO8583 messages being processed by listener
+		IsoInFlight: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "pulse_iso_in_flight_messages",
+				Help: "The number of ISO8583 messages currently being processed",
+			},
+			[]string{"listener"},
+		),
+
+		// Track retransmissions answered with the original response by MTI
+		DuplicateCount: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pulse_duplicate_transmissions_total",
+				Help: "The total number of retransmitted requests answered with the original response",
+			},
+			[]string{"mti"},
+		),
+
+		// Track stand-in advices waiting to be replayed by region
+		StandInQueueDepth: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "pulse_stand_in_queue_depth",
+				Help: "The number of stand-in advices waiting to be sent to the issuer",
+			},
+			[]string{"region"},
+		),
+
+		// Track failovers by the number of failover tiers tried
+		FailoverCount: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pulse_failover_total",
+				Help: "The total number of requests routed away from their primary region",
+			},
+			[]string{"primary_region", "region", "hops"},
+		),
+
+		// Track circuit breaker state changes and their causes
+		CircuitTransitions: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pulse_circuit_transitions_total",
+				Help: "The total number of circuit breaker state changes",
+			},
+			[]string{"region", "from", "to", "cause"},
+		),
+
+		// Track requests hedged to a failover region
+		HedgeCount: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pulse_hedged_requests_total",
+				Help: "The total number of requests also sent to a failover region because the primary was slow",
+			},
+			[]string{"primary_region", "region"},
+		),
+
+		// Track hedged requests answered first by the failover region
+		HedgeWins: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pulse_hedge_wins_total",
+				Help: "The total number of hedged requests answered first by the failover region",
+			},
+			[]string{"primary_region", "region"},
+		),
+
+		// Track retries by the region that failed, the region retried in and the gRPC code
+		RetryCount: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pulse_retries_total",
+				Help: "The total number of issuer calls retried after a transient error",
+			},
+			[]string{"failed_region", "region", "code"},
+		),
+
+		// Track retries refused because the failed region's retry budget was spent
+		RetryBudgetExhausted: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pulse_retry_budget_exhausted_total",
+				Help: "The total number of retries skipped because the region's retry budget was spent",
+			},
+			[]string{"region"},
+		),
+
+		// Track how long read messages wait for their connection's in-flight limit
+		IsoQueueLatency: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "pulse_iso_queue_latency_seconds",
+				Help:    "Time ISO8583 messages wait between being read and being processed",
+				Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14), // From 0.1ms to ~1.6s
+			},
+			[]string{"listener"},
+		),
+
+		// Track requests declined by rate limits by the rule's key
+		RateLimitedCount: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pulse_rate_limited_total",
+				Help: "The total number of requests declined by a rate limit",
+			},
+			[]string{"key"},
+		),
+
+		// Track requests declined by load shedding by the threshold crossed
+		LoadShedCount: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pulse_load_shed_total",
+				Help: "The total number of requests declined because the router was overloaded",
+			},
+			[]string{"reason"},
+		),
+
+		// Track how the shadow region's answers to mirrored requests compare
+		ShadowRequests: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "pulse_shadow_requests_total",
+				Help: "The total number of requests mirrored to a shadow region by outcome",
+			},
+			[]string{"region", "outcome"},
+		),
+
+		// Track how much slower or faster the shadow region answers than the live region
+		ShadowLatencyDelta: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "pulse_shadow_latency_delta_seconds",
+				Help:    "Shadow region latency minus live region latency for mirrored requests",
+				Buckets: []float64{-1, -0.5, -0.25, -0.1, -0.05, -0.025, -0.01, 0, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+			},
+			[]string{"region"},
+		),
 	}
 
 	return m
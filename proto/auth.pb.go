@@ -21,17 +21,86 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// MessageClass distinguishes the financial effect of a request
+type MessageClass int32
+
+const (
+	MessageClass_MESSAGE_CLASS_UNSPECIFIED   MessageClass = 0
+	MessageClass_MESSAGE_CLASS_AUTHORIZATION MessageClass = 1 // 01xx: approve and hold funds
+	MessageClass_MESSAGE_CLASS_FINANCIAL     MessageClass = 2 // 0200: approve and capture funds
+	MessageClass_MESSAGE_CLASS_COMPLETION    MessageClass = 3 // 0220: capture funds held by a prior authorization
+	MessageClass_MESSAGE_CLASS_REVERSAL      MessageClass = 4 // 04xx: release or refund a prior transaction
+)
+
+// Enum value maps for MessageClass.
+var (
+	MessageClass_name = map[int32]string{
+		0: "MESSAGE_CLASS_UNSPECIFIED",
+		1: "MESSAGE_CLASS_AUTHORIZATION",
+		2: "MESSAGE_CLASS_FINANCIAL",
+		3: "MESSAGE_CLASS_COMPLETION",
+		4: "MESSAGE_CLASS_REVERSAL",
+	}
+	MessageClass_value = map[string]int32{
+		"MESSAGE_CLASS_UNSPECIFIED":   0,
+		"MESSAGE_CLASS_AUTHORIZATION": 1,
+		"MESSAGE_CLASS_FINANCIAL":     2,
+		"MESSAGE_CLASS_COMPLETION":    3,
+		"MESSAGE_CLASS_REVERSAL":      4,
+	}
+)
+
+func (x MessageClass) Enum() *MessageClass {
+	p := new(MessageClass)
+	*p = x
+	return p
+}
+
+func (x MessageClass) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MessageClass) Descriptor() protoreflect.EnumDescriptor {
+	return file_auth_proto_enumTypes[0].Descriptor()
+}
+
+func (MessageClass) Type() protoreflect.EnumType {
+	return &file_auth_proto_enumTypes[0]
+}
+
+func (x MessageClass) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MessageClass.Descriptor instead.
+func (MessageClass) EnumDescriptor() ([]byte, []int) {
+	return file_auth_proto_rawDescGZIP(), []int{0}
+}
+
 // AuthRequest represents an ISO8583 authorization request converted to protobuf
 type AuthRequest struct {
-	state            protoimpl.MessageState `protogen:"open.v1"`
-	Mti              string                 `protobuf:"bytes,1,opt,name=mti,proto3" json:"mti,omitempty"`                                                   // Message Type Indicator (0100 for auth request)
-	Pan              string                 `protobuf:"bytes,2,opt,name=pan,proto3" json:"pan,omitempty"`                                                   // Primary Account Number (Field 2)
-	Amount           string                 `protobuf:"bytes,3,opt,name=amount,proto3" json:"amount,omitempty"`                                             // Transaction Amount (Field 4)
-	TransmissionTime string                 `protobuf:"bytes,4,opt,name=transmission_time,json=transmissionTime,proto3" json:"transmission_time,omitempty"` // Transmission Timestamp (Field 7)
-	Stan             string                 `protobuf:"bytes,5,opt,name=stan,proto3" json:"stan,omitempty"`                                                 // System Trace Audit Number (Field 11)
-	Region           string                 `protobuf:"bytes,6,opt,name=region,proto3" json:"region,omitempty"`                                             // Region where the request is routed
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Mti                 string                 `protobuf:"bytes,1,opt,name=mti,proto3" json:"mti,omitempty"`                                                                // Message Type Indicator (0100, 0200, 0220, 0400 or 0420)
+	Pan                 string                 `protobuf:"bytes,2,opt,name=pan,proto3" json:"pan,omitempty"`                                                                // Primary Account Number (Field 2)
+	Amount              string                 `protobuf:"bytes,3,opt,name=amount,proto3" json:"amount,omitempty"`                                                          // Transaction Amount (Field 4)
+	TransmissionTime    string                 `protobuf:"bytes,4,opt,name=transmission_time,json=transmissionTime,proto3" json:"transmission_time,omitempty"`              // Transmission Timestamp (Field 7)
+	Stan                string                 `protobuf:"bytes,5,opt,name=stan,proto3" json:"stan,omitempty"`                                                              // System Trace Audit Number (Field 11)
+	Region              string                 `protobuf:"bytes,6,opt,name=region,proto3" json:"region,omitempty"`                                                          // Region where the request is routed
+	OriginalData        *OriginalData          `protobuf:"bytes,7,opt,name=original_data,json=originalData,proto3" json:"original_data,omitempty"`                          // Original data elements for reversals and completions (Field 90)
+	MessageClass        MessageClass           `protobuf:"varint,8,opt,name=message_class,json=messageClass,proto3,enum=pulse.MessageClass" json:"message_class,omitempty"` // What the issuer is asked to do with the funds
+	StandInResponseCode string                 `protobuf:"bytes,9,opt,name=stand_in_response_code,json=standInResponseCode,proto3" json:"stand_in_response_code,omitempty"` // Response code given by stand-in processing (0120 advices)
+	PrimaryRegion       string                 `protobuf:"bytes,10,opt,name=primary_region,json=primaryRegion,proto3" json:"primary_region,omitempty"`                      // Region the BIN routes to before failover
+	FailoverHops        int32                  `protobuf:"varint,11,opt,name=failover_hops,json=failoverHops,proto3" json:"failover_hops,omitempty"`                        // Failover tiers tried to reach the region (0 = primary region)
+	FailoverReason      string                 `protobuf:"bytes,12,opt,name=failover_reason,json=failoverReason,proto3" json:"failover_reason,omitempty"`                   // Why the request left its primary region (empty = primary region)
+	ExpirationDate      string                 `protobuf:"bytes,13,opt,name=expiration_date,json=expirationDate,proto3" json:"expiration_date,omitempty"`                   // Card expiration date, YYMM (Field 14 or Track 2 Data)
+	ServiceCode         string                 `protobuf:"bytes,14,opt,name=service_code,json=serviceCode,proto3" json:"service_code,omitempty"`                            // Card service code (Field 40 or Track 2 Data)
+	PinBlock            string                 `protobuf:"bytes,15,opt,name=pin_block,json=pinBlock,proto3" json:"pin_block,omitempty"`                                     // ISO 9564 format 0 PIN block in hex (Field 52)
+	Cvv                 string                 `protobuf:"bytes,16,opt,name=cvv,proto3" json:"cvv,omitempty"`                                                               // Card verification value printed on the card (Field 48)
+	AcquirerId          string                 `protobuf:"bytes,17,opt,name=acquirer_id,json=acquirerId,proto3" json:"acquirer_id,omitempty"`                               // Acquiring Institution ID (Field 32)
+	TerminalId          string                 `protobuf:"bytes,18,opt,name=terminal_id,json=terminalId,proto3" json:"terminal_id,omitempty"`                               // Card Acceptor Terminal ID (Field 41)
+	Shadow              bool                   `protobuf:"varint,19,opt,name=shadow,proto3" json:"shadow,omitempty"`                                                        // Mirrored copy whose answer is discarded, so it moves no funds
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
 }
 
 func (x *AuthRequest) Reset() {
@@ -106,10 +175,178 @@ func (x *AuthRequest) GetRegion() string {
 	return ""
 }
 
+func (x *AuthRequest) GetOriginalData() *OriginalData {
+	if x != nil {
+		return x.OriginalData
+	}
+	return nil
+}
+
+func (x *AuthRequest) GetMessageClass() MessageClass {
+	if x != nil {
+		return x.MessageClass
+	}
+	return MessageClass_MESSAGE_CLASS_UNSPECIFIED
+}
+
+func (x *AuthRequest) GetStandInResponseCode() string {
+	if x != nil {
+		return x.StandInResponseCode
+	}
+	return ""
+}
+
+func (x *AuthRequest) GetPrimaryRegion() string {
+	if x != nil {
+		return x.PrimaryRegion
+	}
+	return ""
+}
+
+func (x *AuthRequest) GetFailoverHops() int32 {
+	if x != nil {
+		return x.FailoverHops
+	}
+	return 0
+}
+
+func (x *AuthRequest) GetFailoverReason() string {
+	if x != nil {
+		return x.FailoverReason
+	}
+	return ""
+}
+
+func (x *AuthRequest) GetExpirationDate() string {
+	if x != nil {
+		return x.ExpirationDate
+	}
+	return ""
+}
+
+func (x *AuthRequest) GetServiceCode() string {
+	if x != nil {
+		return x.ServiceCode
+	}
+	return ""
+}
+
+func (x *AuthRequest) GetPinBlock() string {
+	if x != nil {
+		return x.PinBlock
+	}
+	return ""
+}
+
+func (x *AuthRequest) GetCvv() string {
+	if x != nil {
+		return x.Cvv
+	}
+	return ""
+}
+
+func (x *AuthRequest) GetAcquirerId() string {
+	if x != nil {
+		return x.AcquirerId
+	}
+	return ""
+}
+
+func (x *AuthRequest) GetTerminalId() string {
+	if x != nil {
+		return x.TerminalId
+	}
+	return ""
+}
+
+func (x *AuthRequest) GetShadow() bool {
+	if x != nil {
+		return x.Shadow
+	}
+	return false
+}
+
+// OriginalData identifies the transaction a reversal refers to (Field 90)
+type OriginalData struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Mti              string                 `protobuf:"bytes,1,opt,name=mti,proto3" json:"mti,omitempty"`                                                   // Original Message Type Indicator
+	Stan             string                 `protobuf:"bytes,2,opt,name=stan,proto3" json:"stan,omitempty"`                                                 // Original System Trace Audit Number
+	TransmissionTime string                 `protobuf:"bytes,3,opt,name=transmission_time,json=transmissionTime,proto3" json:"transmission_time,omitempty"` // Original transmission timestamp (MMDDhhmmss)
+	AcquirerId       string                 `protobuf:"bytes,4,opt,name=acquirer_id,json=acquirerId,proto3" json:"acquirer_id,omitempty"`                   // Original acquiring institution ID
+	ForwardingId     string                 `protobuf:"bytes,5,opt,name=forwarding_id,json=forwardingId,proto3" json:"forwarding_id,omitempty"`             // Original forwarding institution ID
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *OriginalData) Reset() {
+	*x = OriginalData{}
+	mi := &file_auth_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OriginalData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OriginalData) ProtoMessage() {}
+
+func (x *OriginalData) ProtoReflect() protoreflect.Message {
+	mi := &file_auth_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OriginalData.ProtoReflect.Descriptor instead.
+func (*OriginalData) Descriptor() ([]byte, []int) {
+	return file_auth_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *OriginalData) GetMti() string {
+	if x != nil {
+		return x.Mti
+	}
+	return ""
+}
+
+func (x *OriginalData) GetStan() string {
+	if x != nil {
+		return x.Stan
+	}
+	return ""
+}
+
+func (x *OriginalData) GetTransmissionTime() string {
+	if x != nil {
+		return x.TransmissionTime
+	}
+	return ""
+}
+
+func (x *OriginalData) GetAcquirerId() string {
+	if x != nil {
+		return x.AcquirerId
+	}
+	return ""
+}
+
+func (x *OriginalData) GetForwardingId() string {
+	if x != nil {
+		return x.ForwardingId
+	}
+	return ""
+}
+
 // AuthResponse represents an ISO8583 authorization response converted to protobuf
 type AuthResponse struct {
 	state            protoimpl.MessageState `protogen:"open.v1"`
-	Mti              string                 `protobuf:"bytes,1,opt,name=mti,proto3" json:"mti,omitempty"`                                                      // Message Type Indicator (0110 for auth response)
+	Mti              string                 `protobuf:"bytes,1,opt,name=mti,proto3" json:"mti,omitempty"`                                                      // Message Type Indicator (0110, 0210, 0230, 0410 or 0430)
 	Pan              string                 `protobuf:"bytes,2,opt,name=pan,proto3" json:"pan,omitempty"`                                                      // Primary Account Number (Field 2)
 	Amount           string                 `protobuf:"bytes,3,opt,name=amount,proto3" json:"amount,omitempty"`                                                // Transaction Amount (Field 4)
 	TransmissionTime string                 `protobuf:"bytes,4,opt,name=transmission_time,json=transmissionTime,proto3" json:"transmission_time,omitempty"`    // Transmission Timestamp (Field 7)
@@ -122,7 +359,7 @@ type AuthResponse struct {
 
 func (x *AuthResponse) Reset() {
 	*x = AuthResponse{}
-	mi := &file_auth_proto_msgTypes[1]
+	mi := &file_auth_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -134,7 +371,7 @@ func (x *AuthResponse) String() string {
 func (*AuthResponse) ProtoMessage() {}
 
 func (x *AuthResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_auth_proto_msgTypes[1]
+	mi := &file_auth_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -147,7 +384,7 @@ func (x *AuthResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AuthResponse.ProtoReflect.Descriptor instead.
 func (*AuthResponse) Descriptor() ([]byte, []int) {
-	return file_auth_proto_rawDescGZIP(), []int{1}
+	return file_auth_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *AuthResponse) GetMti() string {
@@ -199,17 +436,21 @@ func (x *AuthResponse) GetProcessingTimeMs() int64 {
 	return 0
 }
 
-// GetTransactionRequest is used to request a transaction by STAN
+// GetTransactionRequest is used to request a transaction by STAN, transmission
+// time, acquirer and terminal
 type GetTransactionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Stan          string                 `protobuf:"bytes,1,opt,name=stan,proto3" json:"stan,omitempty"` // System Trace Audit Number
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Stan             string                 `protobuf:"bytes,1,opt,name=stan,proto3" json:"stan,omitempty"`                                                 // System Trace Audit Number
+	TransmissionTime string                 `protobuf:"bytes,2,opt,name=transmission_time,json=transmissionTime,proto3" json:"transmission_time,omitempty"` // Transmission timestamp (MMDDhhmmss)
+	AcquirerId       string                 `protobuf:"bytes,3,opt,name=acquirer_id,json=acquirerId,proto3" json:"acquirer_id,omitempty"`                   // Acquiring Institution ID
+	TerminalId       string                 `protobuf:"bytes,4,opt,name=terminal_id,json=terminalId,proto3" json:"terminal_id,omitempty"`                   // Card Acceptor Terminal ID
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
 func (x *GetTransactionRequest) Reset() {
 	*x = GetTransactionRequest{}
-	mi := &file_auth_proto_msgTypes[2]
+	mi := &file_auth_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -221,7 +462,7 @@ func (x *GetTransactionRequest) String() string {
 func (*GetTransactionRequest) ProtoMessage() {}
 
 func (x *GetTransactionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_auth_proto_msgTypes[2]
+	mi := &file_auth_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -234,7 +475,7 @@ func (x *GetTransactionRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetTransactionRequest.ProtoReflect.Descriptor instead.
 func (*GetTransactionRequest) Descriptor() ([]byte, []int) {
-	return file_auth_proto_rawDescGZIP(), []int{2}
+	return file_auth_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *GetTransactionRequest) GetStan() string {
@@ -244,23 +485,51 @@ func (x *GetTransactionRequest) GetStan() string {
 	return ""
 }
 
+func (x *GetTransactionRequest) GetTransmissionTime() string {
+	if x != nil {
+		return x.TransmissionTime
+	}
+	return ""
+}
+
+func (x *GetTransactionRequest) GetAcquirerId() string {
+	if x != nil {
+		return x.AcquirerId
+	}
+	return ""
+}
+
+func (x *GetTransactionRequest) GetTerminalId() string {
+	if x != nil {
+		return x.TerminalId
+	}
+	return ""
+}
+
 // AuthRecord represents a stored transaction in Spanner
 type AuthRecord struct {
 	state            protoimpl.MessageState `protogen:"open.v1"`
-	Stan             string                 `protobuf:"bytes,1,opt,name=stan,proto3" json:"stan,omitempty"`                                                 // System Trace Audit Number
-	Pan              string                 `protobuf:"bytes,2,opt,name=pan,proto3" json:"pan,omitempty"`                                                   // Primary Account Number
-	Amount           string                 `protobuf:"bytes,3,opt,name=amount,proto3" json:"amount,omitempty"`                                             // Transaction Amount
-	Region           string                 `protobuf:"bytes,4,opt,name=region,proto3" json:"region,omitempty"`                                             // Processing Region
-	Approved         bool                   `protobuf:"varint,5,opt,name=approved,proto3" json:"approved,omitempty"`                                        // Whether the transaction was approved
-	TransmissionTime string                 `protobuf:"bytes,6,opt,name=transmission_time,json=transmissionTime,proto3" json:"transmission_time,omitempty"` // Original transmission timestamp
-	InsertedAt       string                 `protobuf:"bytes,7,opt,name=inserted_at,json=insertedAt,proto3" json:"inserted_at,omitempty"`                   // When the record was inserted into storage
+	Stan             string                 `protobuf:"bytes,1,opt,name=stan,proto3" json:"stan,omitempty"`                                                              // System Trace Audit Number
+	Pan              string                 `protobuf:"bytes,2,opt,name=pan,proto3" json:"pan,omitempty"`                                                                // Primary Account Number
+	Amount           string                 `protobuf:"bytes,3,opt,name=amount,proto3" json:"amount,omitempty"`                                                          // Transaction Amount
+	Region           string                 `protobuf:"bytes,4,opt,name=region,proto3" json:"region,omitempty"`                                                          // Processing Region
+	Approved         bool                   `protobuf:"varint,5,opt,name=approved,proto3" json:"approved,omitempty"`                                                     // Whether the transaction was approved
+	TransmissionTime string                 `protobuf:"bytes,6,opt,name=transmission_time,json=transmissionTime,proto3" json:"transmission_time,omitempty"`              // Original transmission timestamp
+	InsertedAt       string                 `protobuf:"bytes,7,opt,name=inserted_at,json=insertedAt,proto3" json:"inserted_at,omitempty"`                                // When the record was inserted into storage
+	Reversed         bool                   `protobuf:"varint,8,opt,name=reversed,proto3" json:"reversed,omitempty"`                                                     // Whether the transaction has been reversed
+	MessageClass     MessageClass           `protobuf:"varint,9,opt,name=message_class,json=messageClass,proto3,enum=pulse.MessageClass" json:"message_class,omitempty"` // Message class of the transaction
+	PrimaryRegion    string                 `protobuf:"bytes,10,opt,name=primary_region,json=primaryRegion,proto3" json:"primary_region,omitempty"`                      // Region the BIN routes to before failover
+	FailoverHops     int32                  `protobuf:"varint,11,opt,name=failover_hops,json=failoverHops,proto3" json:"failover_hops,omitempty"`                        // Failover tiers tried to reach the processing region
+	FailoverReason   string                 `protobuf:"bytes,12,opt,name=failover_reason,json=failoverReason,proto3" json:"failover_reason,omitempty"`                   // Why the transaction left its primary region
+	AcquirerId       string                 `protobuf:"bytes,13,opt,name=acquirer_id,json=acquirerId,proto3" json:"acquirer_id,omitempty"`                               // Acquiring Institution ID
+	TerminalId       string                 `protobuf:"bytes,14,opt,name=terminal_id,json=terminalId,proto3" json:"terminal_id,omitempty"`                               // Card Acceptor Terminal ID
 	unknownFields    protoimpl.UnknownFields
 	sizeCache        protoimpl.SizeCache
 }
 
 func (x *AuthRecord) Reset() {
 	*x = AuthRecord{}
-	mi := &file_auth_proto_msgTypes[3]
+	mi := &file_auth_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -272,7 +541,7 @@ func (x *AuthRecord) String() string {
 func (*AuthRecord) ProtoMessage() {}
 
 func (x *AuthRecord) ProtoReflect() protoreflect.Message {
-	mi := &file_auth_proto_msgTypes[3]
+	mi := &file_auth_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -285,7 +554,7 @@ func (x *AuthRecord) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AuthRecord.ProtoReflect.Descriptor instead.
 func (*AuthRecord) Descriptor() ([]byte, []int) {
-	return file_auth_proto_rawDescGZIP(), []int{3}
+	return file_auth_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *AuthRecord) GetStan() string {
@@ -337,11 +606,60 @@ func (x *AuthRecord) GetInsertedAt() string {
 	return ""
 }
 
+func (x *AuthRecord) GetReversed() bool {
+	if x != nil {
+		return x.Reversed
+	}
+	return false
+}
+
+func (x *AuthRecord) GetMessageClass() MessageClass {
+	if x != nil {
+		return x.MessageClass
+	}
+	return MessageClass_MESSAGE_CLASS_UNSPECIFIED
+}
+
+func (x *AuthRecord) GetPrimaryRegion() string {
+	if x != nil {
+		return x.PrimaryRegion
+	}
+	return ""
+}
+
+func (x *AuthRecord) GetFailoverHops() int32 {
+	if x != nil {
+		return x.FailoverHops
+	}
+	return 0
+}
+
+func (x *AuthRecord) GetFailoverReason() string {
+	if x != nil {
+		return x.FailoverReason
+	}
+	return ""
+}
+
+func (x *AuthRecord) GetAcquirerId() string {
+	if x != nil {
+		return x.AcquirerId
+	}
+	return ""
+}
+
+func (x *AuthRecord) GetTerminalId() string {
+	if x != nil {
+		return x.TerminalId
+	}
+	return ""
+}
+
 var File_auth_proto protoreflect.FileDescriptor
 
 var file_auth_proto_rawDesc = string([]byte{
 	0x0a, 0x0a, 0x61, 0x75, 0x74, 0x68, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x05, 0x70, 0x75,
-	0x6c, 0x73, 0x65, 0x22, 0xa2, 0x01, 0x0a, 0x0b, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x71, 0x75,
+	0x6c, 0x73, 0x65, 0x22, 0x95, 0x05, 0x0a, 0x0b, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x71, 0x75,
 	0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x74, 0x69, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
 	0x52, 0x03, 0x6d, 0x74, 0x69, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x61, 0x6e, 0x18, 0x02, 0x20, 0x01,
 	0x28, 0x09, 0x52, 0x03, 0x70, 0x61, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x6d, 0x6f, 0x75, 0x6e,
@@ -351,48 +669,124 @@ var file_auth_proto_rawDesc = string([]byte{
 	0x73, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04,
 	0x73, 0x74, 0x61, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x73, 0x74, 0x61, 0x6e,
 	0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x06, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x22, 0xde, 0x01, 0x0a, 0x0c, 0x41, 0x75, 0x74,
-	0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x74, 0x69,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x74, 0x69, 0x12, 0x10, 0x0a, 0x03, 0x70,
-	0x61, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x70, 0x61, 0x6e, 0x12, 0x16, 0x0a,
-	0x06, 0x61, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61,
-	0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x2b, 0x0a, 0x11, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69,
-	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x10, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x69,
-	0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x74, 0x61, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x04, 0x73, 0x74, 0x61, 0x6e, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x72,
-	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x2c, 0x0a, 0x12, 0x70,
-	0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x69, 0x6e, 0x67, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x6d,
-	0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x10, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73,
-	0x69, 0x6e, 0x67, 0x54, 0x69, 0x6d, 0x65, 0x4d, 0x73, 0x22, 0x2b, 0x0a, 0x15, 0x47, 0x65, 0x74,
-	0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x74, 0x61, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x04, 0x73, 0x74, 0x61, 0x6e, 0x22, 0xcc, 0x01, 0x0a, 0x0a, 0x41, 0x75, 0x74, 0x68, 0x52,
-	0x65, 0x63, 0x6f, 0x72, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x74, 0x61, 0x6e, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x04, 0x73, 0x74, 0x61, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x61, 0x6e,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x70, 0x61, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x61,
-	0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x6d, 0x6f,
-	0x75, 0x6e, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x61,
-	0x70, 0x70, 0x72, 0x6f, 0x76, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x61,
-	0x70, 0x70, 0x72, 0x6f, 0x76, 0x65, 0x64, 0x12, 0x2b, 0x0a, 0x11, 0x74, 0x72, 0x61, 0x6e, 0x73,
-	0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x06, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x10, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e,
-	0x54, 0x69, 0x6d, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x65, 0x64,
-	0x5f, 0x61, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x69, 0x6e, 0x73, 0x65, 0x72,
-	0x74, 0x65, 0x64, 0x41, 0x74, 0x32, 0x8c, 0x01, 0x0a, 0x0b, 0x41, 0x75, 0x74, 0x68, 0x53, 0x65,
-	0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x38, 0x0a, 0x0b, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73,
-	0x41, 0x75, 0x74, 0x68, 0x12, 0x12, 0x2e, 0x70, 0x75, 0x6c, 0x73, 0x65, 0x2e, 0x41, 0x75, 0x74,
-	0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x13, 0x2e, 0x70, 0x75, 0x6c, 0x73, 0x65,
-	0x2e, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12,
-	0x43, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f,
-	0x6e, 0x12, 0x1c, 0x2e, 0x70, 0x75, 0x6c, 0x73, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x54, 0x72, 0x61,
-	0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
-	0x11, 0x2e, 0x70, 0x75, 0x6c, 0x73, 0x65, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x63, 0x6f,
-	0x72, 0x64, 0x22, 0x00, 0x42, 0x1d, 0x5a, 0x1b, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2f, 0x54, 0x46, 0x4d, 0x56, 0x2f, 0x70, 0x75, 0x6c, 0x73, 0x65, 0x2f, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x52, 0x06, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x12, 0x38, 0x0a, 0x0d, 0x6f, 0x72, 0x69, 0x67,
+	0x69, 0x6e, 0x61, 0x6c, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x13, 0x2e, 0x70, 0x75, 0x6c, 0x73, 0x65, 0x2e, 0x4f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x61, 0x6c,
+	0x44, 0x61, 0x74, 0x61, 0x52, 0x0c, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x61, 0x6c, 0x44, 0x61,
+	0x74, 0x61, 0x12, 0x38, 0x0a, 0x0d, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x63, 0x6c,
+	0x61, 0x73, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x70, 0x75, 0x6c, 0x73,
+	0x65, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x43, 0x6c, 0x61, 0x73, 0x73, 0x52, 0x0c,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x43, 0x6c, 0x61, 0x73, 0x73, 0x12, 0x33, 0x0a, 0x16,
+	0x73, 0x74, 0x61, 0x6e, 0x64, 0x5f, 0x69, 0x6e, 0x5f, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x13, 0x73, 0x74,
+	0x61, 0x6e, 0x64, 0x49, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x43, 0x6f, 0x64,
+	0x65, 0x12, 0x25, 0x0a, 0x0e, 0x70, 0x72, 0x69, 0x6d, 0x61, 0x72, 0x79, 0x5f, 0x72, 0x65, 0x67,
+	0x69, 0x6f, 0x6e, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x70, 0x72, 0x69, 0x6d, 0x61,
+	0x72, 0x79, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x12, 0x23, 0x0a, 0x0d, 0x66, 0x61, 0x69, 0x6c,
+	0x6f, 0x76, 0x65, 0x72, 0x5f, 0x68, 0x6f, 0x70, 0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x0c, 0x66, 0x61, 0x69, 0x6c, 0x6f, 0x76, 0x65, 0x72, 0x48, 0x6f, 0x70, 0x73, 0x12, 0x27, 0x0a,
+	0x0f, 0x66, 0x61, 0x69, 0x6c, 0x6f, 0x76, 0x65, 0x72, 0x5f, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e,
+	0x18, 0x0c, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x66, 0x61, 0x69, 0x6c, 0x6f, 0x76, 0x65, 0x72,
+	0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x0f, 0x65, 0x78, 0x70, 0x69, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x64, 0x61, 0x74, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0e, 0x65, 0x78, 0x70, 0x69, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x44, 0x61, 0x74, 0x65, 0x12,
+	0x21, 0x0a, 0x0c, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18,
+	0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x43, 0x6f,
+	0x64, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x70, 0x69, 0x6e, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18,
+	0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x69, 0x6e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12,
+	0x10, 0x0a, 0x03, 0x63, 0x76, 0x76, 0x18, 0x10, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x63, 0x76,
+	0x76, 0x12, 0x1f, 0x0a, 0x0b, 0x61, 0x63, 0x71, 0x75, 0x69, 0x72, 0x65, 0x72, 0x5f, 0x69, 0x64,
+	0x18, 0x11, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x61, 0x63, 0x71, 0x75, 0x69, 0x72, 0x65, 0x72,
+	0x49, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x5f, 0x69,
+	0x64, 0x18, 0x12, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61,
+	0x6c, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x68, 0x61, 0x64, 0x6f, 0x77, 0x18, 0x13, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x06, 0x73, 0x68, 0x61, 0x64, 0x6f, 0x77, 0x22, 0xa7, 0x01, 0x0a, 0x0c,
+	0x4f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x61, 0x6c, 0x44, 0x61, 0x74, 0x61, 0x12, 0x10, 0x0a, 0x03,
+	0x6d, 0x74, 0x69, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x74, 0x69, 0x12, 0x12,
+	0x0a, 0x04, 0x73, 0x74, 0x61, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x73, 0x74,
+	0x61, 0x6e, 0x12, 0x2b, 0x0a, 0x11, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x10, 0x74,
+	0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x12,
+	0x1f, 0x0a, 0x0b, 0x61, 0x63, 0x71, 0x75, 0x69, 0x72, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x61, 0x63, 0x71, 0x75, 0x69, 0x72, 0x65, 0x72, 0x49, 0x64,
+	0x12, 0x23, 0x0a, 0x0d, 0x66, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x69, 0x6e, 0x67, 0x5f, 0x69,
+	0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x66, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64,
+	0x69, 0x6e, 0x67, 0x49, 0x64, 0x22, 0xde, 0x01, 0x0a, 0x0c, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x74, 0x69, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x74, 0x69, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x61, 0x6e, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x70, 0x61, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x6d,
+	0x6f, 0x75, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x6d, 0x6f, 0x75,
+	0x6e, 0x74, 0x12, 0x2b, 0x0a, 0x11, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x10, 0x74,
+	0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x12,
+	0x12, 0x0a, 0x04, 0x73, 0x74, 0x61, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x73,
+	0x74, 0x61, 0x6e, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x5f,
+	0x63, 0x6f, 0x64, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x72, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x2c, 0x0a, 0x12, 0x70, 0x72, 0x6f, 0x63,
+	0x65, 0x73, 0x73, 0x69, 0x6e, 0x67, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x6d, 0x73, 0x18, 0x07,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x10, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x69, 0x6e, 0x67,
+	0x54, 0x69, 0x6d, 0x65, 0x4d, 0x73, 0x22, 0x9a, 0x01, 0x0a, 0x15, 0x47, 0x65, 0x74, 0x54, 0x72,
+	0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x12, 0x0a, 0x04, 0x73, 0x74, 0x61, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x73, 0x74, 0x61, 0x6e, 0x12, 0x2b, 0x0a, 0x11, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73,
+	0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x10, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x69, 0x6d,
+	0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x61, 0x63, 0x71, 0x75, 0x69, 0x72, 0x65, 0x72, 0x5f, 0x69, 0x64,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x61, 0x63, 0x71, 0x75, 0x69, 0x72, 0x65, 0x72,
+	0x49, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x5f, 0x69,
+	0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61,
+	0x6c, 0x49, 0x64, 0x22, 0xd9, 0x03, 0x0a, 0x0a, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x63, 0x6f,
+	0x72, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x74, 0x61, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x73, 0x74, 0x61, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x70, 0x61, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x70, 0x61, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x6d, 0x6f, 0x75,
+	0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x6d, 0x6f, 0x75, 0x6e, 0x74,
+	0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x61, 0x70, 0x70, 0x72,
+	0x6f, 0x76, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x61, 0x70, 0x70, 0x72,
+	0x6f, 0x76, 0x65, 0x64, 0x12, 0x2b, 0x0a, 0x11, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73,
+	0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x10, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x69, 0x6d,
+	0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x65, 0x64,
+	0x41, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x64, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x72, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x64, 0x12, 0x38,
+	0x0a, 0x0d, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x63, 0x6c, 0x61, 0x73, 0x73, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x70, 0x75, 0x6c, 0x73, 0x65, 0x2e, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x43, 0x6c, 0x61, 0x73, 0x73, 0x52, 0x0c, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x43, 0x6c, 0x61, 0x73, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x70, 0x72, 0x69, 0x6d,
+	0x61, 0x72, 0x79, 0x5f, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0d, 0x70, 0x72, 0x69, 0x6d, 0x61, 0x72, 0x79, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x12,
+	0x23, 0x0a, 0x0d, 0x66, 0x61, 0x69, 0x6c, 0x6f, 0x76, 0x65, 0x72, 0x5f, 0x68, 0x6f, 0x70, 0x73,
+	0x18, 0x0b, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x66, 0x61, 0x69, 0x6c, 0x6f, 0x76, 0x65, 0x72,
+	0x48, 0x6f, 0x70, 0x73, 0x12, 0x27, 0x0a, 0x0f, 0x66, 0x61, 0x69, 0x6c, 0x6f, 0x76, 0x65, 0x72,
+	0x5f, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x66,
+	0x61, 0x69, 0x6c, 0x6f, 0x76, 0x65, 0x72, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x1f, 0x0a,
+	0x0b, 0x61, 0x63, 0x71, 0x75, 0x69, 0x72, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x0d, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x61, 0x63, 0x71, 0x75, 0x69, 0x72, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1f,
+	0x0a, 0x0b, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x5f, 0x69, 0x64, 0x18, 0x0e, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0a, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x49, 0x64, 0x2a,
+	0xa5, 0x01, 0x0a, 0x0c, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x43, 0x6c, 0x61, 0x73, 0x73,
+	0x12, 0x1d, 0x0a, 0x19, 0x4d, 0x45, 0x53, 0x53, 0x41, 0x47, 0x45, 0x5f, 0x43, 0x4c, 0x41, 0x53,
+	0x53, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12,
+	0x1f, 0x0a, 0x1b, 0x4d, 0x45, 0x53, 0x53, 0x41, 0x47, 0x45, 0x5f, 0x43, 0x4c, 0x41, 0x53, 0x53,
+	0x5f, 0x41, 0x55, 0x54, 0x48, 0x4f, 0x52, 0x49, 0x5a, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x10, 0x01,
+	0x12, 0x1b, 0x0a, 0x17, 0x4d, 0x45, 0x53, 0x53, 0x41, 0x47, 0x45, 0x5f, 0x43, 0x4c, 0x41, 0x53,
+	0x53, 0x5f, 0x46, 0x49, 0x4e, 0x41, 0x4e, 0x43, 0x49, 0x41, 0x4c, 0x10, 0x02, 0x12, 0x1c, 0x0a,
+	0x18, 0x4d, 0x45, 0x53, 0x53, 0x41, 0x47, 0x45, 0x5f, 0x43, 0x4c, 0x41, 0x53, 0x53, 0x5f, 0x43,
+	0x4f, 0x4d, 0x50, 0x4c, 0x45, 0x54, 0x49, 0x4f, 0x4e, 0x10, 0x03, 0x12, 0x1a, 0x0a, 0x16, 0x4d,
+	0x45, 0x53, 0x53, 0x41, 0x47, 0x45, 0x5f, 0x43, 0x4c, 0x41, 0x53, 0x53, 0x5f, 0x52, 0x45, 0x56,
+	0x45, 0x52, 0x53, 0x41, 0x4c, 0x10, 0x04, 0x32, 0x8c, 0x01, 0x0a, 0x0b, 0x41, 0x75, 0x74, 0x68,
+	0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x38, 0x0a, 0x0b, 0x50, 0x72, 0x6f, 0x63, 0x65,
+	0x73, 0x73, 0x41, 0x75, 0x74, 0x68, 0x12, 0x12, 0x2e, 0x70, 0x75, 0x6c, 0x73, 0x65, 0x2e, 0x41,
+	0x75, 0x74, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x13, 0x2e, 0x70, 0x75, 0x6c,
+	0x73, 0x65, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
+	0x00, 0x12, 0x43, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x1c, 0x2e, 0x70, 0x75, 0x6c, 0x73, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x54,
+	0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x11, 0x2e, 0x70, 0x75, 0x6c, 0x73, 0x65, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x52, 0x65,
+	0x63, 0x6f, 0x72, 0x64, 0x22, 0x00, 0x42, 0x1d, 0x5a, 0x1b, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x54, 0x46, 0x4d, 0x56, 0x2f, 0x70, 0x75, 0x6c, 0x73, 0x65, 0x2f,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 })
 
 var (
@@ -407,23 +801,29 @@ func file_auth_proto_rawDescGZIP() []byte {
 	return file_auth_proto_rawDescData
 }
 
-var file_auth_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_auth_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_auth_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_auth_proto_goTypes = []any{
-	(*AuthRequest)(nil),           // 0: pulse.AuthRequest
-	(*AuthResponse)(nil),          // 1: pulse.AuthResponse
-	(*GetTransactionRequest)(nil), // 2: pulse.GetTransactionRequest
-	(*AuthRecord)(nil),            // 3: pulse.AuthRecord
+	(MessageClass)(0),             // 0: pulse.MessageClass
+	(*AuthRequest)(nil),           // 1: pulse.AuthRequest
+	(*OriginalData)(nil),          // 2: pulse.OriginalData
+	(*AuthResponse)(nil),          // 3: pulse.AuthResponse
+	(*GetTransactionRequest)(nil), // 4: pulse.GetTransactionRequest
+	(*AuthRecord)(nil),            // 5: pulse.AuthRecord
 }
 var file_auth_proto_depIdxs = []int32{
-	0, // 0: pulse.AuthService.ProcessAuth:input_type -> pulse.AuthRequest
-	2, // 1: pulse.AuthService.GetTransaction:input_type -> pulse.GetTransactionRequest
-	1, // 2: pulse.AuthService.ProcessAuth:output_type -> pulse.AuthResponse
-	3, // 3: pulse.AuthService.GetTransaction:output_type -> pulse.AuthRecord
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	2, // 0: pulse.AuthRequest.original_data:type_name -> pulse.OriginalData
+	0, // 1: pulse.AuthRequest.message_class:type_name -> pulse.MessageClass
+	0, // 2: pulse.AuthRecord.message_class:type_name -> pulse.MessageClass
+	1, // 3: pulse.AuthService.ProcessAuth:input_type -> pulse.AuthRequest
+	4, // 4: pulse.AuthService.GetTransaction:input_type -> pulse.GetTransactionRequest
+	3, // 5: pulse.AuthService.ProcessAuth:output_type -> pulse.AuthResponse
+	5, // 6: pulse.AuthService.GetTransaction:output_type -> pulse.AuthRecord
+	5, // [5:7] is the sub-list for method output_type
+	3, // [3:5] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_auth_proto_init() }
@@ -436,13 +836,14 @@ func file_auth_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_auth_proto_rawDesc), len(file_auth_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      1,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_auth_proto_goTypes,
 		DependencyIndexes: file_auth_proto_depIdxs,
+		EnumInfos:         file_auth_proto_enumTypes,
 		MessageInfos:      file_auth_proto_msgTypes,
 	}.Build()
 	File_auth_proto = out.File
@@ -31,7 +31,7 @@ const (
 type AuthServiceClient interface {
 	// ProcessAuth handles authorization requests
 	ProcessAuth(ctx context.Context, in *AuthRequest, opts ...grpc.CallOption) (*AuthResponse, error)
-	// GetTransaction retrieves a transaction by STAN
+	// GetTransaction retrieves a transaction by STAN, transmission time, acquirer and terminal
 	GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*AuthRecord, error)
 }
 
@@ -71,7 +71,7 @@ func (c *authServiceClient) GetTransaction(ctx context.Context, in *GetTransacti
 type AuthServiceServer interface {
 	// ProcessAuth handles authorization requests
 	ProcessAuth(context.Context, *AuthRequest) (*AuthResponse, error)
-	// GetTransaction retrieves a transaction by STAN
+	// GetTransaction retrieves a transaction by STAN, transmission time, acquirer and terminal
 	GetTransaction(context.Context, *GetTransactionRequest) (*AuthRecord, error)
 	mustEmbedUnimplementedAuthServiceServer()
 }
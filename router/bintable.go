@@ -0,0 +1,153 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxBinLength is the longest BIN a routing rule may use. Ranges and prefixes
+// are compared on the first maxBinLength digits of the PAN.
+const maxBinLength = 8
+
+// BinTable is a compiled BIN routing table. Each rule covers an interval of
+// 8-digit BINs; when rules are nested, the narrowest interval wins.
+type BinTable struct {
+	// rules are sorted by start, with enclosing rules before the rules nested in them
+	rules []*binRule
+}
+
+// binRule is a BIN prefix or range routed to a region
+type binRule struct {
+	source string
+	start  uint64
+	end    uint64
+	region string
+	// parent is the narrowest rule enclosing this one
+	parent *binRule
+}
+
+// NewBinTable compiles BIN routes into a routing table. Routes are keyed by a
+// BIN prefix of 1 to 8 digits (e.g. "4" or "41111111") or a range of two
+// prefixes of the same length (e.g. "400000-499999"). A route may be nested in
+// another, but routes that partly overlap, or cover the same BINs for
+// different regions, are rejected.
+func NewBinTable(routes map[string]string) (*BinTable, error) {
+	rules := make([]*binRule, 0, len(routes))
+	for source, region := range routes {
+		rule, err := parseBinRule(source, region)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].start != rules[j].start {
+			return rules[i].start < rules[j].start
+		}
+		if rules[i].end != rules[j].end {
+			return rules[i].end > rules[j].end
+		}
+		return rules[i].source < rules[j].source
+	})
+
+	// Link each rule to the narrowest rule enclosing it. The stack holds the
+	// chain of rules enclosing the current position.
+	table := &BinTable{}
+	var stack []*binRule
+	for _, rule := range rules {
+		for len(stack) > 0 && stack[len(stack)-1].end < rule.start {
+			stack = stack[:len(stack)-1]
+		}
+
+		if len(stack) > 0 {
+			enclosing := stack[len(stack)-1]
+			if rule.end > enclosing.end {
+				return nil, fmt.Errorf("BIN routes %q and %q overlap", enclosing.source, rule.source)
+			}
+			if rule.start == enclosing.start && rule.end == enclosing.end {
+				if rule.region != enclosing.region {
+					return nil, fmt.Errorf("BIN routes %q and %q cover the same BINs for regions %s and %s",
+						enclosing.source, rule.source, enclosing.region, rule.region)
+				}
+				// Equivalent routes to the same region, e.g. "4" and "4000-4999"
+				continue
+			}
+			rule.parent = enclosing
+		}
+
+		table.rules = append(table.rules, rule)
+		stack = append(stack, rule)
+	}
+
+	return table, nil
+}
+
+// parseBinRule parses a BIN prefix or range
+func parseBinRule(source, region string) (*binRule, error) {
+	if region == "" {
+		return nil, fmt.Errorf("BIN route %q has no region", source)
+	}
+
+	first, last, isRange := strings.Cut(source, "-")
+	if !isRange {
+		last = first
+	}
+	if len(first) != len(last) {
+		return nil, fmt.Errorf("BIN range %q must have bounds of the same length", source)
+	}
+	if len(first) == 0 || len(first) > maxBinLength {
+		return nil, fmt.Errorf("BIN route %q must have 1 to %d digits", source, maxBinLength)
+	}
+
+	start, err := binBound(first, '0')
+	if err != nil {
+		return nil, fmt.Errorf("invalid BIN route %q: %w", source, err)
+	}
+	end, err := binBound(last, '9')
+	if err != nil {
+		return nil, fmt.Errorf("invalid BIN route %q: %w", source, err)
+	}
+	if start > end {
+		return nil, fmt.Errorf("BIN range %q starts after it ends", source)
+	}
+
+	return &binRule{source: source, start: start, end: end, region: region}, nil
+}
+
+// binBound pads a BIN prefix to maxBinLength digits with fill
+func binBound(prefix string, fill byte) (uint64, error) {
+	padded := prefix + strings.Repeat(string(fill), maxBinLength-len(prefix))
+	return strconv.ParseUint(padded, 10, 64)
+}
+
+// Lookup returns the region of the most specific route matching the PAN. It
+// returns false when no route matches or the PAN is too short or not numeric.
+func (t *BinTable) Lookup(pan string) (string, bool) {
+	if t == nil || len(pan) < maxBinLength {
+		return "", false
+	}
+
+	bin, err := strconv.ParseUint(pan[:maxBinLength], 10, 64)
+	if err != nil {
+		return "", false
+	}
+
+	// The last rule starting at or before the BIN is the narrowest candidate;
+	// if it ends before the BIN, one of the rules enclosing it may match
+	i := sort.Search(len(t.rules), func(i int) bool {
+		return t.rules[i].start > bin
+	})
+	if i == 0 {
+		return "", false
+	}
+	for rule := t.rules[i-1]; rule != nil; rule = rule.parent {
+		if bin <= rule.end {
+			return rule.region, true
+		}
+	}
+
+	return "", false
+}
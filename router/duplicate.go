@@ -0,0 +1,160 @@
+package router
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+	"github.com/moov-io/iso8583"
+)
+
+// Duplicate detection backends
+const (
+	DuplicateBackendMemory  = "memory"
+	DuplicateBackendStorage = "storage"
+)
+
+// defaultDuplicateTTL is how long responses are kept for replay when no TTL is configured
+const defaultDuplicateTTL = 10 * time.Minute
+
+// DuplicateConfig holds duplicate transmission detection configuration
+type DuplicateConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"`     // How long a response is replayed to retransmissions
+	Backend string        `yaml:"backend"` // memory or storage
+}
+
+// DuplicateStore keeps responses by duplicate detection key.
+// storage.Storage implementations satisfy it.
+type DuplicateStore interface {
+	SaveResponse(ctx context.Context, key string, response *proto.AuthResponse, expiresAt time.Time) error
+	GetResponse(ctx context.Context, key string) (*proto.AuthResponse, error)
+}
+
+// DuplicateDetector recognizes retransmitted requests and returns the
+// response to the original instead of processing them again
+type DuplicateDetector struct {
+	store DuplicateStore
+	ttl   time.Duration
+
+	// inFlight holds requests that are being processed, so a retransmission
+	// that arrives before the original completes waits for its response
+	inFlight map[string]*Transmission
+	mutex    sync.Mutex
+}
+
+// Transmission is a request being processed under a duplicate detection key
+type Transmission struct {
+	detector  *DuplicateDetector
+	key       string
+	done      chan struct{}
+	response  *proto.AuthResponse
+	completed bool
+}
+
+// NewDuplicateDetector creates a duplicate detector that keeps responses in
+// store for ttl
+func NewDuplicateDetector(store DuplicateStore, ttl time.Duration) *DuplicateDetector {
+	if ttl <= 0 {
+		ttl = defaultDuplicateTTL
+	}
+
+	return &DuplicateDetector{
+		store:    store,
+		ttl:      ttl,
+		inFlight: make(map[string]*Transmission),
+	}
+}
+
+// Begin starts processing the request with the given key. If the request is a
+// retransmission, Begin returns the original response. Otherwise it returns a
+// Transmission that must be completed with the response or released.
+func (d *DuplicateDetector) Begin(ctx context.Context, key string) (*proto.AuthResponse, *Transmission, error) {
+	for {
+		d.mutex.Lock()
+		original, ok := d.inFlight[key]
+		if !ok {
+			transmission := &Transmission{detector: d, key: key, done: make(chan struct{})}
+			d.inFlight[key] = transmission
+			d.mutex.Unlock()
+
+			response, err := d.store.GetResponse(ctx, key)
+			if err != nil || response != nil {
+				transmission.Release()
+				return response, nil, err
+			}
+			return nil, transmission, nil
+		}
+		d.mutex.Unlock()
+
+		// Wait for the original to finish
+		select {
+		case <-original.done:
+			if original.response != nil {
+				return original.response, nil, nil
+			}
+			// The original failed without a response, so process this one
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+// Complete records the response so retransmissions receive it
+func (t *Transmission) Complete(ctx context.Context, response *proto.AuthResponse) {
+	if t == nil || t.completed {
+		return
+	}
+
+	expiresAt := time.Now().Add(t.detector.ttl)
+	if err := t.detector.store.SaveResponse(ctx, t.key, response, expiresAt); err != nil {
+		log.Printf("Failed to save response for duplicate detection: %v", err)
+	}
+
+	t.response = response
+	t.finish()
+}
+
+// Release ends a transmission that produced no response to replay, such as a
+// timeout, so a retransmission is processed again
+func (t *Transmission) Release() {
+	if t == nil || t.completed {
+		return
+	}
+	t.finish()
+}
+
+// finish wakes retransmissions waiting for this transmission
+func (t *Transmission) finish() {
+	t.completed = true
+
+	t.detector.mutex.Lock()
+	delete(t.detector.inFlight, t.key)
+	t.detector.mutex.Unlock()
+
+	close(t.done)
+}
+
+// duplicateKey builds the duplicate detection key of a message from its
+// message class, STAN (11), transmission time (7), terminal ID (41) and
+// acquiring institution ID (32). Repeats (e.g. 0101) share the key of the
+// original. It returns an empty string when the STAN or transmission time is
+// missing.
+func duplicateKey(message *iso8583.Message, mti string) string {
+	stan := presentField(message, 11)
+	transmissionTime := presentField(message, 7)
+	if len(mti) != 4 || stan == "" || transmissionTime == "" {
+		return ""
+	}
+
+	return strings.Join([]string{
+		mti[:3],
+		stan,
+		transmissionTime,
+		presentField(message, 41),
+		presentField(message, 32),
+	}, "/")
+}
@@ -0,0 +1,119 @@
+package router
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultEventBuffer is the channel buffer of a subscriber that does not set one
+const defaultEventBuffer = 64
+
+// CircuitEvent is a circuit breaker state change of a region
+type CircuitEvent struct {
+	Region string       `json:"region"`
+	From   CircuitState `json:"from"`
+	To     CircuitState `json:"to"`
+	Cause  string       `json:"cause"`
+	At     time.Time    `json:"at"`
+}
+
+// circuitEvents delivers circuit events to subscribers. Slow subscribers miss
+// events instead of delaying routing.
+type circuitEvents struct {
+	mutex       sync.Mutex
+	subscribers map[chan CircuitEvent]struct{}
+}
+
+// subscribe adds a subscriber and returns its channel and the function that
+// removes it
+func (e *circuitEvents) subscribe(buffer int) (<-chan CircuitEvent, func()) {
+	if buffer <= 0 {
+		buffer = defaultEventBuffer
+	}
+	ch := make(chan CircuitEvent, buffer)
+
+	e.mutex.Lock()
+	if e.subscribers == nil {
+		e.subscribers = make(map[chan CircuitEvent]struct{})
+	}
+	e.subscribers[ch] = struct{}{}
+	e.mutex.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.mutex.Lock()
+			delete(e.subscribers, ch)
+			e.mutex.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publish sends an event to every subscriber that has room for it
+func (e *circuitEvents) publish(event CircuitEvent) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for ch := range e.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Dropped circuit event for region %s: subscriber is full", event.Region)
+		}
+	}
+}
+
+// SubscribeCircuitEvents returns a channel receiving every circuit state
+// change and a function that ends the subscription. Events are dropped while
+// the channel buffer is full.
+func (r *Router) SubscribeCircuitEvents(buffer int) (<-chan CircuitEvent, func()) {
+	return r.circuitEvents.subscribe(buffer)
+}
+
+// watchCircuit logs, counts and publishes the state changes of a region's circuit
+func (r *Router) watchCircuit(region string, health *RegionHealth) {
+	health.OnStateChange(func(from, to CircuitState, cause string) {
+		log.Printf("Circuit for region %s changed from %s to %s: %s", region, from, to, cause)
+
+		if r.metrics != nil {
+			r.metrics.CircuitTransitions.WithLabelValues(region, from.String(), to.String(), cause).Inc()
+		}
+
+		r.circuitEvents.publish(CircuitEvent{
+			Region: region,
+			From:   from,
+			To:     to,
+			Cause:  cause,
+			At:     time.Now(),
+		})
+	})
+}
+
+// RegionStatus is the circuit breaker state of a region
+type RegionStatus struct {
+	Region  string `json:"region"`
+	Address string `json:"address"`
+	HealthSnapshot
+}
+
+// RegionStatuses returns the circuit breaker state of every region, ordered by name
+func (r *Router) RegionStatuses() []RegionStatus {
+	table := r.routes.Load()
+
+	statuses := make([]RegionStatus, 0, len(table.regions))
+	for region, connection := range table.regions {
+		statuses = append(statuses, RegionStatus{
+			Region:         region,
+			Address:        connection.address,
+			HealthSnapshot: connection.health.Snapshot(),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Region < statuses[j].Region
+	})
+
+	return statuses
+}
@@ -0,0 +1,123 @@
+package router
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Failover reasons recorded with a request that left its primary region
+const (
+	// FailoverReasonUnavailable means the primary region's circuit was open
+	FailoverReasonUnavailable = "primary_unavailable"
+	// FailoverReasonRetry means a transient error was retried in another region
+	FailoverReasonRetry = "retry"
+	// FailoverReasonHedge means a hedged request to another region answered first
+	FailoverReasonHedge = "hedge"
+)
+
+// FailoverTarget is a region in a failover chain
+type FailoverTarget struct {
+	Region string `yaml:"region"`
+	// Priority orders the targets; lower priorities are tried first and
+	// targets with the same priority share traffic by weight. It defaults to
+	// the target's position in the chain.
+	Priority int `yaml:"priority"`
+	// Weight is the target's share of traffic among the healthy targets of
+	// its priority (default: 1)
+	Weight int `yaml:"weight"`
+}
+
+// failoverTier is the failover targets with the same priority
+type failoverTier []FailoverTarget
+
+// buildFailoverTiers groups the failover chain of every region into tiers
+// ordered by priority. Regions without a chain fall back to their
+// FailoverMap entry.
+func buildFailoverTiers(config Config) map[string][]failoverTier {
+	chains := make(map[string][]FailoverTarget, len(config.FailoverChains)+len(config.FailoverMap))
+	for primary, failover := range config.FailoverMap {
+		chains[primary] = []FailoverTarget{{Region: failover}}
+	}
+	for primary, chain := range config.FailoverChains {
+		chains[primary] = chain
+	}
+
+	tiers := make(map[string][]failoverTier, len(chains))
+	for primary, chain := range chains {
+		targets := make([]FailoverTarget, len(chain))
+		for i, target := range chain {
+			if target.Priority == 0 {
+				target.Priority = i + 1
+			}
+			if target.Weight == 0 {
+				target.Weight = 1
+			}
+			targets[i] = target
+		}
+		sort.SliceStable(targets, func(i, j int) bool {
+			return targets[i].Priority < targets[j].Priority
+		})
+
+		var regionTiers []failoverTier
+		for i, target := range targets {
+			if i == 0 || target.Priority != targets[i-1].Priority {
+				regionTiers = append(regionTiers, nil)
+			}
+			last := len(regionTiers) - 1
+			regionTiers[last] = append(regionTiers[last], target)
+		}
+		tiers[primary] = regionTiers
+	}
+
+	return tiers
+}
+
+// validateFailoverChains checks that failover chains only name configured
+// regions other than their primary and have no negative weights
+func (c Config) validateFailoverChains() error {
+	for primary, chain := range c.FailoverChains {
+		for _, target := range chain {
+			if _, ok := c.Regions[target.Region]; !ok {
+				return fmt.Errorf("failover chain of region %s refers to unknown region %s", primary, target.Region)
+			}
+			if target.Region == primary {
+				return fmt.Errorf("failover chain of region %s includes the region itself", primary)
+			}
+			if target.Weight < 0 || target.Priority < 0 {
+				return fmt.Errorf("failover chain of region %s has a negative priority or weight for region %s",
+					primary, target.Region)
+			}
+		}
+	}
+	return nil
+}
+
+// selectFailover returns a healthy failover region for primary and the number
+// of failover tiers tried to reach it. Within a tier, healthy regions are
+// chosen at random in proportion to their weights.
+func (t *routingTable) selectFailover(primary string, healthy func(region string) bool) (string, int, bool) {
+	for i, tier := range t.failover[primary] {
+		var candidates []FailoverTarget
+		total := 0
+		for _, target := range tier {
+			if healthy(target.Region) {
+				candidates = append(candidates, target)
+				total += target.Weight
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		pick := rand.Intn(total)
+		for _, target := range candidates {
+			if pick < target.Weight {
+				return target.Region, i + 1, true
+			}
+			pick -= target.Weight
+		}
+	}
+
+	return "", 0, false
+}
@@ -17,16 +17,100 @@ const (
 	CircuitHalfOpen
 )
 
-// Constants for circuit breaker configuration
+// String returns the name of the circuit state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the circuit state by name
+func (s CircuitState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Causes of circuit state changes
+const (
+	CauseConsecutiveFailures = "consecutive_failures"
+	CauseErrorRate           = "error_rate"
+	CauseSlowCallRate        = "slow_call_rate"
+	CauseOpenDurationElapsed = "open_duration_elapsed"
+	CauseProbeFailed         = "probe_failed"
+	CauseProbeSucceeded      = "probe_succeeded"
+	CauseNotServing          = "not_serving"
+	CauseServing             = "serving"
+)
+
+// Default circuit breaker configuration
 const (
 	// Number of consecutive failures needed to open circuit
-	FailureThreshold = 5
+	DefaultFailureThreshold = 5
 	// How long to keep circuit open before testing again
-	ResetTimeout = 30 * time.Second
-	// Allowed errors within a time window
-	ErrorWindow = 60 * time.Second
+	DefaultOpenDuration = 30 * time.Second
+	// Period over which error and slow call rates are evaluated
+	DefaultErrorWindow = 60 * time.Second
+	// Calls needed within the window before rates can open the circuit
+	DefaultMinimumRequests = 10
+	// Concurrent requests allowed through a half-open circuit
+	DefaultHalfOpenProbes = 1
 )
 
+// BreakerConfig holds the circuit breaker policy of a region. Zero values use
+// the defaults; rate thresholds of zero are disabled.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit
+	FailureThreshold int `yaml:"failure_threshold"`
+	// ErrorRateThreshold opens the circuit when this fraction (0-1) of the
+	// calls within Window failed
+	ErrorRateThreshold float64 `yaml:"error_rate_threshold"`
+	// SlowCallRateThreshold opens the circuit when this fraction (0-1) of the
+	// calls within Window took longer than SlowCallDuration
+	SlowCallRateThreshold float64       `yaml:"slow_call_rate_threshold"`
+	SlowCallDuration      time.Duration `yaml:"slow_call_duration"`
+	// MinimumRequests is the number of calls within Window needed before the
+	// rate thresholds apply
+	MinimumRequests int           `yaml:"minimum_requests"`
+	Window          time.Duration `yaml:"window"`
+	// OpenDuration is how long the circuit stays open before probing
+	OpenDuration time.Duration `yaml:"open_duration"`
+	// HalfOpenMaxProbes limits the requests in flight while half-open
+	HalfOpenMaxProbes int `yaml:"half_open_max_probes"`
+}
+
+// withDefaults returns the config with zero values replaced by defaults
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = DefaultFailureThreshold
+	}
+	if c.MinimumRequests <= 0 {
+		c.MinimumRequests = DefaultMinimumRequests
+	}
+	if c.Window <= 0 {
+		c.Window = DefaultErrorWindow
+	}
+	if c.OpenDuration <= 0 {
+		c.OpenDuration = DefaultOpenDuration
+	}
+	if c.HalfOpenMaxProbes <= 0 {
+		c.HalfOpenMaxProbes = DefaultHalfOpenProbes
+	}
+	return c
+}
+
+// callOutcome is a call recorded in the evaluation window
+type callOutcome struct {
+	at     time.Time
+	failed bool
+	slow   bool
+}
+
 // RegionHealth tracks the health status of a region
 type RegionHealth struct {
 	// Current circuit state
@@ -35,33 +119,68 @@ type RegionHealth struct {
 	ConsecutiveFailures int
 	// Last time the circuit state changed
 	LastStateChange time.Time
-	// Timestamps of recent errors for windowed evaluation
-	RecentErrors []time.Time
+
+	config BreakerConfig
+	// calls holds the outcomes within the window, oldest first, and
+	// failedCalls and slowCalls count the failed and slow ones
+	calls       []callOutcome
+	failedCalls int
+	slowCalls   int
+	// probes is the number of requests in flight through a half-open circuit
+	probes int
+	// lastCause is why the circuit last changed state
+	lastCause string
+	// onStateChange is called with the mutex held after every state change
+	onStateChange func(from, to CircuitState, cause string)
 	// Mutex for thread safety
 	mutex sync.RWMutex
 }
 
 // NewRegionHealth creates a new RegionHealth with initial state
-func NewRegionHealth() *RegionHealth {
+func NewRegionHealth(config BreakerConfig) *RegionHealth {
 	return &RegionHealth{
 		State:           CircuitClosed,
 		LastStateChange: time.Now(),
-		RecentErrors:    make([]time.Time, 0),
+		config:          config.withDefaults(),
 	}
 }
 
-// RecordSuccess records a successful request to the region
-func (rh *RegionHealth) RecordSuccess() {
+// SetConfig replaces the breaker policy, keeping the current state
+func (rh *RegionHealth) SetConfig(config BreakerConfig) {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+	rh.config = config.withDefaults()
+}
+
+// OnStateChange registers a function called after every state change with
+// the previous state, the new state and the cause. It is called with the
+// health lock held and must not block or call back into the RegionHealth.
+func (rh *RegionHealth) OnStateChange(fn func(from, to CircuitState, cause string)) {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+	rh.onStateChange = fn
+}
+
+// RecordSuccess records a successful request to the region and how long it took
+func (rh *RegionHealth) RecordSuccess(latency time.Duration) {
 	rh.mutex.Lock()
 	defer rh.mutex.Unlock()
 
 	// Reset failures on success
 	rh.ConsecutiveFailures = 0
+	slow := rh.config.SlowCallDuration > 0 && latency > rh.config.SlowCallDuration
+	now := time.Now()
 
-	// If we're in half-open state and got a success, close the circuit
-	if rh.State == CircuitHalfOpen {
-		rh.State = CircuitClosed
-		rh.LastStateChange = time.Now()
+	switch rh.State {
+	case CircuitHalfOpen:
+		rh.releaseProbe()
+		// A slow probe keeps the region under test
+		if !slow {
+			rh.setState(CircuitClosed, now, CauseProbeSucceeded)
+		}
+	case CircuitClosed:
+		rh.record(callOutcome{at: now, slow: slow})
+		rh.evaluate(now)
 	}
 }
 
@@ -72,44 +191,188 @@ func (rh *RegionHealth) RecordFailure() {
 
 	// Increment consecutive failures
 	rh.ConsecutiveFailures++
-
-	// Add to recent errors
 	now := time.Now()
-	rh.RecentErrors = append(rh.RecentErrors, now)
 
-	// Clean up old errors outside the window
-	var recentErrors []time.Time
-	for _, t := range rh.RecentErrors {
-		if now.Sub(t) <= ErrorWindow {
-			recentErrors = append(recentErrors, t)
-		}
+	switch rh.State {
+	case CircuitHalfOpen:
+		// A failed probe opens the circuit again
+		rh.releaseProbe()
+		rh.setState(CircuitOpen, now, CauseProbeFailed)
+	case CircuitClosed:
+		rh.record(callOutcome{at: now, failed: true})
+		rh.evaluate(now)
 	}
-	rh.RecentErrors = recentErrors
+}
+
+// MarkNotServing opens the circuit of a region whose issuer reports that it
+// is not serving
+func (rh *RegionHealth) MarkNotServing() {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
 
-	// Check if we need to open the circuit
-	if rh.State == CircuitClosed && rh.ConsecutiveFailures >= FailureThreshold {
-		rh.State = CircuitOpen
-		rh.LastStateChange = now
+	// Keep an open circuit open for another open duration
+	if rh.State == CircuitOpen {
+		rh.LastStateChange = time.Now()
+		return
 	}
+	rh.setState(CircuitOpen, time.Now(), CauseNotServing)
 }
 
-// IsHealthy returns true if the region should receive traffic
+// MarkServing records that a region's issuer reports it is serving. A circuit
+// opened because the issuer was not serving moves to half-open so requests
+// probe it again. Health checks are not requests, so the window, the
+// consecutive failures and the half-open probes are left alone.
+func (rh *RegionHealth) MarkServing() {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	if rh.State == CircuitOpen && rh.lastCause == CauseNotServing {
+		rh.setState(CircuitHalfOpen, time.Now(), CauseServing)
+	}
+}
+
+// record adds a call to the window. It must be called with the mutex held.
+func (rh *RegionHealth) record(outcome callOutcome) {
+	rh.calls = append(rh.calls, outcome)
+	rh.countCall(outcome, 1)
+	rh.prune(outcome.at)
+}
+
+// prune drops the calls that left the window. It must be called with the
+// mutex held.
+func (rh *RegionHealth) prune(now time.Time) {
+	cutoff := now.Add(-rh.config.Window)
+	i := 0
+	for i < len(rh.calls) && rh.calls[i].at.Before(cutoff) {
+		rh.countCall(rh.calls[i], -1)
+		i++
+	}
+	rh.calls = rh.calls[i:]
+}
+
+// countCall adds delta to the counters matching a call's outcome
+func (rh *RegionHealth) countCall(outcome callOutcome, delta int) {
+	if outcome.failed {
+		rh.failedCalls += delta
+	}
+	if outcome.slow {
+		rh.slowCalls += delta
+	}
+}
+
+// evaluate opens the circuit when a threshold is crossed. It must be called
+// with the mutex held.
+func (rh *RegionHealth) evaluate(now time.Time) {
+	if rh.ConsecutiveFailures >= rh.config.FailureThreshold {
+		rh.setState(CircuitOpen, now, CauseConsecutiveFailures)
+		return
+	}
+
+	total := len(rh.calls)
+	if total < rh.config.MinimumRequests {
+		return
+	}
+
+	if rh.config.ErrorRateThreshold > 0 && float64(rh.failedCalls)/float64(total) >= rh.config.ErrorRateThreshold {
+		rh.setState(CircuitOpen, now, CauseErrorRate)
+		return
+	}
+	if rh.config.SlowCallRateThreshold > 0 && float64(rh.slowCalls)/float64(total) >= rh.config.SlowCallRateThreshold {
+		rh.setState(CircuitOpen, now, CauseSlowCallRate)
+	}
+}
+
+// setState moves the circuit to a new state and starts a new window. It must
+// be called with the mutex held.
+func (rh *RegionHealth) setState(state CircuitState, now time.Time, cause string) {
+	if rh.State == state {
+		return
+	}
+	from := rh.State
+	rh.State = state
+	rh.LastStateChange = now
+	rh.lastCause = cause
+	rh.calls = nil
+	rh.failedCalls = 0
+	rh.slowCalls = 0
+	if state != CircuitHalfOpen {
+		rh.probes = 0
+	}
+	if state == CircuitClosed {
+		rh.ConsecutiveFailures = 0
+	}
+
+	if rh.onStateChange != nil {
+		rh.onStateChange(from, state, cause)
+	}
+}
+
+// releaseProbe ends a half-open probe. It must be called with the mutex held.
+func (rh *RegionHealth) releaseProbe() {
+	if rh.probes > 0 {
+		rh.probes--
+	}
+}
+
+// halfOpenIfDue moves an open circuit to half-open once the open duration has
+// passed. It must be called with the mutex held.
+func (rh *RegionHealth) halfOpenIfDue(now time.Time) {
+	if rh.State == CircuitOpen && now.Sub(rh.LastStateChange) > rh.config.OpenDuration {
+		rh.setState(CircuitHalfOpen, now, CauseOpenDurationElapsed)
+	}
+}
+
+// IsHealthy returns true if the region should receive traffic. A half-open
+// region is healthy while it has probes left.
 func (rh *RegionHealth) IsHealthy() bool {
-	rh.mutex.RLock()
-	defer rh.mutex.RUnlock()
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
 
 	// Check if we should try half-open state
-	if rh.State == CircuitOpen && time.Since(rh.LastStateChange) > ResetTimeout {
-		// Transition to half-open to test the waters
-		rh.mutex.RUnlock()
-		rh.mutex.Lock()
-		rh.State = CircuitHalfOpen
-		rh.LastStateChange = time.Now()
-		rh.mutex.Unlock()
-		rh.mutex.RLock()
+	rh.halfOpenIfDue(time.Now())
+
+	switch rh.State {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		return rh.probes < rh.config.HalfOpenMaxProbes
+	default:
+		return false
 	}
+}
+
+// Acquire reserves the region for a request. Through a half-open circuit it
+// takes one of the probes, which the next RecordSuccess or RecordFailure
+// returns. It returns false when the region should not receive the request.
+func (rh *RegionHealth) Acquire() bool {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	rh.halfOpenIfDue(time.Now())
+
+	switch rh.State {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if rh.probes >= rh.config.HalfOpenMaxProbes {
+			return false
+		}
+		rh.probes++
+		return true
+	default:
+		return false
+	}
+}
+
+// Release returns the probe taken by Acquire for a request that was abandoned
+// before the region answered
+func (rh *RegionHealth) Release() {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
 
-	return rh.State == CircuitClosed || rh.State == CircuitHalfOpen
+	if rh.State == CircuitHalfOpen {
+		rh.releaseProbe()
+	}
 }
 
 // GetState returns the current circuit state
@@ -119,6 +382,42 @@ func (rh *RegionHealth) GetState() CircuitState {
 	return rh.State
 }
 
+// HealthSnapshot is the circuit breaker state of a region at a point in time
+type HealthSnapshot struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	// WindowCalls is the number of calls within the evaluation window, and
+	// ErrorRate and SlowCallRate the shares of them that failed or were slow
+	WindowCalls     int       `json:"window_calls"`
+	ErrorRate       float64   `json:"error_rate"`
+	SlowCallRate    float64   `json:"slow_call_rate"`
+	HalfOpenProbes  int       `json:"half_open_probes"`
+	LastStateChange time.Time `json:"last_state_change"`
+	LastCause       string    `json:"last_cause,omitempty"`
+}
+
+// Snapshot returns the current circuit breaker state
+func (rh *RegionHealth) Snapshot() HealthSnapshot {
+	rh.mutex.Lock()
+	defer rh.mutex.Unlock()
+
+	rh.prune(time.Now())
+
+	snapshot := HealthSnapshot{
+		State:               rh.State,
+		ConsecutiveFailures: rh.ConsecutiveFailures,
+		WindowCalls:         len(rh.calls),
+		HalfOpenProbes:      rh.probes,
+		LastStateChange:     rh.LastStateChange,
+		LastCause:           rh.lastCause,
+	}
+	if len(rh.calls) > 0 {
+		snapshot.ErrorRate = float64(rh.failedCalls) / float64(len(rh.calls))
+		snapshot.SlowCallRate = float64(rh.slowCalls) / float64(len(rh.calls))
+	}
+	return snapshot
+}
+
 // GetHealth returns a value between 0.0 and 1.0 representing health
 func (rh *RegionHealth) GetHealth() float64 {
 	rh.mutex.RLock()
@@ -129,17 +428,11 @@ func (rh *RegionHealth) GetHealth() float64 {
 		return 0.0
 	}
 
-	// If no errors, health is 1.0
-	if len(rh.RecentErrors) == 0 {
+	// If no calls, health is 1.0
+	if len(rh.calls) == 0 {
 		return 1.0
 	}
 
-	// Calculate health based on recent errors
-	// More errors = lower health
-	errorRatio := float64(len(rh.RecentErrors)) / 10.0 // 10 errors = 0 health
-	health := 1.0 - errorRatio
-	if health < 0 {
-		health = 0
-	}
-	return health
+	// Health is the success rate within the window
+	return 1.0 - float64(rh.failedCalls)/float64(len(rh.calls))
 }
@@ -0,0 +1,169 @@
+package router
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Health check defaults
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 5 * time.Second
+)
+
+// authServiceName is the service whose status the issuers report through grpc.health.v1
+var authServiceName = proto.AuthService_ServiceDesc.ServiceName
+
+// HealthCheckConfig holds the grpc.health.v1 health checking configuration
+type HealthCheckConfig struct {
+	// Interval between Check calls, or between attempts to restart a
+	// failed Watch stream (default: 10s)
+	Interval time.Duration `yaml:"interval"`
+	// Timeout of a Check call (default: 5s)
+	Timeout time.Duration `yaml:"timeout"`
+	// Watch streams status changes from each issuer instead of polling Check
+	Watch bool `yaml:"watch"`
+}
+
+// runPeriodicHealthCheck performs regular health checks on all regions
+func (r *Router) runPeriodicHealthCheck() {
+	ticker := time.NewTicker(r.healthCheckInterval)
+	defer ticker.Stop()
+
+	// Watch streams start right away; checks wait for the first tick
+	if r.routes.Load().config.HealthCheck.Watch {
+		r.checkAllRegionsHealth()
+	}
+	for {
+		select {
+		case <-ticker.C:
+			r.checkAllRegionsHealth()
+		case <-r.stopHealthCheck:
+			return
+		}
+	}
+}
+
+// checkAllRegionsHealth checks every region, or starts watching the regions
+// that are not being watched
+func (r *Router) checkAllRegionsHealth() {
+	table := r.routes.Load()
+	for region, connection := range table.regions {
+		if !table.config.HealthCheck.Watch {
+			go r.checkRegion(region, connection, table.config.HealthCheck.Timeout)
+			continue
+		}
+		if connection.watching.CompareAndSwap(false, true) {
+			go r.watchRegion(region, connection)
+		}
+	}
+}
+
+// checkRegion calls the issuer's health service once
+func (r *Router) checkRegion(region string, connection *regionConn, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(connection.ctx, timeout)
+	defer cancel()
+
+	// Skip regions drained by a reload
+	if !connection.acquire() {
+		return
+	}
+	defer connection.release()
+
+	start := time.Now()
+	resp, err := connection.healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: authServiceName})
+	latency := time.Since(start)
+
+	if err != nil {
+		r.recordHealthError(region, connection, err)
+		return
+	}
+	if r.metrics != nil {
+		r.metrics.ResponseLatency.WithLabelValues(region, "health_check").Observe(latency.Seconds())
+	}
+	r.recordServingStatus(region, connection, resp.Status)
+}
+
+// watchRegion follows the issuer's health status until the stream fails or
+// the region is drained. The next health check tick restarts it.
+func (r *Router) watchRegion(region string, connection *regionConn) {
+	defer connection.watching.Store(false)
+
+	stream, err := connection.healthClient.Watch(connection.ctx, &healthpb.HealthCheckRequest{Service: authServiceName})
+	if err != nil {
+		r.recordHealthError(region, connection, err)
+		return
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			r.recordHealthError(region, connection, err)
+			return
+		}
+		r.recordServingStatus(region, connection, resp.Status)
+	}
+}
+
+// recordServingStatus updates a region's health from its reported status. An
+// issuer that reports it is not serving is taken out of rotation at once, and
+// probed again once it reports serving.
+func (r *Router) recordServingStatus(region string, connection *regionConn, servingStatus healthpb.HealthCheckResponse_ServingStatus) {
+	r.healthMutex.Lock()
+	defer r.healthMutex.Unlock()
+
+	health := connection.health
+	switch servingStatus {
+	case healthpb.HealthCheckResponse_SERVING:
+		health.MarkServing()
+	case healthpb.HealthCheckResponse_NOT_SERVING, healthpb.HealthCheckResponse_SERVICE_UNKNOWN:
+		log.Printf("Region %s reports status %s", region, servingStatus)
+		health.MarkNotServing()
+	default:
+		health.RecordFailure()
+	}
+	r.updateHealthMetric(region, health)
+}
+
+// recordHealthError records a failed health check. Drained regions and
+// issuers without a health service are not counted as failures.
+func (r *Router) recordHealthError(region string, connection *regionConn, err error) {
+	if connection.ctx.Err() != nil {
+		return
+	}
+	if status.Code(err) == codes.Unimplemented {
+		log.Printf("Region %s does not implement grpc.health.v1", region)
+		return
+	}
+
+	r.healthMutex.Lock()
+	defer r.healthMutex.Unlock()
+
+	connection.health.RecordFailure()
+	if r.metrics != nil {
+		r.metrics.ErrorCount.WithLabelValues(region, "health_check").Inc()
+	}
+	log.Printf("Health check failed for region %s: %v", region, err)
+	r.updateHealthMetric(region, connection.health)
+}
+
+// updateHealthMetric publishes a region's health to Prometheus
+func (r *Router) updateHealthMetric(region string, health *RegionHealth) {
+	if r.metrics == nil {
+		return
+	}
+	healthValue := 0.0
+	if health.IsHealthy() {
+		healthValue = health.GetHealth()
+	}
+	r.metrics.RegionHealthStatus.WithLabelValues(region).Set(healthValue)
+}
@@ -0,0 +1,330 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// Hedging defaults
+const (
+	// Percentile of the primary region's latencies after which a hedge is sent
+	defaultHedgePercentile = 95
+	// Latencies kept per region to compute the percentile
+	hedgeLatencySamples = 256
+	// Latencies needed before a region's requests are hedged
+	minHedgeSamples = 20
+	// MTI of the reversal advice releasing the losing call of a hedge
+	hedgeReversalMTI = "0420"
+	// hedgedBin is the value of the BINs in the hedging table
+	hedgedBin = "hedged"
+)
+
+// HedgingConfig selects the authorizations that are also sent to a failover
+// region when the primary region is slow to answer
+type HedgingConfig struct {
+	// Bins lists the BIN prefixes and ranges, in the syntax of the BIN
+	// routes, whose authorizations are hedged. Hedging is off when empty.
+	Bins []string `yaml:"bins"`
+	// Percentile of the primary region's recent latencies after which the
+	// hedge is sent (default: 95)
+	Percentile float64 `yaml:"percentile"`
+	// MinDelay is the shortest time to wait for the primary region
+	MinDelay time.Duration `yaml:"min_delay"`
+}
+
+// buildHedgingTable compiles the hedged BINs. It returns nil when hedging is off.
+func buildHedgingTable(config HedgingConfig) (*BinTable, error) {
+	if len(config.Bins) == 0 {
+		return nil, nil
+	}
+	if config.Percentile < 0 || config.Percentile >= 100 {
+		return nil, fmt.Errorf("hedging percentile %v must be between 0 and 100", config.Percentile)
+	}
+
+	routes := make(map[string]string, len(config.Bins))
+	for _, bin := range config.Bins {
+		routes[bin] = hedgedBin
+	}
+	table, err := NewBinTable(routes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build hedged BIN table: %w", err)
+	}
+	return table, nil
+}
+
+// latencyTracker keeps the latest latencies of a region's successful calls
+type latencyTracker struct {
+	mutex   sync.Mutex
+	samples [hedgeLatencySamples]time.Duration
+	next    int
+	count   int
+}
+
+// record adds the latency of a successful call
+func (l *latencyTracker) record(latency time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.samples[l.next] = latency
+	l.next = (l.next + 1) % len(l.samples)
+	if l.count < len(l.samples) {
+		l.count++
+	}
+}
+
+// percentile returns the latency below which p percent of the recorded calls
+// completed. It returns false until enough calls have been recorded.
+func (l *latencyTracker) percentile(p float64) (time.Duration, bool) {
+	l.mutex.Lock()
+	if l.count < minHedgeSamples {
+		l.mutex.Unlock()
+		return 0, false
+	}
+	samples := make([]time.Duration, l.count)
+	copy(samples, l.samples[:l.count])
+	l.mutex.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i] < samples[j]
+	})
+	index := int(math.Ceil(p/100*float64(len(samples)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return samples[index], true
+}
+
+// callResult is the outcome of a request sent to a region
+type callResult struct {
+	region   string
+	request  *proto.AuthRequest
+	response *proto.AuthResponse
+	err      error
+	elapsed  time.Duration
+}
+
+// call sends a request to a region and records its latency when it succeeds
+func (c *regionConn) call(ctx context.Context, region string, request *proto.AuthRequest) callResult {
+	start := time.Now()
+	response, err := c.client.ProcessAuth(ctx, request)
+	elapsed := time.Since(start)
+	if err == nil {
+		c.latencies.record(elapsed)
+	}
+
+	return callResult{region: region, request: request, response: response, err: err, elapsed: elapsed}
+}
+
+// hedgeDelay returns how long to wait for the primary region before hedging
+// a request. Only authorization requests for hedged BINs that are routed to
+// their primary region are hedged, once the region has enough latencies
+// recorded. Follow-ups of an original must reach its region and are never
+// hedged.
+func (r *Router) hedgeDelay(table *routingTable, request *proto.AuthRequest, connection *regionConn) (time.Duration, bool) {
+	if table.hedging == nil || request.Region != request.PrimaryRegion || request.OriginalData != nil ||
+		request.MessageClass != proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION || request.Mti[2] != '0' {
+		return 0, false
+	}
+	if _, ok := table.hedging.Lookup(request.Pan); !ok || len(table.failover[request.PrimaryRegion]) == 0 {
+		return 0, false
+	}
+
+	percentile := table.config.Hedging.Percentile
+	if percentile == 0 {
+		percentile = defaultHedgePercentile
+	}
+	delay, ok := connection.latencies.percentile(percentile)
+	if !ok {
+		return 0, false
+	}
+	if delay < table.config.Hedging.MinDelay {
+		delay = table.config.Hedging.MinDelay
+	}
+	return delay, true
+}
+
+// processHedged sends a request to the primary region and, if it has not
+// answered after delay, the same request to a failover region. The first
+// response wins and the other call is cancelled; an approval the losing
+// region may have given is reversed. The connection must have been acquired
+// and is released when its call completes. The health of every call except
+// the returned one is recorded.
+func (r *Router) processHedged(ctx context.Context, table *routingTable, connection *regionConn,
+	request *proto.AuthRequest, delay time.Duration) callResult {
+	results := make(chan callResult, 2)
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	go func() {
+		defer connection.release()
+		results <- connection.call(primaryCtx, request.Region, request)
+	}()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case result := <-results:
+		cancelPrimary()
+		return result
+	case <-timer.C:
+	}
+
+	// Hedge to the failover region the primary would fail over to
+	region, hops, ok := table.selectFailover(request.PrimaryRegion, func(region string) bool {
+		r.healthMutex.RLock()
+		defer r.healthMutex.RUnlock()
+		health, exists := table.health(region)
+		return exists && health.IsHealthy()
+	})
+	hedgeConnection, connected := table.region(region)
+	if !ok || !connected || !r.acquireRegion(table, region) {
+		result := <-results
+		cancelPrimary()
+		return result
+	}
+	if !hedgeConnection.acquire() {
+		r.releaseRegion(table, region)
+		result := <-results
+		cancelPrimary()
+		return result
+	}
+
+	hedgeRequest := protobuf.Clone(request).(*proto.AuthRequest)
+	hedgeRequest.Region = region
+	hedgeRequest.FailoverHops = int32(hops)
+	hedgeRequest.FailoverReason = FailoverReasonHedge
+	hedgeCtx, cancelHedge := context.WithCancel(ctx)
+	go func() {
+		defer hedgeConnection.release()
+		results <- hedgeConnection.call(hedgeCtx, region, hedgeRequest)
+	}()
+
+	log.Printf("Region %s has not answered transaction %s after %v, hedging to %s",
+		request.Region, request.Stan, delay, region)
+	if r.metrics != nil {
+		r.metrics.HedgeCount.WithLabelValues(request.Region, region).Inc()
+	}
+
+	first := <-results
+	if first.err == nil {
+		cancelPrimary()
+		cancelHedge()
+		r.recordHedgeWin(first)
+		go func() {
+			r.releaseHedgeLoser(ctx, table, <-results)
+		}()
+		return first
+	}
+
+	// The first call failed, so the other one decides
+	second := <-results
+	cancelPrimary()
+	cancelHedge()
+	if second.err == nil {
+		r.recordCallHealth(table, first)
+		r.recordHedgeWin(second)
+		return second
+	}
+
+	// Both failed: report the primary region's failure
+	if first.region == request.Region {
+		first, second = second, first
+	}
+	r.recordCallHealth(table, first)
+	return second
+}
+
+// recordHedgeWin counts a hedged request answered by the failover region
+func (r *Router) recordHedgeWin(result callResult) {
+	if result.region == result.request.PrimaryRegion {
+		return
+	}
+	log.Printf("Hedge to %s answered transaction %s first", result.region, result.request.Stan)
+	if r.metrics != nil {
+		r.metrics.HedgeWins.WithLabelValues(result.request.PrimaryRegion, result.region).Inc()
+	}
+}
+
+// recordCallHealth records the outcome of a call that is not answered to the
+// acquirer. Calls cancelled because the other region answered first return
+// their half-open probe without counting as a failure.
+func (r *Router) recordCallHealth(table *routingTable, result callResult) {
+	r.healthMutex.Lock()
+	defer r.healthMutex.Unlock()
+
+	health, ok := table.health(result.region)
+	if !ok {
+		return
+	}
+	switch {
+	case result.err == nil:
+		health.RecordSuccess(result.elapsed)
+	case errors.Is(result.err, context.Canceled) || status.Code(result.err) == codes.Canceled:
+		health.Release()
+	default:
+		health.RecordFailure()
+	}
+}
+
+// releaseRegion returns the circuit breaker reservation of a request that was
+// not sent
+func (r *Router) releaseRegion(table *routingTable, region string) {
+	r.healthMutex.RLock()
+	defer r.healthMutex.RUnlock()
+	if health, ok := table.health(region); ok {
+		health.Release()
+	}
+}
+
+// releaseHedgeLoser reverses the authorization the losing region of a hedge
+// may have approved. Only a decline from the losing region needs no reversal;
+// a cancelled call may still have been processed by the issuer.
+func (r *Router) releaseHedgeLoser(ctx context.Context, table *routingTable, loser callResult) {
+	r.recordCallHealth(table, loser)
+	if loser.err == nil && loser.response.ResponseCode != "00" {
+		return
+	}
+
+	connection, ok := table.region(loser.region)
+	if !ok || !connection.acquire() {
+		log.Printf("Cannot reverse hedged transaction %s: region %s has been drained", loser.request.Stan, loser.region)
+		return
+	}
+	defer connection.release()
+
+	reversalCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx),
+		time.Duration(table.config.Regions[loser.region].TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	_, err := connection.client.ProcessAuth(reversalCtx, &proto.AuthRequest{
+		Mti:              hedgeReversalMTI,
+		Pan:              loser.request.Pan,
+		Amount:           loser.request.Amount,
+		TransmissionTime: loser.request.TransmissionTime,
+		Stan:             loser.request.Stan,
+		Region:           loser.region,
+		MessageClass:     proto.MessageClass_MESSAGE_CLASS_REVERSAL,
+		OriginalData: &proto.OriginalData{
+			Mti:              loser.request.Mti,
+			Stan:             loser.request.Stan,
+			TransmissionTime: loser.request.TransmissionTime,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to reverse hedged transaction %s in region %s: %v", loser.request.Stan, loser.region, err)
+		if r.metrics != nil {
+			r.metrics.ErrorCount.WithLabelValues(loser.region, "hedge_reversal").Inc()
+		}
+		return
+	}
+	log.Printf("Reversed losing hedge of transaction %s in region %s", loser.request.Stan, loser.region)
+}
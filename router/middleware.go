@@ -0,0 +1,289 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/TFMV/pulse/iso"
+	"github.com/TFMV/pulse/proto"
+	"github.com/moov-io/iso8583"
+)
+
+// Built-in middlewares
+const (
+	// MiddlewareChaos injects faults when chaos testing is enabled
+	MiddlewareChaos = "chaos"
+	// MiddlewareLoadShedding declines requests while the router is overloaded
+	MiddlewareLoadShedding = "load_shedding"
+	// MiddlewareRateLimit declines requests over a rate limit
+	MiddlewareRateLimit = "rate_limit"
+	// MiddlewareDuplicates answers retransmissions with the original response
+	MiddlewareDuplicates = "duplicates"
+	// MiddlewareParse converts the message to the issuer request
+	MiddlewareParse = "parse"
+	// MiddlewareHealth records the outcome of the issuer call for the circuit breaker
+	MiddlewareHealth = "health"
+	// MiddlewareMetrics counts requests, errors and latencies by region
+	MiddlewareMetrics = "metrics"
+	// MiddlewareStorage stores authorizations
+	MiddlewareStorage = "storage"
+)
+
+// DefaultMiddleware is the middleware chain used when none is configured
+var DefaultMiddleware = []string{
+	MiddlewareChaos,
+	MiddlewareDuplicates,
+	MiddlewareLoadShedding,
+	MiddlewareRateLimit,
+	MiddlewareParse,
+	MiddlewareHealth,
+	MiddlewareMetrics,
+	MiddlewareStorage,
+}
+
+// Exchange is the routing state of a message passing through the middleware
+// chain. Middlewares read it from the context with ExchangeFromContext.
+type Exchange struct {
+	// MTI of the message
+	MTI string
+	// Request is the issuer request. The parse middleware sets it for
+	// authorization, financial and completion messages, and the middlewares
+	// after it may change it before the message is routed.
+	Request *proto.AuthRequest
+	// Region is the region that answered, or on whose behalf stand-in
+	// processing answered
+	Region string
+	// Response is the response of the issuer or of stand-in processing
+	Response *proto.AuthResponse
+	// Err is the error of the issuer call
+	Err error
+	// Elapsed is how long the issuer call took
+	Elapsed time.Duration
+	// StandIn is set when stand-in processing answered
+	StandIn bool
+
+	// table is the routing table the message was routed with
+	table *routingTable
+}
+
+// exchangeKey is the context key of the exchange of a message
+type exchangeKey struct{}
+
+// ExchangeFromContext returns the exchange of the message being handled
+func ExchangeFromContext(ctx context.Context) (*Exchange, bool) {
+	exchange, ok := ctx.Value(exchangeKey{}).(*Exchange)
+	return exchange, ok
+}
+
+// exchangeFrom returns the exchange of ctx, or an empty one for callers that
+// bypass HandleMessage
+func exchangeFrom(ctx context.Context) *Exchange {
+	if exchange, ok := ExchangeFromContext(ctx); ok {
+		return exchange
+	}
+	return &Exchange{}
+}
+
+// RegisterMiddleware makes a middleware available to the chain under name,
+// replacing a built-in middleware of the same name. Middlewares must be
+// registered before Initialize builds the chain.
+func (r *Router) RegisterMiddleware(name string, middleware iso.Middleware) {
+	r.middlewares[name] = middleware
+}
+
+// builtinMiddlewares returns the middlewares every router provides
+func (r *Router) builtinMiddlewares() map[string]iso.Middleware {
+	return map[string]iso.Middleware{
+		MiddlewareChaos:        r.chaosMiddleware,
+		MiddlewareLoadShedding: r.loadSheddingMiddleware,
+		MiddlewareRateLimit:    r.rateLimitMiddleware,
+		MiddlewareDuplicates:   r.duplicatesMiddleware,
+		MiddlewareParse:        r.parseMiddleware,
+		MiddlewareHealth:       r.healthMiddleware,
+		MiddlewareMetrics:      r.metricsMiddleware,
+		MiddlewareStorage:      r.storageMiddleware,
+	}
+}
+
+// buildChain wraps the routing of messages in the named middlewares, in order
+func (r *Router) buildChain(names []string) (iso.MessageHandler, error) {
+	if len(names) == 0 {
+		names = DefaultMiddleware
+	}
+
+	middlewares := make([]iso.Middleware, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		middleware, ok := r.middlewares[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown middleware %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("middleware %q is listed twice", name)
+		}
+		seen[name] = true
+		middlewares = append(middlewares, middleware)
+	}
+
+	// Routing takes the half-open circuit's probes, which only the health
+	// middleware gives back
+	if !seen[MiddlewareHealth] {
+		return nil, fmt.Errorf("middleware %q is required", MiddlewareHealth)
+	}
+
+	return iso.Chain(iso.MessageHandlerFunc(r.route), middlewares...), nil
+}
+
+// chaosMiddleware fails messages when the chaos engine injects a fault
+func (r *Router) chaosMiddleware(next iso.MessageHandler) iso.MessageHandler {
+	return iso.MessageHandlerFunc(func(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+		if r.chaosEngine != nil && r.chaosEngine.ShouldInjectFault() {
+			return nil, iso.NewProcessingError(iso.ErrorSystem, r.chaosEngine.InjectFault("processing_message"))
+		}
+		return next.HandleMessage(ctx, message)
+	})
+}
+
+// duplicatesMiddleware answers retransmissions with the original response and
+// keeps the response of every other routed message for them
+func (r *Router) duplicatesMiddleware(next iso.MessageHandler) iso.MessageHandler {
+	return iso.MessageHandlerFunc(func(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+		exchange := exchangeFrom(ctx)
+		if r.duplicates == nil || !routedByBin(exchange.MTI) {
+			return next.HandleMessage(ctx, message)
+		}
+		key := duplicateKey(message, exchange.MTI)
+		if key == "" {
+			return next.HandleMessage(ctx, message)
+		}
+
+		original, transmission, err := r.duplicates.Begin(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for duplicate transmission: %w", err)
+		}
+		if original != nil {
+			log.Printf("Duplicate transmission %s, returning the original response", key)
+			if r.metrics != nil {
+				r.metrics.DuplicateCount.WithLabelValues(exchange.MTI).Inc()
+			}
+			return r.authResponseToIso(original, message)
+		}
+		defer transmission.Release()
+
+		response, err := next.HandleMessage(ctx, message)
+
+		// Keep the issuer's answer for retransmissions
+		if exchange.Response != nil {
+			transmission.Complete(ctx, exchange.Response)
+		}
+		return response, err
+	})
+}
+
+// parseMiddleware converts messages routed by BIN to the issuer request
+func (r *Router) parseMiddleware(next iso.MessageHandler) iso.MessageHandler {
+	return iso.MessageHandlerFunc(func(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+		exchange := exchangeFrom(ctx)
+		if exchange.Request == nil && routedByBin(exchange.MTI) {
+			if err := r.parse(message, exchange); err != nil {
+				return nil, err
+			}
+		}
+		return next.HandleMessage(ctx, message)
+	})
+}
+
+// healthMiddleware records the outcome of the issuer call that answered the
+// message for the region's circuit breaker
+func (r *Router) healthMiddleware(next iso.MessageHandler) iso.MessageHandler {
+	return iso.MessageHandlerFunc(func(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+		response, err := next.HandleMessage(ctx, message)
+
+		exchange := exchangeFrom(ctx)
+		if exchange.table == nil || exchange.StandIn || (exchange.Err == nil && exchange.Response == nil) {
+			return response, err
+		}
+
+		r.healthMutex.Lock()
+		if health, ok := exchange.table.health(exchange.Region); ok {
+			if exchange.Err != nil {
+				health.RecordFailure()
+			} else {
+				health.RecordSuccess(exchange.Elapsed)
+			}
+		}
+		r.healthMutex.Unlock()
+
+		return response, err
+	})
+}
+
+// metricsMiddleware counts the issuer's responses and errors and observes
+// their latency
+func (r *Router) metricsMiddleware(next iso.MessageHandler) iso.MessageHandler {
+	return iso.MessageHandlerFunc(func(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+		response, err := next.HandleMessage(ctx, message)
+
+		exchange := exchangeFrom(ctx)
+		if r.metrics == nil || exchange.Region == "" {
+			return response, err
+		}
+
+		switch {
+		case exchange.StandIn:
+			r.metrics.RequestCount.WithLabelValues(standInRegionLabel, exchange.MTI, exchange.Response.ResponseCode).Inc()
+		case exchange.Err != nil:
+			errorType := "unknown_error"
+			if exchange.Request != nil && exchange.Request.MessageClass == proto.MessageClass_MESSAGE_CLASS_REVERSAL {
+				errorType = "reversal"
+			} else if errors.Is(exchange.Err, context.DeadlineExceeded) {
+				errorType = "timeout"
+			}
+			r.metrics.ErrorCount.WithLabelValues(exchange.Region, errorType).Inc()
+		case exchange.Response != nil:
+			r.metrics.RequestCount.WithLabelValues(exchange.Region, exchange.MTI, exchange.Response.ResponseCode).Inc()
+			r.metrics.ResponseLatency.WithLabelValues(exchange.Region, exchange.MTI).Observe(exchange.Elapsed.Seconds())
+		}
+
+		return response, err
+	})
+}
+
+// storageMiddleware stores the authorizations answered by an issuer or by
+// stand-in processing
+func (r *Router) storageMiddleware(next iso.MessageHandler) iso.MessageHandler {
+	return iso.MessageHandlerFunc(func(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+		response, err := next.HandleMessage(ctx, message)
+
+		exchange := exchangeFrom(ctx)
+		if r.storage == nil || exchange.Request == nil || exchange.Response == nil ||
+			exchange.Request.MessageClass == proto.MessageClass_MESSAGE_CLASS_REVERSAL {
+			return response, err
+		}
+
+		request, region := exchange.Request, exchange.Region
+		approved := exchange.Response.ResponseCode == "00"
+
+		// Save asynchronously to avoid impacting response time. The save
+		// outlives the request, so it must not inherit its cancellation.
+		go func() {
+			storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			defer cancel()
+
+			if err := r.storage.SaveAuthorization(storeCtx, request, region, approved); err != nil {
+				log.Printf("Failed to store authorization: %v", err)
+			}
+		}()
+
+		return response, err
+	})
+}
+
+// routedByBin reports whether messages of the MTI are routed by BIN rather
+// than following an original transaction
+func routedByBin(mti string) bool {
+	return !isReversal(mti) && messageClass(mti) != proto.MessageClass_MESSAGE_CLASS_UNSPECIFIED
+}
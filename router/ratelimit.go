@@ -0,0 +1,213 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/TFMV/pulse/iso"
+	"github.com/moov-io/iso8583"
+)
+
+// Rate limit keys
+const (
+	RateLimitByTerminal   = "terminal"   // Card acceptor terminal ID (field 41)
+	RateLimitByMerchant   = "merchant"   // Card acceptor ID (field 42)
+	RateLimitByBin        = "bin"        // Leading PAN digits (field 2)
+	RateLimitByConnection = "connection" // Client connection the message arrived on
+)
+
+// Rate limit defaults
+const (
+	// Decline code of requests over a limit: issuer or switch inoperative
+	defaultRateLimitDeclineCode = "91"
+	// Leading PAN digits that make up the BIN of a BIN rate limit
+	defaultRateLimitBinLength = 6
+	// How often buckets that have refilled are dropped
+	rateLimitSweepInterval = time.Minute
+)
+
+// RateLimitConfig holds the ingress rate limits
+type RateLimitConfig struct {
+	// DeclineCode answers requests over a limit (default: 91)
+	DeclineCode string          `yaml:"decline_code"`
+	Rules       []RateLimitRule `yaml:"rules"`
+}
+
+// RateLimitRule limits the requests sharing a key to a sustained rate with
+// bursts. Requests without the key's field are not limited by the rule.
+type RateLimitRule struct {
+	// Key is terminal, merchant, bin or connection
+	Key string `yaml:"key"`
+	// Rate is the sustained requests per second allowed per key value
+	Rate float64 `yaml:"rate"`
+	// Burst is the requests allowed at once (default: the rate, at least 1)
+	Burst int `yaml:"burst"`
+	// BinLength is the leading PAN digits of a bin key (default: 6)
+	BinLength int `yaml:"bin_length"`
+	// DeclineCode overrides the decline code of the rate limits
+	DeclineCode string `yaml:"decline_code"`
+}
+
+// validate checks the rate limit configuration
+func (c RateLimitConfig) validate() error {
+	if c.DeclineCode != "" && len(c.DeclineCode) != 2 {
+		return fmt.Errorf("rate limit decline code %q must have 2 characters", c.DeclineCode)
+	}
+	for _, rule := range c.Rules {
+		switch rule.Key {
+		case RateLimitByTerminal, RateLimitByMerchant, RateLimitByBin, RateLimitByConnection:
+		default:
+			return fmt.Errorf("unknown rate limit key %q", rule.Key)
+		}
+		if rule.Rate <= 0 {
+			return fmt.Errorf("rate limit by %s needs a positive rate", rule.Key)
+		}
+		if rule.Burst < 0 || rule.BinLength < 0 {
+			return fmt.Errorf("rate limit by %s has a negative burst or BIN length", rule.Key)
+		}
+		if rule.DeclineCode != "" && len(rule.DeclineCode) != 2 {
+			return fmt.Errorf("rate limit decline code %q must have 2 characters", rule.DeclineCode)
+		}
+	}
+	return nil
+}
+
+// tokenBucket holds the tokens left for a key value
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter enforces a rate limit rule with a token bucket per key value
+type RateLimiter struct {
+	rule        RateLimitRule
+	burst       float64
+	declineCode string
+
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a rate limiter for rule, declining requests over the
+// limit with declineCode unless the rule sets its own
+func NewRateLimiter(rule RateLimitRule, declineCode string) *RateLimiter {
+	if rule.BinLength <= 0 {
+		rule.BinLength = defaultRateLimitBinLength
+	}
+	burst := float64(rule.Burst)
+	if burst <= 0 {
+		burst = math.Max(1, math.Ceil(rule.Rate))
+	}
+	if rule.DeclineCode != "" {
+		declineCode = rule.DeclineCode
+	}
+	if declineCode == "" {
+		declineCode = defaultRateLimitDeclineCode
+	}
+
+	return &RateLimiter{
+		rule:        rule,
+		burst:       burst,
+		declineCode: declineCode,
+		buckets:     make(map[string]*tokenBucket),
+		lastSweep:   time.Now(),
+	}
+}
+
+// Allow takes a token for the key value. It returns false when the key value
+// is over the limit.
+func (l *RateLimiter) Allow(value string) bool {
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[value]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[value] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rule.Rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// sweep drops the buckets that have refilled, which behave like new ones. It
+// must be called with the mutex held.
+func (l *RateLimiter) sweep(now time.Time) {
+	for value, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rule.Rate >= l.burst {
+			delete(l.buckets, value)
+		}
+	}
+	l.lastSweep = now
+}
+
+// keyValue returns the value of the rule's key in a message, or an empty
+// string when the message does not have it
+func (l *RateLimiter) keyValue(ctx context.Context, message *iso8583.Message) string {
+	switch l.rule.Key {
+	case RateLimitByTerminal:
+		return presentField(message, 41)
+	case RateLimitByMerchant:
+		return presentField(message, 42)
+	case RateLimitByBin:
+		pan := presentField(message, 2)
+		if len(pan) < l.rule.BinLength {
+			return pan
+		}
+		return pan[:l.rule.BinLength]
+	case RateLimitByConnection:
+		if session, ok := iso.SessionFromContext(ctx); ok {
+			return session.RemoteAddr()
+		}
+	}
+	return ""
+}
+
+// rateLimitMiddleware declines authorization and financial requests over a
+// rate limit before they reach an issuer
+func (r *Router) rateLimitMiddleware(next iso.MessageHandler) iso.MessageHandler {
+	return iso.MessageHandlerFunc(func(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+		mti := exchangeFrom(ctx).MTI
+		if !routedByBin(mti) || !isRequest(mti) {
+			return next.HandleMessage(ctx, message)
+		}
+
+		for _, limiter := range r.rateLimiters {
+			value := limiter.keyValue(ctx, message)
+			if value == "" || limiter.Allow(value) {
+				continue
+			}
+
+			log.Printf("Rate limit by %s exceeded for %s, declining %s with %s",
+				limiter.rule.Key, value, mti, limiter.declineCode)
+			if r.metrics != nil {
+				r.metrics.RateLimitedCount.WithLabelValues(limiter.rule.Key).Inc()
+			}
+			return iso.NewResponse(message, limiter.declineCode)
+		}
+
+		return next.HandleMessage(ctx, message)
+	})
+}
+
+// isRequest reports whether the MTI is a request (xx0x) rather than an advice,
+// which the acquirer has already acted on and which must not be declined
+func isRequest(mti string) bool {
+	return len(mti) == 4 && mti[2] == '0'
+}
@@ -0,0 +1,264 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/TFMV/pulse/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// routingTable is the routing state built from one Config. It is replaced as
+// a whole on reload and never modified after it is published.
+type routingTable struct {
+	config   Config
+	bins     *BinTable
+	regions  map[string]*regionConn
+	failover map[string][]failoverTier
+	// hedging holds the hedged BINs, or nil when hedging is off
+	hedging *BinTable
+	retry   retryPolicy
+	// shadow holds the shadow traffic policy, or nil when shadowing is off
+	shadow *shadowPolicy
+}
+
+// regionConn is the gRPC connection and health of a region. A region whose
+// address is unchanged keeps its connection and health across reloads.
+type regionConn struct {
+	address      string
+	conn         *grpc.ClientConn
+	client       proto.AuthServiceClient
+	healthClient healthpb.HealthClient
+	health       *RegionHealth
+	latencies    latencyTracker
+	budget       retryBudget
+
+	// ctx is cancelled when the region is drained, ending its health watch
+	ctx    context.Context
+	cancel context.CancelFunc
+	// watching is set while a health watch stream is running
+	watching atomic.Bool
+
+	// mutex is held for reading by calls in flight, so draining waits for them
+	mutex  sync.RWMutex
+	closed bool
+}
+
+// acquire reserves the connection for a call. It returns false once the
+// region has been drained; otherwise release must be called after the call.
+func (c *regionConn) acquire() bool {
+	c.mutex.RLock()
+	if c.closed {
+		c.mutex.RUnlock()
+		return false
+	}
+	return true
+}
+
+// release ends a call started with acquire
+func (c *regionConn) release() {
+	c.mutex.RUnlock()
+}
+
+// drain waits for the calls in flight and closes the connection
+func (c *regionConn) drain(region string) {
+	c.cancel()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if err := c.conn.Close(); err != nil {
+		log.Printf("Error closing connection to %s: %v", region, err)
+	}
+	log.Printf("Drained %s region at %s", region, c.address)
+}
+
+// region returns the connection of a region
+func (t *routingTable) region(name string) (*regionConn, bool) {
+	conn, ok := t.regions[name]
+	return conn, ok
+}
+
+// health returns the health of a region
+func (t *routingTable) health(name string) (*RegionHealth, bool) {
+	conn, ok := t.regions[name]
+	if !ok {
+		return nil, false
+	}
+	return conn.health, true
+}
+
+// determineRegion determines the appropriate region based on the PAN's BIN
+func (t *routingTable) determineRegion(pan string) string {
+	if region, ok := t.bins.Lookup(pan); ok {
+		return region
+	}
+	return t.config.DefaultRegion
+}
+
+// Validate checks that the BIN routes, hedged BINs, retry policy and shadow
+// policy compile, that the rate limits and load shedding settings are valid,
+// and that every region the BIN routes, default region, failover map,
+// failover chains and shadow policy refer to is configured. The shadow region
+// must take no live traffic.
+func (c Config) Validate() error {
+	if _, err := NewBinTable(c.BinRoutes); err != nil {
+		return fmt.Errorf("failed to build BIN routing table: %w", err)
+	}
+	if _, err := buildHedgingTable(c.Hedging); err != nil {
+		return err
+	}
+	if _, err := buildRetryPolicy(c.Retry); err != nil {
+		return err
+	}
+	if _, err := buildShadowPolicy(c.Shadow); err != nil {
+		return err
+	}
+	if err := c.RateLimits.validate(); err != nil {
+		return err
+	}
+	if err := c.LoadShedding.validate(); err != nil {
+		return err
+	}
+	return c.validateRegions()
+}
+
+// validateRegions checks that every region the config refers to is configured
+func (c Config) validateRegions() error {
+	for bin, region := range c.BinRoutes {
+		if _, ok := c.Regions[region]; !ok {
+			return fmt.Errorf("BIN route %q refers to unknown region %s", bin, region)
+		}
+	}
+	if _, ok := c.Regions[c.DefaultRegion]; !ok {
+		return fmt.Errorf("default region %s is not configured", c.DefaultRegion)
+	}
+	for primary, failover := range c.FailoverMap {
+		if _, ok := c.Regions[failover]; !ok {
+			return fmt.Errorf("failover for region %s refers to unknown region %s", primary, failover)
+		}
+	}
+	if _, ok := c.Regions[c.Shadow.Region]; c.Shadow.Region != "" && !ok {
+		return fmt.Errorf("shadow region %s is not configured", c.Shadow.Region)
+	}
+	if err := c.validateShadowRegion(); err != nil {
+		return err
+	}
+
+	return c.validateFailoverChains()
+}
+
+// Reload validates config and switches routing to it while traffic keeps
+// flowing. Connections are opened to new regions and to regions whose
+// address changed before the switch; connections that are no longer used are
+// closed once their calls in flight complete. Duplicate detection, stand-in
+// settings, rate limits, load shedding, the health check interval and the
+// middleware chain are not reloaded.
+func (r *Router) Reload(config Config) error {
+	r.reloadMutex.Lock()
+	defer r.reloadMutex.Unlock()
+
+	if err := r.apply(config); err != nil {
+		return err
+	}
+	log.Printf("Reloaded routing configuration with %d BIN routes and %d regions",
+		len(config.BinRoutes), len(config.Regions))
+	return nil
+}
+
+// apply builds the routing table for config and publishes it. It must be
+// called with the reload mutex held.
+func (r *Router) apply(config Config) error {
+	bins, err := NewBinTable(config.BinRoutes)
+	if err != nil {
+		return fmt.Errorf("failed to build BIN routing table: %w", err)
+	}
+	hedging, err := buildHedgingTable(config.Hedging)
+	if err != nil {
+		return err
+	}
+	retry, err := buildRetryPolicy(config.Retry)
+	if err != nil {
+		return err
+	}
+	shadow, err := buildShadowPolicy(config.Shadow)
+	if err != nil {
+		return err
+	}
+	if err := config.validateRegions(); err != nil {
+		return err
+	}
+
+	previous := r.routes.Load()
+	table := &routingTable{
+		config:   config,
+		bins:     bins,
+		regions:  make(map[string]*regionConn, len(config.Regions)),
+		failover: buildFailoverTiers(config),
+		hedging:  hedging,
+		retry:    retry,
+		shadow:   shadow,
+	}
+
+	var opened []*regionConn
+	for region, cfg := range config.Regions {
+		address := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+		if existing, ok := previous.regions[region]; ok && existing.address == address {
+			existing.health.SetConfig(cfg.Breaker)
+			table.regions[region] = existing
+			continue
+		}
+
+		conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			for _, c := range opened {
+				c.cancel()
+				c.conn.Close()
+			}
+			return fmt.Errorf("failed to connect to %s region: %w", region, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		connection := &regionConn{
+			address:      address,
+			conn:         conn,
+			client:       proto.NewAuthServiceClient(conn),
+			healthClient: healthpb.NewHealthClient(conn),
+			health:       NewRegionHealth(cfg.Breaker),
+			ctx:          ctx,
+			cancel:       cancel,
+		}
+		r.watchCircuit(region, connection.health)
+		opened = append(opened, connection)
+		table.regions[region] = connection
+		log.Printf("Connected to %s region at %s", region, address)
+
+		// Set initial health status in Prometheus
+		if r.metrics != nil {
+			r.metrics.RegionHealthStatus.WithLabelValues(region).Set(1.0)
+		}
+	}
+
+	r.routes.Store(table)
+
+	// Drain the connections the new table no longer uses
+	for region, existing := range previous.regions {
+		if table.regions[region] == existing {
+			continue
+		}
+		if _, ok := table.regions[region]; !ok && r.metrics != nil {
+			r.metrics.RegionHealthStatus.DeleteLabelValues(region)
+		}
+		go existing.drain(region)
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// Retry defaults
+const (
+	defaultRetryBackoff     = 10 * time.Millisecond
+	defaultRetryBudgetRatio = 0.1
+	defaultRetryBudgetMax   = 10
+)
+
+// RetryConfig holds the retry policy of requests whose issuer call failed
+// with a transient error
+type RetryConfig struct {
+	// MaxAttempts is the number of calls a request may make, including the
+	// first. Retries are off when it is 0 or 1.
+	MaxAttempts int `yaml:"max_attempts"`
+	// Codes are the gRPC status codes that are retried (default: UNAVAILABLE)
+	Codes []string `yaml:"codes"`
+	// Backoff is the wait before the first retry, doubled for each further
+	// retry (default: 10ms)
+	Backoff time.Duration `yaml:"backoff"`
+	// BudgetRatio is the share of a region's requests that may be retried:
+	// every request adds this many tokens to the region's retry budget and
+	// every retry of a call that failed in the region takes one (default: 0.1)
+	BudgetRatio float64 `yaml:"budget_ratio"`
+	// BudgetMax caps the tokens a region's retry budget can save up (default: 10)
+	BudgetMax float64 `yaml:"budget_max"`
+}
+
+// retryPolicy is a RetryConfig with defaults applied and codes parsed
+type retryPolicy struct {
+	config RetryConfig
+	codes  map[codes.Code]bool
+}
+
+// buildRetryPolicy parses the retry configuration
+func buildRetryPolicy(config RetryConfig) (retryPolicy, error) {
+	if config.MaxAttempts < 0 || config.BudgetRatio < 0 || config.BudgetMax < 0 {
+		return retryPolicy{}, errors.New("retry attempts and budget must not be negative")
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = defaultRetryBackoff
+	}
+	if config.BudgetRatio == 0 {
+		config.BudgetRatio = defaultRetryBudgetRatio
+	}
+	if config.BudgetMax == 0 {
+		config.BudgetMax = defaultRetryBudgetMax
+	}
+
+	names := config.Codes
+	if len(names) == 0 {
+		names = []string{"UNAVAILABLE"}
+	}
+	policy := retryPolicy{config: config, codes: make(map[codes.Code]bool, len(names))}
+	for _, name := range names {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(name)))); err != nil {
+			return retryPolicy{}, fmt.Errorf("invalid retryable code %q: %w", name, err)
+		}
+		policy.codes[code] = true
+	}
+
+	return policy, nil
+}
+
+// retryable reports whether a failed call may be retried
+func (p retryPolicy) retryable(err error) bool {
+	return err != nil && p.config.MaxAttempts > 1 && p.codes[status.Code(err)]
+}
+
+// retryBudget is a token bucket limiting the retries caused by a region
+type retryBudget struct {
+	mutex  sync.Mutex
+	tokens float64
+	// started is set once the budget has been filled
+	started bool
+}
+
+// deposit adds the tokens earned by a request
+func (b *retryBudget) deposit(config RetryConfig) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.fill(config)
+	b.tokens += config.BudgetRatio
+	if b.tokens > config.BudgetMax {
+		b.tokens = config.BudgetMax
+	}
+}
+
+// withdraw takes the token of a retry. It returns false when the budget is spent.
+func (b *retryBudget) withdraw(config RetryConfig) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.fill(config)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// fill starts a new budget full. It must be called with the mutex held.
+func (b *retryBudget) fill(config RetryConfig) {
+	if !b.started {
+		b.tokens = config.BudgetMax
+		b.started = true
+	}
+}
+
+// process sends a request to the region of connection within the region's
+// timeout, hedging it when the primary region is slow. The connection must
+// have been acquired and is released when the call completes.
+func (r *Router) process(ctx context.Context, table *routingTable, connection *regionConn, request *proto.AuthRequest) callResult {
+	timeoutCtx, cancel := context.WithTimeout(ctx,
+		time.Duration(table.config.Regions[request.Region].TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	if delay, ok := r.hedgeDelay(table, request, connection); ok {
+		return r.processHedged(timeoutCtx, table, connection, request, delay)
+	}
+
+	defer connection.release()
+	return connection.call(timeoutCtx, request.Region, request)
+}
+
+// processWithRetries sends a request and retries it while the call fails
+// with a retryable code, the retry budget of the failed region allows it and
+// the deadline of ctx leaves time. Retries go to a healthy failover region of
+// the primary when there is one and the request is not pinned to its region,
+// and to the failed region otherwise. The connection must have been acquired
+// and is released when the call completes. The health of every call except
+// the returned one is recorded.
+func (r *Router) processWithRetries(ctx context.Context, table *routingTable, connection *regionConn,
+	request *proto.AuthRequest, pinned bool) callResult {
+	policy := table.retry
+	if policy.config.MaxAttempts > 1 {
+		connection.budget.deposit(policy.config)
+	}
+
+	result := r.process(ctx, table, connection, request)
+	backoff := policy.config.Backoff
+	for attempt := 1; attempt < policy.config.MaxAttempts && policy.retryable(result.err); attempt++ {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+			log.Printf("No time left to retry transaction %s", request.Stan)
+			break
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result
+		}
+		backoff *= 2
+
+		region, hops, next, ok := r.retryTarget(table, result, pinned)
+		if !ok {
+			log.Printf("No region available to retry transaction %s", request.Stan)
+			break
+		}
+		failed, _ := table.region(result.region)
+		if !failed.budget.withdraw(policy.config) {
+			next.release()
+			r.releaseRegion(table, region)
+			log.Printf("Retry budget of region %s spent, not retrying transaction %s", result.region, request.Stan)
+			if r.metrics != nil {
+				r.metrics.RetryBudgetExhausted.WithLabelValues(result.region).Inc()
+			}
+			break
+		}
+		r.recordCallHealth(table, result)
+
+		log.Printf("Retrying transaction %s in region %s after %s from region %s",
+			request.Stan, region, status.Code(result.err), result.region)
+		if r.metrics != nil {
+			r.metrics.RetryCount.WithLabelValues(result.region, region, status.Code(result.err).String()).Inc()
+		}
+
+		retryRequest := protobuf.Clone(result.request).(*proto.AuthRequest)
+		retryRequest.Region = region
+		retryRequest.FailoverHops = int32(hops)
+		if region != result.region {
+			retryRequest.FailoverReason = FailoverReasonRetry
+		}
+		result = r.process(ctx, table, next, retryRequest)
+	}
+
+	return result
+}
+
+// retryTarget chooses and reserves the region to retry a failed call in. It
+// returns the region, its failover hops and its acquired connection.
+func (r *Router) retryTarget(table *routingTable, failed callResult, pinned bool) (string, int, *regionConn, bool) {
+	region, hops, ok := "", 0, false
+	if !pinned {
+		region, hops, ok = table.selectFailover(failed.request.PrimaryRegion, func(candidate string) bool {
+			r.healthMutex.RLock()
+			defer r.healthMutex.RUnlock()
+			health, exists := table.health(candidate)
+			return candidate != failed.region && exists && health.IsHealthy()
+		})
+	}
+	if !ok {
+		region, hops = failed.region, int(failed.request.FailoverHops)
+	}
+
+	connection, exists := table.region(region)
+	if !exists || !r.acquireRegion(table, region) {
+		return "", 0, nil, false
+	}
+	if !connection.acquire() {
+		r.releaseRegion(table, region)
+		return "", 0, nil, false
+	}
+	return region, hops, connection, true
+}
@@ -0,0 +1,236 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/TFMV/pulse/iso"
+	"github.com/TFMV/pulse/proto"
+	"github.com/TFMV/pulse/storage"
+	"github.com/moov-io/iso8583"
+)
+
+// Response codes used when answering without the issuer
+const (
+	reversalAcknowledged   = "00" // Approved or completed successfully
+	invalidTransactionCode = "12" // Invalid transaction
+	originalNotFoundCode   = "25" // Unable to locate record
+)
+
+// originalDataFieldLength is the minimum length of field 90: MTI (4), STAN (6)
+// and transmission time (10)
+const originalDataFieldLength = 20
+
+// isReversal reports whether the MTI is a reversal request or advice (04xx)
+func isReversal(mti string) bool {
+	return len(mti) == 4 && mti[1] == '4'
+}
+
+// parseOriginalData parses the original data elements (field 90): original
+// MTI, STAN, transmission time, acquirer ID and forwarding institution ID
+func parseOriginalData(value string) (*proto.OriginalData, error) {
+	if len(value) < originalDataFieldLength {
+		return nil, fmt.Errorf("original data elements %q too short", value)
+	}
+
+	original := &proto.OriginalData{
+		Mti:              value[0:4],
+		Stan:             value[4:10],
+		TransmissionTime: value[10:20],
+	}
+	if len(value) >= 31 {
+		original.AcquirerId = value[20:31]
+	}
+	if len(value) >= 42 {
+		original.ForwardingId = value[31:42]
+	}
+
+	return original, nil
+}
+
+// presentField returns the value of a field, or an empty string when the field
+// is not present in the message
+func presentField(message *iso8583.Message, id int) string {
+	if _, ok := message.GetFields()[id]; !ok {
+		return ""
+	}
+	value, _ := message.GetString(id)
+	return value
+}
+
+// originalKey returns the storage key of the transaction referenced by the
+// original data elements. Follow-up messages come from the terminal that sent
+// the original, so its terminal ID is taken from the message itself.
+func originalKey(message *iso8583.Message, original *proto.OriginalData) storage.TransactionKey {
+	return storage.NewTransactionKey(original.Stan, original.TransmissionTime, original.AcquirerId, presentField(message, 41))
+}
+
+// findOriginal looks up the transaction referenced by the original data
+// elements. It returns nil when no stored transaction matches the original
+// key and the PAN of the message.
+func (r *Router) findOriginal(ctx context.Context, message *iso8583.Message, original *proto.OriginalData) (*proto.AuthRecord, error) {
+	key := originalKey(message, original)
+	record, err := r.storage.GetTransaction(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up original transaction %s: %w", key, err)
+	}
+	if record == nil {
+		return nil, nil
+	}
+
+	if pan := presentField(message, 2); pan != "" && record.Pan != pan {
+		return nil, nil
+	}
+
+	return record, nil
+}
+
+// handleReversal routes a reversal to the region that processed the original
+// authorization. Repeated reversals of the same original are acknowledged
+// without contacting the issuer again.
+func (r *Router) handleReversal(ctx context.Context, message *iso8583.Message, mti string) (*iso8583.Message, error) {
+	original, err := parseOriginalData(presentField(message, 90))
+	if err != nil {
+		if r.metrics != nil {
+			r.metrics.ErrorCount.WithLabelValues("unknown", "parse_request").Inc()
+		}
+		return nil, iso.NewProcessingError(iso.ErrorFormat, fmt.Errorf("invalid reversal: %w", err))
+	}
+
+	if r.storage == nil {
+		return nil, iso.NewProcessingError(iso.ErrorSystem,
+			errors.New("reversals require storage to locate the original transaction"))
+	}
+
+	// Serialize reversals of the same original so a repeated advice that
+	// arrives while the first is in flight sees its outcome
+	key := originalKey(message, original)
+	unlock := r.reversalLocks.lock(key.String())
+	defer unlock()
+
+	record, err := r.findOriginal(ctx, message, original)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		log.Printf("Original transaction %s for reversal %s not found", original.Stan, mti)
+		return iso.NewResponse(message, originalNotFoundCode, 90)
+	}
+
+	if record.Reversed {
+		log.Printf("Transaction %s already reversed, acknowledging repeated %s", original.Stan, mti)
+		return iso.NewResponse(message, reversalAcknowledged, 90)
+	}
+
+	if !record.Approved {
+		log.Printf("Transaction %s was declined, nothing to reverse", original.Stan)
+		return iso.NewResponse(message, reversalAcknowledged, 90)
+	}
+
+	// Reversals always go to the region that approved the original
+	targetRegion := record.Region
+	reversalRequest := &proto.AuthRequest{
+		Mti:              mti,
+		Pan:              record.Pan,
+		Amount:           record.Amount,
+		TransmissionTime: presentField(message, 7),
+		Stan:             presentField(message, 11),
+		Region:           targetRegion,
+		OriginalData:     original,
+		MessageClass:     proto.MessageClass_MESSAGE_CLASS_REVERSAL,
+		AcquirerId:       presentField(message, 32),
+		TerminalId:       presentField(message, 41),
+	}
+	if amount := presentField(message, 4); amount != "" {
+		reversalRequest.Amount = amount
+	}
+
+	log.Printf("Routing reversal %s of transaction %s to region %s", reversalRequest.Stan, original.Stan, targetRegion)
+
+	table := r.routes.Load()
+	connection, ok := table.region(targetRegion)
+	if !ok || !connection.acquire() {
+		if r.metrics != nil {
+			r.metrics.ErrorCount.WithLabelValues(targetRegion, "no_client").Inc()
+		}
+		return nil, iso.NewProcessingError(iso.ErrorIssuerUnavailable,
+			fmt.Errorf("no client available for region %s", targetRegion))
+	}
+	defer connection.release()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(table.config.Regions[targetRegion].TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	response, err := connection.client.ProcessAuth(timeoutCtx, reversalRequest)
+
+	exchange := exchangeFrom(ctx)
+	exchange.table, exchange.Request, exchange.Region = table, reversalRequest, targetRegion
+	exchange.Response, exchange.Err, exchange.Elapsed = response, err, time.Since(startTime)
+
+	if err != nil {
+		return nil, iso.NewProcessingError(issuerErrorClass(err),
+			fmt.Errorf("failed to process reversal in region %s: %w", targetRegion, err))
+	}
+
+	// Record the reversal before answering so a repeated advice is acknowledged locally
+	if response.ResponseCode == reversalAcknowledged {
+		if err := r.storage.MarkReversed(ctx, key); err != nil {
+			log.Printf("Failed to record reversal of transaction %s: %v", key, err)
+		}
+	}
+
+	responseMessage, err := r.authResponseToIso(response, message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert AuthResponse to ISO: %w", err)
+	}
+	if err := responseMessage.Field(90, presentField(message, 90)); err != nil {
+		return nil, fmt.Errorf("failed to set original data elements: %w", err)
+	}
+
+	return responseMessage, nil
+}
+
+// keyLocker provides a mutex per key
+type keyLocker struct {
+	mutex sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is a mutex shared by the callers holding or waiting for a key
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks key and returns the function that unlocks it
+func (k *keyLocker) lock(key string) func() {
+	k.mutex.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyLock)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mutex.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		k.mutex.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mutex.Unlock()
+	}
+}
@@ -8,16 +8,15 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/TFMV/pulse/chaos"
+	"github.com/TFMV/pulse/iso"
 	"github.com/TFMV/pulse/metrics"
 	"github.com/moov-io/iso8583"
-	"github.com/moov-io/iso8583/encoding"
-	"github.com/moov-io/iso8583/field"
-	"github.com/moov-io/iso8583/prefix"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/TFMV/pulse/proto"
 	"github.com/TFMV/pulse/storage"
@@ -29,158 +28,146 @@ type Config struct {
 	DefaultRegion string                  `yaml:"default_region"`
 	Regions       map[string]RegionConfig `yaml:"regions"`
 	FailoverMap   map[string]string       `yaml:"failover_map"` // Maps primary region to fallback region
+	// FailoverChains maps primary regions to ordered failover targets. A chain
+	// replaces the region's FailoverMap entry.
+	FailoverChains map[string][]FailoverTarget `yaml:"failover_chains"`
+	HealthCheck    HealthCheckConfig           `yaml:"health_check"`
+	Hedging        HedgingConfig               `yaml:"hedging"`
+	Retry          RetryConfig                 `yaml:"retry"`
+	Shadow         ShadowConfig                `yaml:"shadow"`
+	Duplicates     DuplicateConfig             `yaml:"duplicate_detection"`
+	StandIn        StandInConfig               `yaml:"stand_in"`
+	RateLimits     RateLimitConfig             `yaml:"rate_limits"`
+	LoadShedding   LoadSheddingConfig          `yaml:"load_shedding"`
+	// Middleware lists the middlewares messages pass through before they
+	// are routed, outermost first (default: DefaultMiddleware)
+	Middleware []string `yaml:"middleware"`
 }
 
 // RegionConfig holds configuration for a specific region
 type RegionConfig struct {
-	Host      string `yaml:"host"`
-	Port      int    `yaml:"port"`
-	TimeoutMs int    `yaml:"timeout_ms"`
+	Host      string        `yaml:"host"`
+	Port      int           `yaml:"port"`
+	TimeoutMs int           `yaml:"timeout_ms"`
+	Breaker   BreakerConfig `yaml:"breaker"`
 }
 
 // Router handles routing ISO8583 messages to the appropriate regional processors
 type Router struct {
-	config              Config
-	connections         map[string]*grpc.ClientConn
-	clients             map[string]proto.AuthServiceClient
+	routes              atomic.Pointer[routingTable]
+	reloadMutex         sync.Mutex
 	chaosEngine         *chaos.Engine
-	spec                *iso8583.MessageSpec
-	regionHealth        map[string]*RegionHealth
 	metrics             *metrics.Metrics
 	healthMutex         sync.RWMutex
 	healthCheckInterval time.Duration
 	stopHealthCheck     chan struct{}
 	storage             storage.Storage
+	reversalLocks       keyLocker
+	duplicates          *DuplicateDetector
+	standIn             *StandInProcessor
+	rateLimiters        []*RateLimiter
+	loadShedder         *LoadShedder
+	shadowInFlight      atomic.Int64
+	circuitEvents       circuitEvents
+	middlewares         map[string]iso.Middleware
+	handler             iso.MessageHandler
 }
 
 // NewRouter creates a new router with the given configuration
-func NewRouter(config Config, chaosEngine *chaos.Engine, metricsCollector *metrics.Metrics, storage storage.Storage) *Router {
-	// Create a message spec for ISO8583 messages
-	spec := &iso8583.MessageSpec{
-		Name: "ISO 8583 v1987",
-		Fields: map[int]field.Field{
-			0:  field.NewString(field.NewSpec(4, "Message Type Indicator", encoding.ASCII, prefix.None.Fixed)),
-			2:  field.NewString(field.NewSpec(19, "Primary Account Number", encoding.ASCII, prefix.None.Fixed)),
-			4:  field.NewString(field.NewSpec(12, "Amount, Transaction", encoding.ASCII, prefix.None.Fixed)),
-			7:  field.NewString(field.NewSpec(10, "Transmission Date and Time", encoding.ASCII, prefix.None.Fixed)),
-			11: field.NewString(field.NewSpec(6, "System Trace Audit Number", encoding.ASCII, prefix.None.Fixed)),
-			39: field.NewString(field.NewSpec(2, "Response Code", encoding.ASCII, prefix.None.Fixed)),
-		},
-	}
-
-	// Initialize health status for each region
-	regionHealth := make(map[string]*RegionHealth)
-	for region := range config.Regions {
-		regionHealth[region] = NewRegionHealth()
-
-		// Set initial health status in Prometheus
-		if metricsCollector != nil {
-			metricsCollector.RegionHealthStatus.WithLabelValues(region).Set(1.0)
+func NewRouter(config Config, chaosEngine *chaos.Engine, metricsCollector *metrics.Metrics, transactionStorage storage.Storage) *Router {
+	// Keep responses for retransmissions in memory or in the transaction storage
+	var duplicates *DuplicateDetector
+	if config.Duplicates.Enabled {
+		var store DuplicateStore = storage.NewMemoryStore()
+		switch config.Duplicates.Backend {
+		case "", DuplicateBackendMemory:
+		case DuplicateBackendStorage:
+			if transactionStorage != nil {
+				store = transactionStorage
+			} else {
+				log.Printf("Duplicate detection backend %q requires storage, using memory", config.Duplicates.Backend)
+			}
+		default:
+			log.Printf("Unknown duplicate detection backend %q, using memory", config.Duplicates.Backend)
+		}
+		duplicates = NewDuplicateDetector(store, config.Duplicates.TTL)
+	}
+
+	var standIn *StandInProcessor
+	if config.StandIn.Enabled {
+		standIn = NewStandInProcessor(config.StandIn, metricsCollector)
+	}
+
+	var rateLimiters []*RateLimiter
+	for _, rule := range config.RateLimits.Rules {
+		switch rule.Key {
+		case RateLimitByTerminal, RateLimitByMerchant, RateLimitByBin, RateLimitByConnection:
+		default:
+			log.Printf("Unknown rate limit key %q, ignoring the rule", rule.Key)
+			continue
+		}
+		if rule.Rate <= 0 {
+			log.Printf("Rate limit by %s has no positive rate, ignoring the rule", rule.Key)
+			continue
 		}
+		rateLimiters = append(rateLimiters, NewRateLimiter(rule, config.RateLimits.DeclineCode))
+	}
+
+	var loadShedder *LoadShedder
+	if config.LoadShedding.MaxInFlight > 0 || config.LoadShedding.MaxQueueLatency > 0 {
+		loadShedder = NewLoadShedder(config.LoadShedding)
+	}
+
+	healthCheckInterval := config.HealthCheck.Interval
+	if healthCheckInterval <= 0 {
+		healthCheckInterval = defaultHealthCheckInterval
 	}
 
-	return &Router{
-		config:              config,
-		connections:         make(map[string]*grpc.ClientConn),
-		clients:             make(map[string]proto.AuthServiceClient),
+	rt := &Router{
 		chaosEngine:         chaosEngine,
-		spec:                spec,
-		regionHealth:        regionHealth,
 		metrics:             metricsCollector,
-		healthCheckInterval: 10 * time.Second,
+		healthCheckInterval: healthCheckInterval,
 		stopHealthCheck:     make(chan struct{}),
-		storage:             storage,
+		storage:             transactionStorage,
+		duplicates:          duplicates,
+		standIn:             standIn,
+		rateLimiters:        rateLimiters,
+		loadShedder:         loadShedder,
 	}
+
+	rt.middlewares = rt.builtinMiddlewares()
+
+	// Regions are connected by Initialize
+	rt.routes.Store(&routingTable{config: config, regions: make(map[string]*regionConn)})
+
+	return rt
 }
 
-// Initialize establishes connections to all regional services and starts health monitoring
+// Initialize builds the middleware chain, establishes connections to all
+// regional services and starts health monitoring
 func (r *Router) Initialize() error {
-	for region, cfg := range r.config.Regions {
-		address := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-		conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
-		if err != nil {
-			return fmt.Errorf("failed to connect to %s region: %w", region, err)
-		}
+	handler, err := r.buildChain(r.routes.Load().config.Middleware)
+	if err != nil {
+		return fmt.Errorf("failed to build middleware chain: %w", err)
+	}
+	r.handler = handler
 
-		r.connections[region] = conn
-		r.clients[region] = proto.NewAuthServiceClient(conn)
-		log.Printf("Connected to %s region at %s", region, address)
+	r.reloadMutex.Lock()
+	err = r.apply(r.routes.Load().config)
+	r.reloadMutex.Unlock()
+	if err != nil {
+		return err
 	}
 
 	// Start background health check
 	go r.runPeriodicHealthCheck()
 
-	return nil
-}
-
-// runPeriodicHealthCheck performs regular health checks on all regions
-func (r *Router) runPeriodicHealthCheck() {
-	ticker := time.NewTicker(r.healthCheckInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			r.checkAllRegionsHealth()
-		case <-r.stopHealthCheck:
-			return
-		}
+	// Replay stand-in approvals once issuers recover
+	if r.standIn != nil {
+		go r.runStandInReplay()
 	}
-}
 
-// checkAllRegionsHealth performs a health check on all regions
-func (r *Router) checkAllRegionsHealth() {
-	for region := range r.config.Regions {
-		go func(reg string) {
-			// Create a simple echo request to check health
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-
-			client, ok := r.clients[reg]
-			if !ok {
-				log.Printf("No client for region %s", reg)
-				return
-			}
-
-			// Simple echo request with minimal data
-			req := &proto.AuthRequest{
-				Mti:    "0800", // Network management message
-				Stan:   fmt.Sprintf("%06d", time.Now().Unix()%1000000),
-				Region: reg,
-			}
-
-			start := time.Now()
-			_, err := client.ProcessAuth(ctx, req)
-			latency := time.Since(start)
-
-			r.healthMutex.Lock()
-			defer r.healthMutex.Unlock()
-
-			// Update health status based on response
-			health := r.regionHealth[reg]
-			if err != nil {
-				health.RecordFailure()
-				if r.metrics != nil {
-					r.metrics.ErrorCount.WithLabelValues(reg, "health_check").Inc()
-				}
-				log.Printf("Health check failed for region %s: %v", reg, err)
-			} else {
-				health.RecordSuccess()
-				if r.metrics != nil {
-					r.metrics.ResponseLatency.WithLabelValues(reg, "0800").Observe(latency.Seconds())
-				}
-			}
-
-			// Update Prometheus metric
-			if r.metrics != nil {
-				healthValue := 0.0
-				if health.IsHealthy() {
-					healthValue = health.GetHealth()
-				}
-				r.metrics.RegionHealthStatus.WithLabelValues(reg).Set(healthValue)
-			}
-		}(region)
-	}
+	return nil
 }
 
 // Close stops health monitoring and closes all connections
@@ -188,163 +175,216 @@ func (r *Router) Close() {
 	// Stop health check goroutine
 	close(r.stopHealthCheck)
 
-	for region, conn := range r.connections {
-		if err := conn.Close(); err != nil {
-			log.Printf("Error closing connection to %s: %v", region, err)
-		}
+	for region, connection := range r.routes.Load().regions {
+		connection.drain(region)
 	}
 }
 
-// HandleMessage implements the iso.MessageHandler interface
+// HandleMessage implements the iso.MessageHandler interface. Messages pass
+// through the middleware chain and are then routed to an issuer. Messages
+// reaching a router that has not been initialized get a system error.
 func (r *Router) HandleMessage(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
-	// Check if we should inject chaos
-	if r.chaosEngine != nil && r.chaosEngine.ShouldInjectFault() {
-		return nil, r.chaosEngine.InjectFault("processing_message")
+	if r.handler == nil {
+		return nil, iso.NewProcessingError(iso.ErrorSystem, errors.New("router is not initialized"))
 	}
 
-	// Convert ISO message to AuthRequest
-	authRequest, err := r.isoToAuthRequest(message)
-	if err != nil {
-		if r.metrics != nil {
-			r.metrics.ErrorCount.WithLabelValues("unknown", "parse_request").Inc()
+	exchange := &Exchange{}
+	exchange.MTI, _ = message.GetString(0)
+	return r.handler.HandleMessage(context.WithValue(ctx, exchangeKey{}, exchange), message)
+}
+
+// route sends a message to the issuer of its region, failing over, retrying
+// or standing in as configured, and records the outcome in the exchange
+func (r *Router) route(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+	exchange := exchangeFrom(ctx)
+	mti := exchange.MTI
+
+	// Reversals go to the region that processed the original transaction
+	if isReversal(mti) {
+		return r.handleReversal(ctx, message, mti)
+	}
+
+	// Only authorization and financial messages are routed by BIN
+	if messageClass(mti) == proto.MessageClass_MESSAGE_CLASS_UNSPECIFIED {
+		log.Printf("Unsupported message type %s", mti)
+		return iso.NewResponse(message, invalidTransactionCode)
+	}
+
+	// Convert the message unless the parse middleware already did
+	if exchange.Request == nil {
+		if err := r.parse(message, exchange); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to convert ISO to AuthRequest: %w", err)
 	}
+	authRequest := exchange.Request
 
-	// Get MTI for metrics
-	mti, _ := message.GetString(0)
+	// Route with the table current when the message arrived
+	table := r.routes.Load()
+	exchange.table = table
 
 	// Determine primary region
-	primaryRegion := r.determineRegion(authRequest.Pan)
-	authRequest.Region = primaryRegion
+	primaryRegion := table.determineRegion(authRequest.Pan)
 
-	// Start timing the request
-	startTime := time.Now()
+	// Follow-up messages go to the region that processed the original, as
+	// recorded with it, whatever its BIN routes to now
+	pinned := false
+	if authRequest.OriginalData != nil && r.storage != nil {
+		record, err := r.findOriginal(ctx, message, authRequest.OriginalData)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			log.Printf("Original transaction %s for %s %s not found",
+				authRequest.OriginalData.Stan, mti, authRequest.Stan)
+			return iso.NewResponse(message, originalNotFoundCode, 90)
+		}
+		log.Printf("Routing %s %s to region %s, which processed transaction %s (primary %s, %d hops)",
+			mti, authRequest.Stan, record.Region, record.Stan, record.PrimaryRegion, record.FailoverHops)
+		primaryRegion = record.Region
+		pinned = true
+	}
+	authRequest.Region = primaryRegion
+	authRequest.PrimaryRegion = primaryRegion
 
-	// Check if the primary region is healthy
+	// Check if the primary region is healthy, taking a probe if its circuit is half-open
 	r.healthMutex.RLock()
-	regionHealth, ok := r.regionHealth[primaryRegion]
-	primaryHealthy := ok && regionHealth.IsHealthy()
+	regionHealth, ok := table.health(primaryRegion)
+	primaryHealthy := ok && regionHealth.Acquire()
 	r.healthMutex.RUnlock()
 
 	// Determine which region to use (primary or failover)
 	targetRegion := primaryRegion
 	if !primaryHealthy {
-		// Try to use a failover region
-		if failoverRegion, ok := r.config.FailoverMap[primaryRegion]; ok {
+		regionAvailable := false
+
+		// Try the failover chain in priority order
+		if pinned {
+			log.Printf("Region %s unhealthy for transaction %s, which must go to that region",
+				primaryRegion, authRequest.Stan)
+		} else if failoverRegion, hops, ok := table.selectFailover(primaryRegion, func(region string) bool {
 			r.healthMutex.RLock()
-			failoverHealth, exists := r.regionHealth[failoverRegion]
-			failoverHealthy := exists && failoverHealth.IsHealthy()
-			r.healthMutex.RUnlock()
-
-			if failoverHealthy {
-				log.Printf("Failing over from %s to %s for transaction %s",
-					primaryRegion, failoverRegion, authRequest.Stan)
-				targetRegion = failoverRegion
-				authRequest.Region = failoverRegion
-			} else {
-				log.Printf("Primary region %s unhealthy and failover %s also unhealthy for transaction %s",
-					primaryRegion, failoverRegion, authRequest.Stan)
+			defer r.healthMutex.RUnlock()
+			health, exists := table.health(region)
+			return exists && health.IsHealthy()
+		}); ok && r.acquireRegion(table, failoverRegion) {
+			log.Printf("Failing over from %s to %s after %d hops for transaction %s",
+				primaryRegion, failoverRegion, hops, authRequest.Stan)
+			targetRegion = failoverRegion
+			authRequest.Region = failoverRegion
+			authRequest.FailoverHops = int32(hops)
+			authRequest.FailoverReason = FailoverReasonUnavailable
+			regionAvailable = true
+
+			if r.metrics != nil {
+				r.metrics.FailoverCount.WithLabelValues(primaryRegion, failoverRegion, strconv.Itoa(hops)).Inc()
 			}
 		} else {
-			log.Printf("Primary region %s unhealthy and no failover configured for transaction %s",
+			log.Printf("Primary region %s unhealthy and no healthy failover for transaction %s",
 				primaryRegion, authRequest.Stan)
 		}
+
+		// Stand in for the issuer when no region can take the request
+		if !regionAvailable && r.standIn != nil {
+			return r.standInResponse(ctx, message, authRequest, primaryRegion)
+		}
 	}
 
 	log.Printf("Routing transaction %s to region %s", authRequest.Stan, targetRegion)
 
 	// Get client for target region
-	client, ok := r.clients[targetRegion]
-	if !ok {
+	connection, ok := table.region(targetRegion)
+	if !ok || !connection.acquire() {
 		if r.metrics != nil {
 			r.metrics.ErrorCount.WithLabelValues(targetRegion, "no_client").Inc()
 		}
-		return nil, fmt.Errorf("no client available for region %s", targetRegion)
+		return nil, iso.NewProcessingError(iso.ErrorIssuerUnavailable,
+			fmt.Errorf("no client available for region %s", targetRegion))
 	}
 
-	regionCfg := r.config.Regions[targetRegion]
-	timeoutDuration := time.Duration(regionCfg.TimeoutMs) * time.Millisecond
+	// Process the request, retrying transient failures
+	result := r.processWithRetries(ctx, table, connection, authRequest, pinned)
+	exchange.Request, exchange.Region = result.request, result.region
+	exchange.Response, exchange.Err, exchange.Elapsed = result.response, result.err, result.elapsed
 
-	// Create context with timeout
-	timeoutCtx, cancel := context.WithTimeout(ctx, timeoutDuration)
-	defer cancel()
-
-	// Process the request
-	response, err := client.ProcessAuth(timeoutCtx, authRequest)
-
-	// Calculate latency regardless of success/failure
-	elapsed := time.Since(startTime)
-
-	// Handle response and update metrics
-	if err != nil {
-		// Record failure for health monitoring
-		r.healthMutex.Lock()
-		if health, ok := r.regionHealth[targetRegion]; ok {
-			health.RecordFailure()
-		}
-		r.healthMutex.Unlock()
-
-		if r.metrics != nil {
-			errorType := "unknown_error"
-			if errors.Is(err, context.DeadlineExceeded) {
-				errorType = "timeout"
-			}
-			r.metrics.ErrorCount.WithLabelValues(targetRegion, errorType).Inc()
-		}
+	// Mirror a sample of answered requests to the shadow region
+	r.mirror(ctx, table, result)
 
+	if result.err != nil {
 		// If this is a timeout, try to return a declined response
-		if errors.Is(err, context.DeadlineExceeded) {
-			log.Printf("Request timed out for region %s, returning timeout decline", targetRegion)
+		if errors.Is(result.err, context.DeadlineExceeded) {
+			log.Printf("Request timed out for region %s, returning timeout decline", result.region)
 			return r.createTimeoutResponse(message)
 		}
-		return nil, fmt.Errorf("failed to process request in region %s: %w", targetRegion, err)
+		return nil, iso.NewProcessingError(issuerErrorClass(result.err),
+			fmt.Errorf("failed to process request in region %s: %w", result.region, result.err))
 	}
 
-	// Record success for health monitoring
-	r.healthMutex.Lock()
-	if health, ok := r.regionHealth[targetRegion]; ok {
-		health.RecordSuccess()
-	}
-	r.healthMutex.Unlock()
-
 	// Add processing time to the response
-	response.ProcessingTimeMs = elapsed.Milliseconds()
-
-	// Update metrics for successful request
-	if r.metrics != nil {
-		r.metrics.RequestCount.WithLabelValues(targetRegion, mti, response.ResponseCode).Inc()
-		r.metrics.ResponseLatency.WithLabelValues(targetRegion, mti).Observe(elapsed.Seconds())
-	}
+	response := result.response
+	response.ProcessingTimeMs = result.elapsed.Milliseconds()
 
 	// Convert the AuthResponse back to ISO8583
 	responseMessage, err := r.authResponseToIso(response, message)
 	if err != nil {
 		if r.metrics != nil {
-			r.metrics.ErrorCount.WithLabelValues(targetRegion, "response_conversion").Inc()
+			r.metrics.ErrorCount.WithLabelValues(result.region, "response_conversion").Inc()
 		}
 		return nil, fmt.Errorf("failed to convert AuthResponse to ISO: %w", err)
 	}
+	if authRequest.OriginalData != nil {
+		if err := responseMessage.Field(90, presentField(message, 90)); err != nil {
+			return nil, fmt.Errorf("failed to set original data elements: %w", err)
+		}
+	}
+
+	return responseMessage, nil
+}
 
-	// Record the transaction in storage
-	if r.storage != nil {
-		storeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		defer cancel()
+// acquireRegion reserves a region for a request through its circuit breaker
+func (r *Router) acquireRegion(table *routingTable, region string) bool {
+	r.healthMutex.RLock()
+	defer r.healthMutex.RUnlock()
+	health, ok := table.health(region)
+	return ok && health.Acquire()
+}
 
-		// Determine if approved based on response code
-		approved := response.ResponseCode == "00"
+// standInResponse answers a request through stand-in processing on behalf of
+// the issuer of region
+func (r *Router) standInResponse(ctx context.Context, message *iso8583.Message, authRequest *proto.AuthRequest,
+	region string) (*iso8583.Message, error) {
+	response := r.standIn.Authorize(authRequest, region)
 
-		// Save to storage asynchronously to avoid impacting response time
-		go func() {
-			if err := r.storage.SaveAuthorization(storeCtx, authRequest, targetRegion, approved); err != nil {
-				log.Printf("Failed to store authorization: %v", err)
-			}
-		}()
+	// Stand-in approvals are recorded against the region whose issuer receives the advice
+	exchange := exchangeFrom(ctx)
+	exchange.Region, exchange.Response, exchange.StandIn = region, response, true
+
+	responseMessage, err := r.authResponseToIso(response, message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert stand-in response to ISO: %w", err)
+	}
+	if authRequest.OriginalData != nil {
+		if err := responseMessage.Field(90, presentField(message, 90)); err != nil {
+			return nil, fmt.Errorf("failed to set original data elements: %w", err)
+		}
 	}
 
 	return responseMessage, nil
 }
 
+// parse converts a message to the issuer request of its exchange
+func (r *Router) parse(message *iso8583.Message, exchange *Exchange) error {
+	authRequest, err := r.isoToAuthRequest(message)
+	if err != nil {
+		if r.metrics != nil {
+			r.metrics.ErrorCount.WithLabelValues("unknown", "parse_request").Inc()
+		}
+		return iso.NewProcessingError(iso.ErrorFormat,
+			fmt.Errorf("failed to convert ISO to AuthRequest: %w", err))
+	}
+	exchange.Request = authRequest
+	return nil
+}
+
 // isoToAuthRequest converts an ISO8583 message to an AuthRequest
 func (r *Router) isoToAuthRequest(message *iso8583.Message) (*proto.AuthRequest, error) {
 	mti, err := message.GetString(0)
@@ -373,19 +413,89 @@ func (r *Router) isoToAuthRequest(message *iso8583.Message) (*proto.AuthRequest,
 		return nil, fmt.Errorf("failed to get STAN: %w", err)
 	}
 
-	return &proto.AuthRequest{
+	authRequest := &proto.AuthRequest{
 		Mti:              mti,
 		Pan:              pan,
 		Amount:           amount,
 		TransmissionTime: transmissionTime,
 		Stan:             stan,
-	}, nil
+		MessageClass:     messageClass(mti),
+		AcquirerId:       presentField(message, 32),
+		TerminalId:       presentField(message, 41),
+	}
+
+	// Follow-up messages, such as completions, incremental authorizations and
+	// refunds, reference the transaction they follow
+	if value := presentField(message, 90); value != "" {
+		original, err := parseOriginalData(value)
+		if err != nil {
+			return nil, err
+		}
+		authRequest.OriginalData = original
+	}
+
+	// Card data for the issuer's card checks. Track 2 data supplies the
+	// expiration date and service code of card-present requests.
+	authRequest.ExpirationDate = presentField(message, 14)
+	authRequest.ServiceCode = presentField(message, 40)
+	if track2 := presentField(message, 35); track2 != "" {
+		expirationDate, serviceCode, err := parseTrack2(track2)
+		if err != nil {
+			return nil, err
+		}
+		if authRequest.ExpirationDate == "" {
+			authRequest.ExpirationDate = expirationDate
+		}
+		if authRequest.ServiceCode == "" {
+			authRequest.ServiceCode = serviceCode
+		}
+	}
+	authRequest.PinBlock = presentField(message, 52)
+	authRequest.Cvv = presentField(message, 48)
+
+	return authRequest, nil
+}
+
+// parseTrack2 returns the expiration date and service code of track 2 data
+// (field 35): the PAN, a separator ('=' or 'D'), the expiration date (YYMM),
+// the service code and discretionary data
+func parseTrack2(value string) (expirationDate, serviceCode string, err error) {
+	separator := strings.IndexAny(value, "=D")
+	if separator < 0 || len(value) < separator+8 {
+		return "", "", fmt.Errorf("invalid track 2 data")
+	}
+	return value[separator+1 : separator+5], value[separator+5 : separator+8], nil
+}
+
+// messageClass returns the message class of an MTI. Authorization requests
+// and advices (01xx), financial requests (0200, 0201) and completion advices
+// (0220, 0221) are routed by BIN; reversals (04xx) follow the original.
+func messageClass(mti string) proto.MessageClass {
+	if len(mti) != 4 {
+		return proto.MessageClass_MESSAGE_CLASS_UNSPECIFIED
+	}
+
+	switch mti[1] {
+	case '1':
+		return proto.MessageClass_MESSAGE_CLASS_AUTHORIZATION
+	case '2':
+		switch mti[2] {
+		case '0':
+			return proto.MessageClass_MESSAGE_CLASS_FINANCIAL
+		case '2':
+			return proto.MessageClass_MESSAGE_CLASS_COMPLETION
+		}
+	case '4':
+		return proto.MessageClass_MESSAGE_CLASS_REVERSAL
+	}
+
+	return proto.MessageClass_MESSAGE_CLASS_UNSPECIFIED
 }
 
 // authResponseToIso converts an AuthResponse to an ISO8583 message
 func (r *Router) authResponseToIso(response *proto.AuthResponse, requestMessage *iso8583.Message) (*iso8583.Message, error) {
-	// Create a new message
-	responseMessage := iso8583.NewMessage(r.spec)
+	// Create a new message using the spec of the listener that received the request
+	responseMessage := iso8583.NewMessage(requestMessage.GetSpec())
 
 	// Set MTI (response is usually request + 10)
 	err := responseMessage.Field(0, response.Mti)
@@ -394,8 +504,7 @@ func (r *Router) authResponseToIso(response *proto.AuthResponse, requestMessage
 	}
 
 	// Copy fields from request
-	fieldsToCopy := []int{2, 4, 7, 11}
-	for _, field := range fieldsToCopy {
+	for _, field := range iso.EchoedFields {
 		if value, err := requestMessage.GetString(field); err == nil {
 			if err := responseMessage.Field(field, value); err != nil {
 				return nil, fmt.Errorf("failed to set field %d: %w", field, err)
@@ -411,47 +520,15 @@ func (r *Router) authResponseToIso(response *proto.AuthResponse, requestMessage
 	return responseMessage, nil
 }
 
-// determineRegion determines the appropriate region based on the PAN's BIN
-func (r *Router) determineRegion(pan string) string {
-	if len(pan) < 6 {
-		return r.config.DefaultRegion
+// issuerErrorClass classifies a failed issuer call. Issuers that cannot be
+// reached are reported as unavailable, anything else as a system error.
+func issuerErrorClass(err error) string {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return iso.ErrorIssuerUnavailable
+	default:
+		return iso.ErrorSystem
 	}
-
-	bin := pan[:6]
-
-	for binRange, region := range r.config.BinRoutes {
-		// Check if the range is a simple prefix match
-		if !strings.Contains(binRange, "-") {
-			if strings.HasPrefix(bin, binRange) {
-				return region
-			}
-			continue
-		}
-
-		// Parse the range
-		parts := strings.Split(binRange, "-")
-		if len(parts) != 2 {
-			continue
-		}
-
-		start, err1 := strconv.Atoi(parts[0])
-		end, err2 := strconv.Atoi(parts[1])
-
-		if err1 != nil || err2 != nil {
-			continue
-		}
-
-		binInt, err := strconv.Atoi(bin[:len(parts[0])])
-		if err != nil {
-			continue
-		}
-
-		if binInt >= start && binInt <= end {
-			return region
-		}
-	}
-
-	return r.config.DefaultRegion
 }
 
 // createTimeoutResponse creates a decline response for timeout scenarios
@@ -462,16 +539,18 @@ func (r *Router) createTimeoutResponse(requestMessage *iso8583.Message) (*iso858
 	}
 
 	// Convert request MTI to response MTI
-	responseMti := mti[:2] + "10"
+	responseMti, err := iso.ResponseMTI(mti)
+	if err != nil {
+		return nil, err
+	}
 
-	responseMessage := iso8583.NewMessage(r.spec)
+	responseMessage := iso8583.NewMessage(requestMessage.GetSpec())
 	if err := responseMessage.Field(0, responseMti); err != nil {
 		return nil, fmt.Errorf("failed to set MTI: %w", err)
 	}
 
 	// Copy fields from request
-	fieldsToCopy := []int{2, 4, 7, 11}
-	for _, field := range fieldsToCopy {
+	for _, field := range iso.EchoedFields {
 		if value, err := requestMessage.GetString(field); err == nil {
 			if err := responseMessage.Field(field, value); err != nil {
 				return nil, fmt.Errorf("failed to set field %d: %w", field, err)
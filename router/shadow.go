@@ -0,0 +1,219 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// Shadow traffic defaults
+const (
+	// Mirrored requests waiting for the shadow region at once
+	defaultShadowMaxInFlight = 100
+	// shadowBin is the value of the BINs in the shadow BIN table
+	shadowBin = "shadow"
+)
+
+// Shadow comparison outcomes
+const (
+	shadowOutcomeMatch    = "match"
+	shadowOutcomeMismatch = "mismatch"
+	shadowOutcomeError    = "error"
+	shadowOutcomeDropped  = "dropped"
+)
+
+// ShadowConfig mirrors a sample of live requests to a shadow region, such as
+// a candidate issuer build, whose answers are compared with the live ones and
+// then discarded
+type ShadowConfig struct {
+	// Region is the configured region requests are mirrored to. Shadowing is
+	// off when empty.
+	Region string `yaml:"region"`
+	// SampleRate is the share of eligible requests mirrored, from 0 to 1
+	SampleRate float64 `yaml:"sample_rate"`
+	// Bins lists the BIN prefixes and ranges, in the syntax of the BIN
+	// routes, whose requests are mirrored. All BINs are mirrored when empty.
+	Bins []string `yaml:"bins"`
+	// MaxInFlight is the mirrored requests waiting for the shadow region
+	// past which samples are dropped (default: 100)
+	MaxInFlight int `yaml:"max_in_flight"`
+}
+
+// validateShadowRegion checks that the shadow region takes no live traffic.
+// Mirroring skips requests already routed to it, so a shadow region that is
+// also a BIN route, the default region or a failover target would compare
+// nothing for those requests, and its candidate issuer would answer
+// cardholders.
+func (c Config) validateShadowRegion() error {
+	region := c.Shadow.Region
+	if region == "" {
+		return nil
+	}
+	if region == c.DefaultRegion {
+		return fmt.Errorf("shadow region %s is the default region", region)
+	}
+	for bin, target := range c.BinRoutes {
+		if target == region {
+			return fmt.Errorf("shadow region %s is the route of BIN %q", region, bin)
+		}
+	}
+	for primary, failover := range c.FailoverMap {
+		if primary == region || failover == region {
+			return fmt.Errorf("shadow region %s is in the failover map", region)
+		}
+	}
+	for primary, chain := range c.FailoverChains {
+		if primary == region {
+			return fmt.Errorf("shadow region %s has a failover chain", region)
+		}
+		for _, target := range chain {
+			if target.Region == region {
+				return fmt.Errorf("shadow region %s is in the failover chain of region %s", region, primary)
+			}
+		}
+	}
+	return nil
+}
+
+// shadowPolicy is the compiled shadow configuration of a routing table
+type shadowPolicy struct {
+	config ShadowConfig
+	// bins holds the mirrored BINs, or nil when all BINs are mirrored
+	bins *BinTable
+}
+
+// buildShadowPolicy compiles the shadow configuration. It returns nil when
+// shadowing is off.
+func buildShadowPolicy(config ShadowConfig) (*shadowPolicy, error) {
+	if config.Region == "" {
+		return nil, nil
+	}
+	if config.SampleRate <= 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("shadow sample rate %v must be above 0 and at most 1", config.SampleRate)
+	}
+	if config.MaxInFlight < 0 {
+		return nil, fmt.Errorf("shadow max in flight %d must not be negative", config.MaxInFlight)
+	}
+	if config.MaxInFlight == 0 {
+		config.MaxInFlight = defaultShadowMaxInFlight
+	}
+
+	policy := &shadowPolicy{config: config}
+	if len(config.Bins) > 0 {
+		routes := make(map[string]string, len(config.Bins))
+		for _, bin := range config.Bins {
+			routes[bin] = shadowBin
+		}
+		bins, err := NewBinTable(routes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build shadow BIN table: %w", err)
+		}
+		policy.bins = bins
+	}
+	return policy, nil
+}
+
+// sampled reports whether a request answered live by region is mirrored.
+// Only new authorization and financial requests are mirrored: advices and
+// follow-ups of an original would act on transactions the shadow region has
+// not seen.
+func (p *shadowPolicy) sampled(request *proto.AuthRequest, region string) bool {
+	if region == p.config.Region || request.OriginalData != nil || !isRequest(request.Mti) {
+		return false
+	}
+	if p.bins != nil {
+		if _, ok := p.bins.Lookup(request.Pan); !ok {
+			return false
+		}
+	}
+	return rand.Float64() < p.config.SampleRate
+}
+
+// mirror sends a copy of a request the live region answered to the shadow
+// region in the background, and logs and counts how the answers differ. The
+// live response is neither delayed nor changed.
+func (r *Router) mirror(ctx context.Context, table *routingTable, live callResult) {
+	policy := table.shadow
+	if policy == nil || live.err != nil || !policy.sampled(live.request, live.region) {
+		return
+	}
+	region := policy.config.Region
+
+	r.healthMutex.RLock()
+	health, ok := table.health(region)
+	healthy := ok && health.IsHealthy()
+	r.healthMutex.RUnlock()
+	connection, connected := table.region(region)
+	if !healthy || !connected {
+		r.recordShadow(region, shadowOutcomeDropped)
+		return
+	}
+
+	if r.shadowInFlight.Add(1) > int64(policy.config.MaxInFlight) {
+		r.shadowInFlight.Add(-1)
+		r.recordShadow(region, shadowOutcomeDropped)
+		return
+	}
+
+	// Compare copies, as the live request and response go on to be answered
+	live.request = protobuf.Clone(live.request).(*proto.AuthRequest)
+	live.response = protobuf.Clone(live.response).(*proto.AuthResponse)
+	request := protobuf.Clone(live.request).(*proto.AuthRequest)
+	request.Region = region
+	request.FailoverHops = 0
+	request.FailoverReason = ""
+	request.Shadow = true
+
+	// The shadow call outlives the live request, so it must not inherit its
+	// cancellation
+	shadowCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx),
+		time.Duration(table.config.Regions[region].TimeoutMs)*time.Millisecond)
+	go func() {
+		defer cancel()
+		defer r.shadowInFlight.Add(-1)
+
+		if !connection.acquire() {
+			r.recordShadow(region, shadowOutcomeDropped)
+			return
+		}
+		shadow := connection.call(shadowCtx, region, request)
+		connection.release()
+
+		r.compareShadow(live, shadow)
+	}()
+}
+
+// compareShadow logs and counts the differences between the live and the
+// shadow answer to a request
+func (r *Router) compareShadow(live, shadow callResult) {
+	if r.metrics != nil && shadow.err == nil {
+		r.metrics.ShadowLatencyDelta.WithLabelValues(shadow.region).Observe((shadow.elapsed - live.elapsed).Seconds())
+	}
+
+	switch {
+	case shadow.err != nil:
+		log.Printf("Shadow diff for transaction %s: %s answered %s in %v, %s failed after %v: %v",
+			live.request.Stan, live.region, live.response.ResponseCode, live.elapsed,
+			shadow.region, shadow.elapsed, shadow.err)
+		r.recordShadow(shadow.region, shadowOutcomeError)
+	case shadow.response.ResponseCode != live.response.ResponseCode:
+		log.Printf("Shadow diff for transaction %s: %s answered %s in %v, %s answered %s in %v",
+			live.request.Stan, live.region, live.response.ResponseCode, live.elapsed,
+			shadow.region, shadow.response.ResponseCode, shadow.elapsed)
+		r.recordShadow(shadow.region, shadowOutcomeMismatch)
+	default:
+		r.recordShadow(shadow.region, shadowOutcomeMatch)
+	}
+}
+
+// recordShadow counts the outcome of a mirrored request
+func (r *Router) recordShadow(region, outcome string) {
+	if r.metrics != nil {
+		r.metrics.ShadowRequests.WithLabelValues(region, outcome).Inc()
+	}
+}
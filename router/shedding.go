@@ -0,0 +1,116 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TFMV/pulse/iso"
+	"github.com/moov-io/iso8583"
+)
+
+// Load shedding reasons
+const (
+	shedReasonInFlight     = "in_flight"
+	shedReasonQueueLatency = "queue_latency"
+)
+
+// Weight of the latest message in the queue latency average
+const queueLatencySmoothing = 0.1
+
+// LoadSheddingConfig holds the thresholds past which requests are declined
+// before they reach an issuer
+type LoadSheddingConfig struct {
+	// MaxInFlight is the messages handled at once past which requests are
+	// shed (0 disables the limit)
+	MaxInFlight int `yaml:"max_in_flight"`
+	// MaxQueueLatency is the average time messages wait between being read
+	// and being handled past which requests are shed (0 disables the limit)
+	MaxQueueLatency time.Duration `yaml:"max_queue_latency"`
+	// DeclineCode answers shed requests (default: 91)
+	DeclineCode string `yaml:"decline_code"`
+}
+
+// validate checks the load shedding configuration
+func (c LoadSheddingConfig) validate() error {
+	if c.MaxInFlight < 0 || c.MaxQueueLatency < 0 {
+		return fmt.Errorf("load shedding thresholds must not be negative")
+	}
+	if c.DeclineCode != "" && len(c.DeclineCode) != 2 {
+		return fmt.Errorf("load shedding decline code %q must have 2 characters", c.DeclineCode)
+	}
+	return nil
+}
+
+// LoadShedder tracks the messages in flight and how long messages queue, and
+// decides when requests must be shed
+type LoadShedder struct {
+	config   LoadSheddingConfig
+	inFlight atomic.Int64
+
+	mutex        sync.Mutex
+	queueLatency time.Duration
+}
+
+// NewLoadShedder creates a load shedder for config
+func NewLoadShedder(config LoadSheddingConfig) *LoadShedder {
+	if config.DeclineCode == "" {
+		config.DeclineCode = defaultRateLimitDeclineCode
+	}
+	return &LoadShedder{config: config}
+}
+
+// observeQueueLatency adds a message's queue latency to the average and
+// returns the average
+func (s *LoadShedder) observeQueueLatency(latency time.Duration) time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.queueLatency += time.Duration(queueLatencySmoothing * float64(latency-s.queueLatency))
+	return s.queueLatency
+}
+
+// loadSheddingMiddleware declines authorization and financial requests while
+// too many messages are in flight or messages queue for too long. Advices,
+// reversals and network management messages are always handled.
+func (r *Router) loadSheddingMiddleware(next iso.MessageHandler) iso.MessageHandler {
+	return iso.MessageHandlerFunc(func(ctx context.Context, message *iso8583.Message) (*iso8583.Message, error) {
+		shedder := r.loadShedder
+		if shedder == nil {
+			return next.HandleMessage(ctx, message)
+		}
+
+		inFlight := shedder.inFlight.Add(1)
+		defer shedder.inFlight.Add(-1)
+
+		var queueLatency time.Duration
+		if receivedAt, ok := iso.ReceivedAtFromContext(ctx); ok {
+			queueLatency = shedder.observeQueueLatency(time.Since(receivedAt))
+		}
+
+		mti := exchangeFrom(ctx).MTI
+		if !routedByBin(mti) || !isRequest(mti) {
+			return next.HandleMessage(ctx, message)
+		}
+
+		reason := ""
+		switch {
+		case shedder.config.MaxInFlight > 0 && inFlight > int64(shedder.config.MaxInFlight):
+			reason = shedReasonInFlight
+		case shedder.config.MaxQueueLatency > 0 && queueLatency > shedder.config.MaxQueueLatency:
+			reason = shedReasonQueueLatency
+		default:
+			return next.HandleMessage(ctx, message)
+		}
+
+		log.Printf("Shedding %s with %s: %d messages in flight, %v average queue latency",
+			mti, shedder.config.DeclineCode, inFlight, queueLatency)
+		if r.metrics != nil {
+			r.metrics.LoadShedCount.WithLabelValues(reason).Inc()
+		}
+		return iso.NewResponse(message, shedder.config.DeclineCode)
+	})
+}
@@ -0,0 +1,275 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TFMV/pulse/iso"
+	"github.com/TFMV/pulse/metrics"
+	"github.com/TFMV/pulse/proto"
+)
+
+// Stand-in processing defaults
+const (
+	defaultStandInDeclineCode    = "05" // Do not honor
+	defaultStandInReplayInterval = 5 * time.Second
+	defaultStandInQueueSize      = 10000
+	// standInAdviceTimeout bounds the replay of a single advice
+	standInAdviceTimeout = 5 * time.Second
+)
+
+// Stand-in responses and advices
+const (
+	standInAdviceMti       = "0120"
+	standInApprovalCode    = "00"
+	standInUnavailableCode = "91" // Issuer or switch inoperative
+	// standInRegionLabel is the metrics region of stand-in decisions
+	standInRegionLabel = "stand_in"
+)
+
+// StandInConfig holds stand-in processing (STIP) configuration
+type StandInConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Limits are the per-BIN stand-in limits. Cards without a matching BIN are
+	// declined as issuer inoperative.
+	Limits []StandInLimit `yaml:"limits"`
+	// DeclineCode is the response code for requests over a limit (default: 05)
+	DeclineCode string `yaml:"decline_code"`
+	// QueueSize bounds the advices held per region (default: 10000)
+	QueueSize int `yaml:"queue_size"`
+	// ReplayInterval is how often queued advices are replayed to recovered issuers (default: 5s)
+	ReplayInterval time.Duration `yaml:"replay_interval"`
+}
+
+// StandInLimit holds the stand-in limits for cards whose PAN starts with BIN
+type StandInLimit struct {
+	BIN string `yaml:"bin"`
+	// MaxAmount is the largest single transaction approved in stand-in
+	MaxAmount float64 `yaml:"max_amount"`
+	// MaxCardAmount is the total a card may be approved for while its
+	// approvals are waiting to be replayed (0 = no limit)
+	MaxCardAmount float64 `yaml:"max_card_amount"`
+}
+
+// StandInProcessor approves or declines requests when no issuer region is
+// available, and keeps its approvals until they can be sent to the issuer as
+// 0120 advices
+type StandInProcessor struct {
+	config  StandInConfig
+	metrics *metrics.Metrics
+
+	// queues holds the advices waiting for each region's issuer, in order
+	queues map[string][]*proto.AuthRequest
+	// cardTotals holds the queued approval amounts per PAN
+	cardTotals map[string]float64
+	mutex      sync.Mutex
+}
+
+// NewStandInProcessor creates a stand-in processor
+func NewStandInProcessor(config StandInConfig, metricsCollector *metrics.Metrics) *StandInProcessor {
+	if config.DeclineCode == "" {
+		config.DeclineCode = defaultStandInDeclineCode
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultStandInQueueSize
+	}
+	if config.ReplayInterval <= 0 {
+		config.ReplayInterval = defaultStandInReplayInterval
+	}
+
+	return &StandInProcessor{
+		config:     config,
+		metrics:    metricsCollector,
+		queues:     make(map[string][]*proto.AuthRequest),
+		cardTotals: make(map[string]float64),
+	}
+}
+
+// Authorize decides a request on behalf of the issuer of region. Approvals
+// are queued for replay to that issuer.
+func (p *StandInProcessor) Authorize(req *proto.AuthRequest, region string) *proto.AuthResponse {
+	responseMti, err := iso.ResponseMTI(req.Mti)
+	if err != nil {
+		responseMti = "0110"
+	}
+	resp := &proto.AuthResponse{
+		Mti:              responseMti,
+		Pan:              req.Pan,
+		Amount:           req.Amount,
+		TransmissionTime: req.TransmissionTime,
+		Stan:             req.Stan,
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	resp.ResponseCode = p.decide(req, region)
+	if resp.ResponseCode == standInApprovalCode {
+		log.Printf("Stand-in approved transaction %s for region %s", req.Stan, region)
+	} else {
+		log.Printf("Stand-in declined transaction %s for region %s with response code %s",
+			req.Stan, region, resp.ResponseCode)
+	}
+
+	return resp
+}
+
+// decide applies the BIN limits and queues approvals. It must be called with
+// the mutex held.
+func (p *StandInProcessor) decide(req *proto.AuthRequest, region string) string {
+	if len(p.queues[region]) >= p.config.QueueSize {
+		log.Printf("Stand-in queue for region %s is full", region)
+		return standInUnavailableCode
+	}
+
+	amount, err := strconv.ParseFloat(req.Amount, 64)
+	if err != nil {
+		return invalidTransactionCode
+	}
+
+	// Completion advices capture funds the issuer already approved
+	if req.MessageClass != proto.MessageClass_MESSAGE_CLASS_COMPLETION {
+		limit := p.limitFor(req.Pan)
+		if limit == nil {
+			return standInUnavailableCode
+		}
+		if amount > limit.MaxAmount {
+			return p.config.DeclineCode
+		}
+		if limit.MaxCardAmount > 0 && p.cardTotals[req.Pan]+amount > limit.MaxCardAmount {
+			return p.config.DeclineCode
+		}
+	}
+
+	advice := &proto.AuthRequest{
+		Mti:                 standInAdviceMti,
+		Pan:                 req.Pan,
+		Amount:              req.Amount,
+		TransmissionTime:    req.TransmissionTime,
+		Stan:                req.Stan,
+		Region:              region,
+		OriginalData:        req.OriginalData,
+		MessageClass:        req.MessageClass,
+		StandInResponseCode: standInApprovalCode,
+		AcquirerId:          req.AcquirerId,
+		TerminalId:          req.TerminalId,
+	}
+	p.queues[region] = append(p.queues[region], advice)
+	p.cardTotals[req.Pan] += amount
+	p.updateQueueMetric(region)
+
+	return standInApprovalCode
+}
+
+// limitFor returns the limit with the longest BIN matching the PAN
+func (p *StandInProcessor) limitFor(pan string) *StandInLimit {
+	var best *StandInLimit
+	for i := range p.config.Limits {
+		limit := &p.config.Limits[i]
+		if strings.HasPrefix(pan, limit.BIN) && (best == nil || len(limit.BIN) > len(best.BIN)) {
+			best = limit
+		}
+	}
+	return best
+}
+
+// Pending returns the number of advices waiting for region
+func (p *StandInProcessor) Pending(region string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.queues[region])
+}
+
+// Replay sends the advices queued for region to its issuer in order. It stops
+// at the first failure and leaves the remaining advices queued.
+func (p *StandInProcessor) Replay(ctx context.Context, region string, client proto.AuthServiceClient) (int, error) {
+	replayed := 0
+	for {
+		p.mutex.Lock()
+		queue := p.queues[region]
+		if len(queue) == 0 {
+			p.mutex.Unlock()
+			return replayed, nil
+		}
+		advice := queue[0]
+		p.mutex.Unlock()
+
+		adviceCtx, cancel := context.WithTimeout(ctx, standInAdviceTimeout)
+		_, err := client.ProcessAuth(adviceCtx, advice)
+		cancel()
+		if err != nil {
+			return replayed, fmt.Errorf("failed to replay stand-in advice %s to region %s: %w", advice.Stan, region, err)
+		}
+
+		// Only this goroutine removes advices, so the head is still the one sent
+		p.mutex.Lock()
+		p.queues[region] = p.queues[region][1:]
+		if amount, err := strconv.ParseFloat(advice.Amount, 64); err == nil {
+			p.cardTotals[advice.Pan] -= amount
+			if p.cardTotals[advice.Pan] <= 0 {
+				delete(p.cardTotals, advice.Pan)
+			}
+		}
+		p.updateQueueMetric(region)
+		p.mutex.Unlock()
+
+		replayed++
+	}
+}
+
+// updateQueueMetric publishes the queue depth of region. It must be called
+// with the mutex held.
+func (p *StandInProcessor) updateQueueMetric(region string) {
+	if p.metrics != nil {
+		p.metrics.StandInQueueDepth.WithLabelValues(region).Set(float64(len(p.queues[region])))
+	}
+}
+
+// runStandInReplay periodically replays queued stand-in advices to regions
+// whose circuit has closed again
+func (r *Router) runStandInReplay() {
+	ticker := time.NewTicker(r.standIn.config.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.replayStandIn()
+		case <-r.stopHealthCheck:
+			return
+		}
+	}
+}
+
+// replayStandIn replays queued stand-in advices to every recovered region
+func (r *Router) replayStandIn() {
+	for region, connection := range r.routes.Load().regions {
+		if r.standIn.Pending(region) == 0 {
+			continue
+		}
+
+		r.healthMutex.RLock()
+		closed := connection.health.GetState() == CircuitClosed
+		r.healthMutex.RUnlock()
+		if !closed || !connection.acquire() {
+			continue
+		}
+
+		replayed, err := r.standIn.Replay(context.Background(), region, connection.client)
+		connection.release()
+		if replayed > 0 {
+			log.Printf("Replayed %d stand-in advices to region %s", replayed, region)
+		}
+		if err != nil {
+			log.Printf("Stand-in replay stopped: %v", err)
+			r.healthMutex.Lock()
+			connection.health.RecordFailure()
+			r.healthMutex.Unlock()
+		}
+	}
+}
@@ -1,7 +1,13 @@
 -- Authorizations table stores all processed authorization transactions
 CREATE TABLE Authorizations (
-  -- System Trace Audit Number uniquely identifies a transaction
+  -- System Trace Audit Number (field 11)
   Stan STRING(12) NOT NULL,
+  -- Transmission time from the original request (field 7, MMDDhhmmss)
+  TransmissionTime STRING(10) NOT NULL,
+  -- Acquiring institution ID without leading zeros (field 32)
+  AcquirerId STRING(11) NOT NULL,
+  -- Card acceptor terminal ID (field 41)
+  TerminalId STRING(16) NOT NULL,
   -- Primary Account Number (card number) - sensitive data
   Pan STRING(19) NOT NULL,
   -- Transaction amount
@@ -10,11 +16,21 @@ CREATE TABLE Authorizations (
   Region STRING(50) NOT NULL,
   -- Whether the transaction was approved
   Approved BOOL NOT NULL,
-  -- Transmission time from the original request
-  TransmissionTime TIMESTAMP NOT NULL,
+  -- Message class: authorization, financial, completion or reversal
+  MessageClass STRING(40),
+  -- Whether the transaction has been reversed (0400/0420)
+  Reversed BOOL,
+  -- When the reversal was recorded
+  ReversedAt TIMESTAMP OPTIONS (allow_commit_timestamp=true),
+  -- Region the BIN routes to, which differs from Region after a failover
+  PrimaryRegion STRING(50),
+  -- Failover tiers tried to reach Region (0 = primary region)
+  FailoverHops INT64,
+  -- Why the transaction left PrimaryRegion: primary_unavailable, retry or hedge
+  FailoverReason STRING(40),
   -- When the record was inserted into Spanner
   InsertedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
-) PRIMARY KEY (Stan);
+) PRIMARY KEY (Stan, TransmissionTime, AcquirerId, TerminalId);
 
 -- Index for querying transactions by region
 CREATE INDEX AuthorizationsByRegion
@@ -26,4 +42,14 @@ ON Authorizations (Approved, TransmissionTime DESC);
 
 -- Index for querying transactions by PAN (for cardholder transaction history)
 CREATE INDEX AuthorizationsByPan
-ON Authorizations (Pan, TransmissionTime DESC); 
\ No newline at end of file
+ON Authorizations (Pan, TransmissionTime DESC); 
+-- Responses stores responses for duplicate transmission detection
+CREATE TABLE Responses (
+  -- Duplicate detection key: message class, STAN, transmission time, terminal and acquirer
+  Key STRING(120) NOT NULL,
+  -- Serialized AuthResponse
+  Response BYTES(MAX) NOT NULL,
+  -- When the response may no longer be replayed
+  ExpiresAt TIMESTAMP NOT NULL,
+) PRIMARY KEY (Key),
+  ROW DELETION POLICY (OLDER_THAN(ExpiresAt, INTERVAL 0 DAY));
@@ -13,6 +13,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc/codes"
+	protobuf "google.golang.org/protobuf/proto"
 )
 
 // Config holds Spanner configuration
@@ -106,11 +107,14 @@ func (s *Store) SaveAuthorization(ctx context.Context, auth *proto.AuthRequest,
 	}()
 
 	// Create mutation
+	key := storage.AuthorizationKey(auth)
 	mutation := spanner.InsertOrUpdate("Authorizations", []string{
-		"Stan", "Pan", "Amount", "Region", "Approved", "TransmissionTime", "InsertedAt",
+		"Stan", "TransmissionTime", "AcquirerId", "TerminalId", "Pan", "Amount", "Region", "Approved",
+		"MessageClass", "PrimaryRegion", "FailoverHops", "FailoverReason", "InsertedAt",
 	}, []interface{}{
-		auth.Stan, auth.Pan, auth.Amount, region, approved,
-		auth.TransmissionTime, spanner.CommitTimestamp,
+		key.Stan, key.TransmissionTime, key.AcquirerID, key.TerminalID,
+		auth.Pan, auth.Amount, region, approved, auth.MessageClass.String(),
+		auth.PrimaryRegion, int64(auth.FailoverHops), auth.FailoverReason, spanner.CommitTimestamp,
 	})
 
 	// Apply mutation
@@ -124,7 +128,7 @@ func (s *Store) SaveAuthorization(ctx context.Context, auth *proto.AuthRequest,
 }
 
 // GetTransaction implements the storage.Storage interface
-func (s *Store) GetTransaction(ctx context.Context, stan string) (*proto.AuthRecord, error) {
+func (s *Store) GetTransaction(ctx context.Context, key storage.TransactionKey) (*proto.AuthRecord, error) {
 	if s == nil || s.client == nil {
 		return nil, fmt.Errorf("spanner storage is disabled")
 	}
@@ -136,8 +140,9 @@ func (s *Store) GetTransaction(ctx context.Context, stan string) (*proto.AuthRec
 	}()
 
 	// Execute query
-	row, err := s.client.Single().ReadRow(ctx, "Authorizations", spanner.Key{stan}, []string{
-		"Stan", "Pan", "Amount", "Region", "Approved", "TransmissionTime", "InsertedAt",
+	row, err := s.client.Single().ReadRow(ctx, "Authorizations", transactionKey(key), []string{
+		"Stan", "TransmissionTime", "AcquirerId", "TerminalId", "Pan", "Amount", "Region", "Approved",
+		"Reversed", "MessageClass", "PrimaryRegion", "FailoverHops", "FailoverReason", "InsertedAt",
 	})
 	if err != nil {
 		if spanner.ErrCode(err) == codes.NotFound {
@@ -149,20 +154,45 @@ func (s *Store) GetTransaction(ctx context.Context, stan string) (*proto.AuthRec
 
 	// Parse the result
 	var record storage.AuthRecord
+	var reversed spanner.NullBool
+	var messageClass spanner.NullString
+	var primaryRegion spanner.NullString
+	var failoverHops spanner.NullInt64
+	var failoverReason spanner.NullString
 	var insertedAt spanner.NullTime
 	if err := row.Columns(
 		&record.Stan,
+		&record.TransmissionTime,
+		&record.AcquirerID,
+		&record.TerminalID,
 		&record.Pan,
 		&record.Amount,
 		&record.Region,
 		&record.Approved,
-		&record.TransmissionTime,
+		&reversed,
+		&messageClass,
+		&primaryRegion,
+		&failoverHops,
+		&failoverReason,
 		&insertedAt,
 	); err != nil {
 		s.errorCount.WithLabelValues("get_transaction", "parse_error").Inc()
 		return nil, fmt.Errorf("failed to parse transaction: %w", err)
 	}
 
+	record.Reversed = reversed.Valid && reversed.Bool
+	if messageClass.Valid {
+		record.MessageClass = messageClass.StringVal
+	}
+	if primaryRegion.Valid {
+		record.PrimaryRegion = primaryRegion.StringVal
+	}
+	if failoverHops.Valid {
+		record.FailoverHops = int32(failoverHops.Int64)
+	}
+	if failoverReason.Valid {
+		record.FailoverReason = failoverReason.StringVal
+	}
 	if insertedAt.Valid {
 		record.InsertedAt = insertedAt.Time
 	}
@@ -170,6 +200,114 @@ func (s *Store) GetTransaction(ctx context.Context, stan string) (*proto.AuthRec
 	return record.ToProto(), nil
 }
 
+// MarkReversed implements the storage.Storage interface
+func (s *Store) MarkReversed(ctx context.Context, key storage.TransactionKey) error {
+	if s == nil || s.client == nil {
+		return fmt.Errorf("spanner storage is disabled")
+	}
+
+	// Start timing
+	start := time.Now()
+	defer func() {
+		s.writeLatency.WithLabelValues("mark_reversed").Observe(time.Since(start).Seconds())
+	}()
+
+	// Update fails with NotFound if the original is missing
+	mutation := spanner.Update("Authorizations", []string{
+		"Stan", "TransmissionTime", "AcquirerId", "TerminalId", "Reversed", "ReversedAt",
+	}, []interface{}{
+		key.Stan, key.TransmissionTime, key.AcquirerID, key.TerminalID, true, spanner.CommitTimestamp,
+	})
+
+	_, err := s.client.Apply(ctx, []*spanner.Mutation{mutation})
+	if err != nil {
+		s.errorCount.WithLabelValues("mark_reversed", grpcCodeToString(err)).Inc()
+		return fmt.Errorf("failed to mark transaction reversed: %w", err)
+	}
+
+	return nil
+}
+
+// transactionKey returns the primary key of a row in the Authorizations table
+func transactionKey(key storage.TransactionKey) spanner.Key {
+	return spanner.Key{key.Stan, key.TransmissionTime, key.AcquirerID, key.TerminalID}
+}
+
+// SaveResponse implements the storage.Storage interface
+func (s *Store) SaveResponse(ctx context.Context, key string, response *proto.AuthResponse, expiresAt time.Time) error {
+	if s == nil || s.client == nil {
+		return fmt.Errorf("spanner storage is disabled")
+	}
+
+	// Start timing
+	start := time.Now()
+	defer func() {
+		s.writeLatency.WithLabelValues("save_response").Observe(time.Since(start).Seconds())
+	}()
+
+	data, err := protobuf.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	mutation := spanner.InsertOrUpdate("Responses", []string{
+		"Key", "Response", "ExpiresAt",
+	}, []interface{}{
+		key, data, expiresAt,
+	})
+
+	_, err = s.client.Apply(ctx, []*spanner.Mutation{mutation})
+	if err != nil {
+		s.errorCount.WithLabelValues("save_response", grpcCodeToString(err)).Inc()
+		return fmt.Errorf("failed to save response: %w", err)
+	}
+
+	return nil
+}
+
+// GetResponse implements the storage.Storage interface
+func (s *Store) GetResponse(ctx context.Context, key string) (*proto.AuthResponse, error) {
+	if s == nil || s.client == nil {
+		return nil, fmt.Errorf("spanner storage is disabled")
+	}
+
+	// Start timing
+	start := time.Now()
+	defer func() {
+		s.readLatency.WithLabelValues("get_response").Observe(time.Since(start).Seconds())
+	}()
+
+	row, err := s.client.Single().ReadRow(ctx, "Responses", spanner.Key{key}, []string{
+		"Response", "ExpiresAt",
+	})
+	if err != nil {
+		if spanner.ErrCode(err) == codes.NotFound {
+			return nil, nil // Not found but not an error
+		}
+		s.errorCount.WithLabelValues("get_response", grpcCodeToString(err)).Inc()
+		return nil, fmt.Errorf("failed to get response: %w", err)
+	}
+
+	var data []byte
+	var expiresAt time.Time
+	if err := row.Columns(&data, &expiresAt); err != nil {
+		s.errorCount.WithLabelValues("get_response", "parse_error").Inc()
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Expired rows are removed by the row deletion policy, which runs lazily
+	if time.Now().After(expiresAt) {
+		return nil, nil
+	}
+
+	var response proto.AuthResponse
+	if err := protobuf.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &response, nil
+}
+
 // Close implements the storage.Storage interface
 func (s *Store) Close() error {
 	if s == nil || s.client == nil {
@@ -0,0 +1,124 @@
+package spec
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moov-io/iso8583"
+	"github.com/moov-io/iso8583/specs"
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the built-in message specs
+const (
+	// ISO87ASCII is ISO 8583:1987 with ASCII fields and a hex bitmap
+	ISO87ASCII = "iso87ascii"
+	// ISO93ASCII is ISO 8583:1993 with ASCII fields and a hex bitmap
+	ISO93ASCII = "iso93ascii"
+)
+
+//go:embed *.yaml
+var builtinSpecs embed.FS
+
+// Default returns the built-in ISO 8583:1987 ASCII spec
+func Default() *iso8583.MessageSpec {
+	messageSpec, err := Load(ISO87ASCII)
+	if err != nil {
+		// The built-in specs are embedded at compile time and must always parse
+		panic(fmt.Sprintf("failed to load built-in spec %s: %v", ISO87ASCII, err))
+	}
+	return messageSpec
+}
+
+// Load returns the message spec identified by name. The name is either one of
+// the built-in spec names or the path to a JSON or YAML spec file. An empty
+// name selects the default spec.
+func Load(name string) (*iso8583.MessageSpec, error) {
+	if name == "" {
+		name = ISO87ASCII
+	}
+
+	// Check the built-in specs first
+	if data, err := builtinSpecs.ReadFile(name + ".yaml"); err == nil {
+		return Parse(data, "yaml")
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec file: %w", err)
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	messageSpec, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load spec %s: %w", name, err)
+	}
+
+	return messageSpec, nil
+}
+
+// Parse builds a message spec from its JSON or YAML description. The format
+// is "json", "yaml" or "yml".
+//
+// Fields are keyed by field number and use the moov-io/iso8583 spec schema:
+// type, length, description, enc, prefix, padding and, for composite fields,
+// subfields, tag and bitmap.
+func Parse(data []byte, format string) (*iso8583.MessageSpec, error) {
+	switch format {
+	case "json":
+	case "yaml", "yml":
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML spec: %w", err)
+		}
+
+		// The spec builder only understands JSON, so convert the YAML document
+		converted, err := json.Marshal(normalizeYAML(doc))
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert YAML spec: %w", err)
+		}
+		data = converted
+	default:
+		return nil, fmt.Errorf("unsupported spec format: %q", format)
+	}
+
+	messageSpec, err := specs.Builder.ImportJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build spec: %w", err)
+	}
+
+	if err := messageSpec.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid spec %q: %w", messageSpec.Name, err)
+	}
+
+	return messageSpec, nil
+}
+
+// normalizeYAML converts YAML maps with non-string keys, such as unquoted
+// field numbers, into maps that can be marshaled as JSON
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeYAML(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprintf("%v", key)] = normalizeYAML(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeYAML(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
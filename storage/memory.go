@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TFMV/pulse/proto"
+)
+
+// sweepInterval is how often expired responses are removed
+const sweepInterval = time.Minute
+
+// MemoryStore implements the Storage interface in memory. Records are lost on
+// restart, so it is meant for development and tests.
+type MemoryStore struct {
+	records   map[TransactionKey]*AuthRecord
+	responses map[string]*storedResponse
+	lastSweep time.Time
+	mutex     sync.RWMutex
+}
+
+// storedResponse is a response kept for duplicate detection
+type storedResponse struct {
+	response  *proto.AuthResponse
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records:   make(map[TransactionKey]*AuthRecord),
+		responses: make(map[string]*storedResponse),
+		lastSweep: time.Now(),
+	}
+}
+
+// SaveAuthorization implements the Storage interface
+func (m *MemoryStore) SaveAuthorization(ctx context.Context, auth *proto.AuthRequest, region string, approved bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := AuthorizationKey(auth)
+	m.records[key] = &AuthRecord{
+		Stan:             auth.Stan,
+		Pan:              auth.Pan,
+		Amount:           auth.Amount,
+		Region:           region,
+		Approved:         approved,
+		TransmissionTime: auth.TransmissionTime,
+		AcquirerID:       key.AcquirerID,
+		TerminalID:       key.TerminalID,
+		MessageClass:     auth.MessageClass.String(),
+		PrimaryRegion:    auth.PrimaryRegion,
+		FailoverHops:     auth.FailoverHops,
+		FailoverReason:   auth.FailoverReason,
+		InsertedAt:       time.Now(),
+	}
+
+	return nil
+}
+
+// GetTransaction implements the Storage interface
+func (m *MemoryStore) GetTransaction(ctx context.Context, key TransactionKey) (*proto.AuthRecord, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	record, ok := m.records[key]
+	if !ok {
+		return nil, nil // Not found but not an error
+	}
+
+	return record.ToProto(), nil
+}
+
+// MarkReversed implements the Storage interface
+func (m *MemoryStore) MarkReversed(ctx context.Context, key TransactionKey) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	record, ok := m.records[key]
+	if !ok {
+		return fmt.Errorf("transaction %s not found", key)
+	}
+	record.Reversed = true
+
+	return nil
+}
+
+// SaveResponse implements the Storage interface
+func (m *MemoryStore) SaveResponse(ctx context.Context, key string, response *proto.AuthResponse, expiresAt time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		for k, stored := range m.responses {
+			if now.After(stored.expiresAt) {
+				delete(m.responses, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	m.responses[key] = &storedResponse{response: response, expiresAt: expiresAt}
+
+	return nil
+}
+
+// GetResponse implements the Storage interface
+func (m *MemoryStore) GetResponse(ctx context.Context, key string) (*proto.AuthResponse, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stored, ok := m.responses[key]
+	if !ok || time.Now().After(stored.expiresAt) {
+		return nil, nil // Not found but not an error
+	}
+
+	return stored.response, nil
+}
+
+// Close implements the Storage interface
+func (m *MemoryStore) Close() error {
+	return nil
+}
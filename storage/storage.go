@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/TFMV/pulse/proto"
@@ -12,13 +13,54 @@ type Storage interface {
 	// SaveAuthorization persists the authorization request and result
 	SaveAuthorization(ctx context.Context, auth *proto.AuthRequest, region string, approved bool) error
 
-	// GetTransaction retrieves a transaction by STAN
-	GetTransaction(ctx context.Context, stan string) (*proto.AuthRecord, error)
+	// GetTransaction retrieves a transaction by its key
+	GetTransaction(ctx context.Context, key TransactionKey) (*proto.AuthRecord, error)
+
+	// MarkReversed records that the transaction with the given key has been reversed
+	MarkReversed(ctx context.Context, key TransactionKey) error
+
+	// SaveResponse stores the response to a request under its duplicate detection key until expiresAt
+	SaveResponse(ctx context.Context, key string, response *proto.AuthResponse, expiresAt time.Time) error
+
+	// GetResponse retrieves an unexpired response by duplicate detection key
+	GetResponse(ctx context.Context, key string) (*proto.AuthResponse, error)
 
 	// Close closes the storage connection
 	Close() error
 }
 
+// TransactionKey identifies a stored transaction. A STAN alone is not unique:
+// it wraps every million messages and every terminal and acquirer numbers its
+// own, so it is qualified by the transmission time (field 7), the acquiring
+// institution ID (field 32) and the terminal ID (field 41).
+type TransactionKey struct {
+	Stan             string
+	TransmissionTime string
+	AcquirerID       string
+	TerminalID       string
+}
+
+// NewTransactionKey creates a transaction key. Leading zeros are dropped from
+// the acquirer ID, which original data elements (field 90) zero-fill.
+func NewTransactionKey(stan, transmissionTime, acquirerID, terminalID string) TransactionKey {
+	return TransactionKey{
+		Stan:             stan,
+		TransmissionTime: transmissionTime,
+		AcquirerID:       strings.TrimLeft(acquirerID, "0"),
+		TerminalID:       terminalID,
+	}
+}
+
+// AuthorizationKey returns the key an authorization request is stored under
+func AuthorizationKey(auth *proto.AuthRequest) TransactionKey {
+	return NewTransactionKey(auth.Stan, auth.TransmissionTime, auth.AcquirerId, auth.TerminalId)
+}
+
+// String formats the key for logs
+func (k TransactionKey) String() string {
+	return strings.Join([]string{k.Stan, k.TransmissionTime, k.AcquirerID, k.TerminalID}, "/")
+}
+
 // AuthRecord represents a stored transaction record
 type AuthRecord struct {
 	Stan             string    `json:"stan"`
@@ -27,6 +69,13 @@ type AuthRecord struct {
 	Region           string    `json:"region"`
 	Approved         bool      `json:"approved"`
 	TransmissionTime string    `json:"transmission_time"`
+	AcquirerID       string    `json:"acquirer_id"`
+	TerminalID       string    `json:"terminal_id"`
+	Reversed         bool      `json:"reversed"`
+	MessageClass     string    `json:"message_class"`
+	PrimaryRegion    string    `json:"primary_region"`
+	FailoverHops     int32     `json:"failover_hops"`
+	FailoverReason   string    `json:"failover_reason"`
 	InsertedAt       time.Time `json:"inserted_at"`
 }
 
@@ -39,6 +88,13 @@ func (a *AuthRecord) ToProto() *proto.AuthRecord {
 		Region:           a.Region,
 		Approved:         a.Approved,
 		TransmissionTime: a.TransmissionTime,
+		AcquirerId:       a.AcquirerID,
+		TerminalId:       a.TerminalID,
+		Reversed:         a.Reversed,
+		MessageClass:     proto.MessageClass(proto.MessageClass_value[a.MessageClass]),
+		PrimaryRegion:    a.PrimaryRegion,
+		FailoverHops:     a.FailoverHops,
+		FailoverReason:   a.FailoverReason,
 		InsertedAt:       a.InsertedAt.Format(time.RFC3339),
 	}
 }
@@ -3,6 +3,7 @@ package workflow
 import (
 	"time"
 
+	"github.com/TFMV/pulse/iso"
 	"github.com/TFMV/pulse/proto"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
@@ -182,12 +183,11 @@ func (w *PaymentWorkflow) Execute(ctx workflow.Context, request *proto.AuthReque
 	return response, nil
 }
 
-// getResponseMTI converts a request MTI to a response MTI (e.g., 0100 -> 0110)
+// getResponseMTI converts a request MTI to a response MTI (e.g., 0100 -> 0110, 0220 -> 0230)
 func getResponseMTI(requestMTI string) string {
-	if len(requestMTI) != 4 {
+	responseMTI, err := iso.ResponseMTI(requestMTI)
+	if err != nil {
 		return "0110" // Default response MTI
 	}
-
-	// The response MTI typically changes the third digit from 0->1
-	return requestMTI[:2] + "1" + requestMTI[3:]
+	return responseMTI
 }